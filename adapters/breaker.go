@@ -0,0 +1,123 @@
+package adapters
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	defaultBreakerFailureThreshold = 5
+	defaultBreakerOpenDuration     = time.Second * 10
+)
+
+// CircuitOpenError is an error type represents the circuit of the dependency is open and the call is rejected.
+type CircuitOpenError struct {
+	Dependency string
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker for %s is open", e.Dependency)
+}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+type NewCircuitBreakerOption func(b *CircuitBreaker)
+
+// WithFailureThreshold configures the number of consecutive failures that opens the circuit.
+func WithFailureThreshold(n int) NewCircuitBreakerOption {
+	return func(b *CircuitBreaker) { b.failureThreshold = n }
+}
+
+// WithOpenDuration configures the duration the circuit stays open before a trial call is permitted.
+func WithOpenDuration(d time.Duration) NewCircuitBreakerOption {
+	return func(b *CircuitBreaker) { b.openDuration = d }
+}
+
+// NewCircuitBreaker returns a CircuitBreaker guarding the dependency.
+func NewCircuitBreaker(dependency string, optFns ...NewCircuitBreakerOption) *CircuitBreaker {
+	b := &CircuitBreaker{dependency: dependency}
+	for _, f := range optFns {
+		f(b)
+	}
+	if b.failureThreshold == 0 {
+		b.failureThreshold = defaultBreakerFailureThreshold
+	}
+	if b.openDuration == 0 {
+		b.openDuration = defaultBreakerOpenDuration
+	}
+	return b
+}
+
+// CircuitBreaker rejects calls to the dependency fast while it keeps failing.
+//
+// The circuit opens after consecutive failures reach the threshold, and permits a single trial call after the open duration.
+// The circuit closes if the trial call succeeds, otherwise it opens again.
+type CircuitBreaker struct {
+	dependency       string
+	failureThreshold int
+	openDuration     time.Duration
+
+	mux      sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+}
+
+// Dependency returns the name of the guarded dependency.
+func (b *CircuitBreaker) Dependency() string { return b.dependency }
+
+// Allow reports whether the call to the dependency is permitted.
+//
+// If the call is permitted, the caller must report its outcome via Success or Failure.
+func (b *CircuitBreaker) Allow() error {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	switch b.state {
+	case breakerOpen:
+		elapsed := time.Since(b.openedAt)
+		if elapsed < b.openDuration {
+			return &CircuitOpenError{Dependency: b.dependency, RetryAfter: b.openDuration - elapsed}
+		}
+		b.state = breakerHalfOpen
+		return nil
+	case breakerHalfOpen:
+		return &CircuitOpenError{Dependency: b.dependency, RetryAfter: b.openDuration}
+	default:
+		return nil
+	}
+}
+
+// Success reports the permitted call succeeded.
+func (b *CircuitBreaker) Success() {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.state = breakerClosed
+	b.failures = 0
+}
+
+// Failure reports the permitted call failed.
+func (b *CircuitBreaker) Failure() {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.failureThreshold {
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// Trip opens the circuit immediately, such as when a health check finds the dependency down.
+func (b *CircuitBreaker) Trip() {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.state = breakerOpen
+	b.openedAt = time.Now()
+}
@@ -0,0 +1,376 @@
+package adapters
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrDBHostRequired = errors.New("db host is required")
+	ErrDBUserRequired = errors.New("db user is required")
+	ErrDBPortInvalid  = errors.New("db port must be between 1 and 65535")
+
+	defaultDBPort                  = 3306
+	defaultPasswordRefreshInterval = time.Minute * 5
+)
+
+// PasswordSource returns the password to connect to the database.
+type PasswordSource func(ctx context.Context) (string, error)
+
+// PasswordValue returns a PasswordSource that returns the given password.
+func PasswordValue(password string) PasswordSource {
+	return func(context.Context) (string, error) { return password, nil }
+}
+
+// PasswordFromFile returns a PasswordSource that reads the password from the file such as a mounted secret.
+//
+// The trailing newline is trimmed.
+func PasswordFromFile(path string) PasswordSource {
+	return func(context.Context) (string, error) {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("os.ReadFile: %w", err)
+		}
+		return strings.TrimRight(string(b), "\r\n"), nil
+	}
+}
+
+type NewConfigOption func(cfg *Config)
+
+// WithHost configures the host and the port of the database server.
+//
+// If the port is zero, 3306 is used.
+func WithHost(host string, port int) NewConfigOption {
+	return func(cfg *Config) {
+		cfg.Host = host
+		cfg.Port = port
+	}
+}
+
+// WithFailoverEndpoints configures the endpoints tried in order when the primary host cannot be connected to.
+//
+// If the port of an endpoint is zero, 3306 is used.
+func WithFailoverEndpoints(endpoints ...Endpoint) NewConfigOption {
+	return func(cfg *Config) { cfg.FailoverEndpoints = append(cfg.FailoverEndpoints, endpoints...) }
+}
+
+// WithUser configures the user and the source of the password.
+func WithUser(user string, password PasswordSource) NewConfigOption {
+	return func(cfg *Config) {
+		cfg.User = user
+		cfg.Password = password
+	}
+}
+
+// WithDatabase configures the database selected on connect.
+func WithDatabase(database string) NewConfigOption {
+	return func(cfg *Config) { cfg.Database = database }
+}
+
+// WithParam adds the connection parameter passed to the driver.
+func WithParam(key, value string) NewConfigOption {
+	return func(cfg *Config) {
+		if cfg.Params == nil {
+			cfg.Params = map[string]string{}
+		}
+		cfg.Params[key] = value
+	}
+}
+
+// WithLocation configures the time zone the time values are parsed in.
+func WithLocation(loc *time.Location) NewConfigOption {
+	return func(cfg *Config) { cfg.Location = loc }
+}
+
+// WithMaxExecutionTime makes the server abort the SELECT statements that run longer than the duration by setting the max_execution_time session variable.
+func WithMaxExecutionTime(d time.Duration) NewConfigOption {
+	return WithParam("max_execution_time", strconv.FormatInt(d.Milliseconds(), 10))
+}
+
+// NewConfig returns a validated Config.
+func NewConfig(optFns ...NewConfigOption) (*Config, error) {
+	cfg := new(Config)
+	for _, f := range optFns {
+		f(cfg)
+	}
+	if cfg.Port == 0 {
+		cfg.Port = defaultDBPort
+	}
+	for i := range cfg.FailoverEndpoints {
+		if cfg.FailoverEndpoints[i].Port == 0 {
+			cfg.FailoverEndpoints[i].Port = defaultDBPort
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// ConfigFromEnv builds a Config from DB_HOST, DB_PORT, DB_USER and DB_NAME.
+//
+// The password is read from the first one set of DB_PASSWORD_SECRET_ID (AWS Secrets Manager, with the JSON key DB_PASSWORD_SECRET_KEY),
+// DB_PASSWORD_VAULT_PATH (Vault KV v2 at VAULT_ADDR with VAULT_TOKEN, with the field DB_PASSWORD_VAULT_FIELD), DB_PASSWORD_FILE and DB_PASSWORD.
+// The passwords from the secret stores are refreshed every DB_PASSWORD_REFRESH_INTERVAL, 5 minutes by default.
+// If DB_IAM_AUTH_REGION is set, the RDS IAM authentication is used instead of the password.
+// DB_FAILOVER_HOSTS is the comma-separated list of host[:port] tried in order when DB_HOST cannot be connected to.
+// DB_SOCKET connects through the unix domain socket, DB_SOCKS5_PROXY through the SOCKS5 proxy, and DB_SSH_TUNNEL_ADDR through the SSH server
+// with DB_SSH_USER, the private key at DB_SSH_KEY_FILE and the known hosts at DB_SSH_KNOWN_HOSTS.
+// DB_TIME_ZONE names the time zone the time values are parsed in, UTC by default.
+// If DB_STATEMENT_TIMEOUT is set, it is also applied to the server as max_execution_time.
+// If any of DB_TLS_CA_FILE, DB_TLS_CERT_FILE, DB_TLS_KEY_FILE and DB_TLS_SERVER_NAME is set, the connections use TLS.
+func ConfigFromEnv(ctx context.Context) (*Config, error) {
+	var port int
+	if v := os.Getenv("DB_PORT"); v != "" {
+		p, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("DB_PORT: %w", err)
+		}
+		port = p
+	}
+	refreshInterval := defaultPasswordRefreshInterval
+	if v := os.Getenv("DB_PASSWORD_REFRESH_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("DB_PASSWORD_REFRESH_INTERVAL: %w", err)
+		}
+		refreshInterval = d
+	}
+	optFns := []NewConfigOption{WithDatabase(os.Getenv("DB_NAME"))}
+	if v := os.Getenv("DB_FAILOVER_HOSTS"); v != "" {
+		endpoints, err := parseEndpoints(v)
+		if err != nil {
+			return nil, fmt.Errorf("DB_FAILOVER_HOSTS: %w", err)
+		}
+		optFns = append(optFns, WithFailoverEndpoints(endpoints...))
+	}
+	switch {
+	case os.Getenv("DB_SOCKET") != "":
+		optFns = append(optFns, WithUnixSocket(os.Getenv("DB_SOCKET")))
+	case os.Getenv("DB_SOCKS5_PROXY") != "":
+		dial, err := SOCKS5Dialer(os.Getenv("DB_SOCKS5_PROXY"), nil)
+		if err != nil {
+			return nil, err
+		}
+		optFns = append(optFns, WithDialer(dial))
+	case os.Getenv("DB_SSH_TUNNEL_ADDR") != "":
+		sshCfg, err := sshClientConfigFromFiles(os.Getenv("DB_SSH_USER"), os.Getenv("DB_SSH_KEY_FILE"), os.Getenv("DB_SSH_KNOWN_HOSTS"))
+		if err != nil {
+			return nil, err
+		}
+		optFns = append(optFns, WithDialer(SSHTunnelDialer(os.Getenv("DB_SSH_TUNNEL_ADDR"), sshCfg)))
+	}
+	if v := os.Getenv("DB_TIME_ZONE"); v != "" {
+		loc, err := time.LoadLocation(v)
+		if err != nil {
+			return nil, fmt.Errorf("DB_TIME_ZONE: %w", err)
+		}
+		optFns = append(optFns, WithLocation(loc))
+	}
+	if v := os.Getenv("DB_STATEMENT_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("DB_STATEMENT_TIMEOUT: %w", err)
+		}
+		optFns = append(optFns, WithMaxExecutionTime(d))
+	}
+	if tc := (TLSConfig{CAFile: os.Getenv("DB_TLS_CA_FILE"), CertFile: os.Getenv("DB_TLS_CERT_FILE"), KeyFile: os.Getenv("DB_TLS_KEY_FILE"), ServerName: os.Getenv("DB_TLS_SERVER_NAME")}); tc != (TLSConfig{}) {
+		optFns = append(optFns, WithTLS(tc))
+	}
+	var password PasswordSource
+	switch {
+	case os.Getenv("DB_IAM_AUTH_REGION") != "":
+		awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("awsconfig.LoadDefaultConfig: %w", err)
+		}
+		optFns = append(optFns, WithIAMAuth(os.Getenv("DB_IAM_AUTH_REGION"), awsCfg.Credentials))
+	case os.Getenv("DB_PASSWORD_SECRET_ID") != "":
+		awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("awsconfig.LoadDefaultConfig: %w", err)
+		}
+		password = CachedPassword(PasswordFromSecretsManager(secretsmanager.NewFromConfig(awsCfg), os.Getenv("DB_PASSWORD_SECRET_ID"), os.Getenv("DB_PASSWORD_SECRET_KEY")), refreshInterval)
+	case os.Getenv("DB_PASSWORD_VAULT_PATH") != "":
+		field := os.Getenv("DB_PASSWORD_VAULT_FIELD")
+		if field == "" {
+			field = "password"
+		}
+		password = CachedPassword(PasswordFromVault(os.Getenv("VAULT_ADDR"), os.Getenv("VAULT_TOKEN"), os.Getenv("DB_PASSWORD_VAULT_PATH"), field), refreshInterval)
+	case os.Getenv("DB_PASSWORD_FILE") != "":
+		password = PasswordFromFile(os.Getenv("DB_PASSWORD_FILE"))
+	default:
+		password = PasswordValue(os.Getenv("DB_PASSWORD"))
+	}
+	optFns = append(optFns, WithHost(os.Getenv("DB_HOST"), port), WithUser(os.Getenv("DB_USER"), password))
+	return NewConfig(optFns...)
+}
+
+// Config describes how to connect to the MySQL server.
+type Config struct {
+	Host     string
+	Port     int
+	User     string
+	Password PasswordSource
+	Database string
+	Params   map[string]string
+	IAMAuth  *IAMAuth
+	TLS      *TLSConfig
+	// FailoverEndpoints are tried in order when Host cannot be connected to.
+	FailoverEndpoints []Endpoint
+	// Socket is the path to the unix domain socket to connect to instead of Host and Port.
+	Socket string
+	// Dial opens the connections instead of dialing Host and Port directly.
+	Dial DialFunc
+	// Location is the time zone the time values are parsed in; UTC is used if nil.
+	Location *time.Location
+
+	dialOnce    sync.Once
+	dialNetwork string
+}
+
+func (c *Config) Validate() error {
+	if c.Host == "" && c.Socket == "" {
+		return ErrDBHostRequired
+	}
+	if c.User == "" {
+		return ErrDBUserRequired
+	}
+	for _, ep := range c.endpoints() {
+		if ep.Port < 1 || ep.Port > 65535 {
+			return ErrDBPortInvalid
+		}
+	}
+	return nil
+}
+
+// DSN builds the DSN for the MySQL driver, resolving the password.
+//
+// The DSN cannot carry the TLS certificates, so use OpenDBWithConfig to connect with TLSConfig.
+func (c *Config) DSN(ctx context.Context) (string, error) {
+	mc, err := c.mysqlConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+	return mc.FormatDSN(), nil
+}
+
+// endpoints returns the primary endpoint followed by the failover endpoints.
+func (c *Config) endpoints() []Endpoint {
+	return append([]Endpoint{{Host: c.Host, Port: c.Port}}, c.FailoverEndpoints...)
+}
+
+func (c *Config) mysqlConfig(ctx context.Context) (*mysql.Config, error) {
+	return c.mysqlConfigFor(ctx, Endpoint{Host: c.Host, Port: c.Port})
+}
+
+func (c *Config) mysqlConfigFor(ctx context.Context, ep Endpoint) (*mysql.Config, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	mc := mysql.NewConfig()
+	switch {
+	case c.Socket != "":
+		mc.Net = "unix"
+		mc.Addr = c.Socket
+	case c.Dial != nil:
+		c.dialOnce.Do(func() { c.dialNetwork = registerDialFunc(c.Dial) })
+		mc.Net = c.dialNetwork
+		mc.Addr = ep.addr()
+	default:
+		mc.Net = "tcp"
+		mc.Addr = ep.addr()
+	}
+	mc.User = c.User
+	mc.DBName = c.Database
+	mc.ParseTime = true
+	mc.Loc = time.UTC
+	if c.Location != nil {
+		mc.Loc = c.Location
+	}
+	switch {
+	case c.IAMAuth != nil:
+		token, err := c.IAMAuth.buildToken(ctx, ep, c.User)
+		if err != nil {
+			return nil, err
+		}
+		mc.Passwd = token
+		mc.AllowCleartextPasswords = true
+		mc.TLSConfig = "true"
+	case c.Password != nil:
+		password, err := c.Password(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to obtain db password: %w", err)
+		}
+		mc.Passwd = password
+	}
+	if c.TLS != nil {
+		tlsCfg, err := c.TLS.build(ep.Host)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build db TLS config: %w", err)
+		}
+		mc.TLS = tlsCfg
+	}
+	if len(c.Params) > 0 {
+		mc.Params = make(map[string]string, len(c.Params))
+		for k, v := range c.Params {
+			mc.Params[k] = v
+		}
+	}
+	return mc, nil
+}
+
+// OpenDBWithConfig is like OpenDB but builds the connection settings from the Config.
+//
+// The password is resolved each time a new connection is made, so the pool picks up a rotated password without being reopened;
+// the connections already established keep working until the pool retires them.
+func OpenDBWithConfig(cfg *Config, optFns ...OpenDBOption) (*sqlx.DB, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	endpoints := cfg.endpoints()
+	if cfg.Socket != "" {
+		endpoints = endpoints[:1]
+	}
+	connectors := make([]driver.Connector, len(endpoints))
+	for i, ep := range endpoints {
+		connectors[i] = &configConnector{cfg: cfg, endpoint: ep}
+	}
+	return openConnector(cfg.Database, newOpenDBConfig(optFns...), connectors...)
+}
+
+// configConnector is a driver.Connector that resolves the Config on every connect.
+type configConnector struct {
+	cfg      *Config
+	endpoint Endpoint
+}
+
+var _ driver.Connector = (*configConnector)(nil)
+
+func (c *configConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	mc, err := c.cfg.mysqlConfigFor(ctx, c.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	connector, err := mysql.NewConnector(mc)
+	if err != nil {
+		return nil, fmt.Errorf("mysql.NewConnector: %w", err)
+	}
+	return connector.Connect(ctx)
+}
+
+func (c *configConnector) Driver() driver.Driver {
+	return &mysql.MySQLDriver{}
+}
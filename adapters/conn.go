@@ -0,0 +1,309 @@
+package adapters
+
+import (
+	"context"
+	"database/sql/driver"
+	"enjoymultitenancy/telemetry"
+	"io"
+	"reflect"
+	"time"
+
+	"go.opentelemetry.io/otel/metric"
+)
+
+// WithStatementTimeout makes the DB handle cancel each statement that runs longer than the timeout, including the time to read the rows,
+// so that a runaway query cannot hold the connection forever.
+//
+// The deadline of the caller's context takes precedence if it is earlier.
+func WithStatementTimeout(timeout time.Duration) OpenDBOption {
+	return func(cfg *openDBConfig) { cfg.wrap.statementTimeout = timeout }
+}
+
+type wrapConfig struct {
+	slowQueryThreshold time.Duration
+	statementTimeout   time.Duration
+	sqlComment         bool
+	explainFraction    float64
+	queryCounting      bool
+	tenantGuard        *telemetry.TenantGuard
+	queryDuration      metric.Float64Histogram
+}
+
+func (cfg *wrapConfig) enabled() bool {
+	return cfg.slowQueryThreshold > 0 || cfg.statementTimeout > 0 || cfg.sqlComment || cfg.explainFraction > 0 || cfg.queryCounting || cfg.tenantGuard != nil
+}
+
+func (cfg *wrapConfig) annotate(ctx context.Context, query string) string {
+	if !cfg.sqlComment {
+		return query
+	}
+	return appendSQLComment(ctx, query)
+}
+
+func (cfg *wrapConfig) withStatementTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if cfg.statementTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, cfg.statementTimeout)
+}
+
+// wrappedConnector is a driver.Connector that applies the statement timeout, the slow query reports, the SQL comments, the EXPLAIN sampling, the query counting and the per-tenant metrics to the connections.
+type wrappedConnector struct {
+	driver.Connector
+	cfg *wrapConfig
+}
+
+func (c *wrappedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &wrappedConn{Conn: conn, cfg: c.cfg}, nil
+}
+
+type wrappedConn struct {
+	driver.Conn
+	cfg *wrapConfig
+}
+
+var (
+	_ driver.ExecerContext      = (*wrappedConn)(nil)
+	_ driver.QueryerContext     = (*wrappedConn)(nil)
+	_ driver.ConnPrepareContext = (*wrappedConn)(nil)
+	_ driver.ConnBeginTx        = (*wrappedConn)(nil)
+	_ driver.Pinger             = (*wrappedConn)(nil)
+	_ driver.SessionResetter    = (*wrappedConn)(nil)
+	_ driver.Validator          = (*wrappedConn)(nil)
+	_ driver.NamedValueChecker  = (*wrappedConn)(nil)
+)
+
+func (c *wrappedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, cancel := c.cfg.withStatementTimeout(ctx)
+	defer cancel()
+	query = c.cfg.annotate(ctx, query)
+	startedAt := time.Now()
+	res, err := execer.ExecContext(ctx, query, args)
+	if err != driver.ErrSkip {
+		countQuery(ctx)
+		reportSlowQuery(ctx, c.cfg.slowQueryThreshold, query, startedAt)
+		c.cfg.recordQueryDuration(ctx, startedAt)
+	}
+	return res, err
+}
+
+func (c *wrappedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, cancel := c.cfg.withStatementTimeout(ctx)
+	query = c.cfg.annotate(ctx, query)
+	if shouldExplain(c.cfg.explainFraction, query) {
+		recordExplain(ctx, c.Conn, query, args)
+	}
+	startedAt := time.Now()
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != driver.ErrSkip {
+		countQuery(ctx)
+		reportSlowQuery(ctx, c.cfg.slowQueryThreshold, query, startedAt)
+		c.cfg.recordQueryDuration(ctx, startedAt)
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &cancelOnCloseRows{Rows: rows, cancel: cancel}, nil
+}
+
+func (c *wrappedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	query = c.cfg.annotate(ctx, query)
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &wrappedStmt{Stmt: stmt, query: query, cfg: c.cfg}, nil
+}
+
+func (c *wrappedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *wrappedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *wrappedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *wrappedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+func (c *wrappedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+type wrappedStmt struct {
+	driver.Stmt
+	query string
+	cfg   *wrapConfig
+}
+
+var (
+	_ driver.StmtExecContext   = (*wrappedStmt)(nil)
+	_ driver.StmtQueryContext  = (*wrappedStmt)(nil)
+	_ driver.NamedValueChecker = (*wrappedStmt)(nil)
+)
+
+func (s *wrappedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	ctx, cancel := s.cfg.withStatementTimeout(ctx)
+	defer cancel()
+	startedAt := time.Now()
+	var (
+		res driver.Result
+		err error
+	)
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		res, err = execer.ExecContext(ctx, args)
+	} else {
+		res, err = s.Stmt.Exec(namedValuesToValues(args))
+	}
+	countQuery(ctx)
+	reportSlowQuery(ctx, s.cfg.slowQueryThreshold, s.query, startedAt)
+	s.cfg.recordQueryDuration(ctx, startedAt)
+	return res, err
+}
+
+func (s *wrappedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	ctx, cancel := s.cfg.withStatementTimeout(ctx)
+	startedAt := time.Now()
+	var (
+		rows driver.Rows
+		err  error
+	)
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = queryer.QueryContext(ctx, args)
+	} else {
+		rows, err = s.Stmt.Query(namedValuesToValues(args))
+	}
+	countQuery(ctx)
+	reportSlowQuery(ctx, s.cfg.slowQueryThreshold, s.query, startedAt)
+	s.cfg.recordQueryDuration(ctx, startedAt)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &cancelOnCloseRows{Rows: rows, cancel: cancel}, nil
+}
+
+func (s *wrappedStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+func namedValuesToValues(named []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(named))
+	for i, nv := range named {
+		values[i] = nv.Value
+	}
+	return values
+}
+
+// cancelOnCloseRows releases the statement timeout when the rows are closed, so the timeout covers reading the rows.
+type cancelOnCloseRows struct {
+	driver.Rows
+	cancel context.CancelFunc
+}
+
+var (
+	_ driver.RowsNextResultSet              = (*cancelOnCloseRows)(nil)
+	_ driver.RowsColumnTypeDatabaseTypeName = (*cancelOnCloseRows)(nil)
+	_ driver.RowsColumnTypeScanType         = (*cancelOnCloseRows)(nil)
+	_ driver.RowsColumnTypeNullable         = (*cancelOnCloseRows)(nil)
+	_ driver.RowsColumnTypeLength           = (*cancelOnCloseRows)(nil)
+	_ driver.RowsColumnTypePrecisionScale   = (*cancelOnCloseRows)(nil)
+)
+
+func (r *cancelOnCloseRows) Close() error {
+	defer r.cancel()
+	return r.Rows.Close()
+}
+
+func (r *cancelOnCloseRows) HasNextResultSet() bool {
+	if rs, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return rs.HasNextResultSet()
+	}
+	return false
+}
+
+func (r *cancelOnCloseRows) NextResultSet() error {
+	if rs, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return rs.NextResultSet()
+	}
+	return io.EOF
+}
+
+func (r *cancelOnCloseRows) ColumnTypeDatabaseTypeName(index int) string {
+	if ct, ok := r.Rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return ct.ColumnTypeDatabaseTypeName(index)
+	}
+	return ""
+}
+
+func (r *cancelOnCloseRows) ColumnTypeScanType(index int) reflect.Type {
+	if ct, ok := r.Rows.(driver.RowsColumnTypeScanType); ok {
+		return ct.ColumnTypeScanType(index)
+	}
+	return reflect.TypeOf(new(any)).Elem()
+}
+
+func (r *cancelOnCloseRows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	if ct, ok := r.Rows.(driver.RowsColumnTypeNullable); ok {
+		return ct.ColumnTypeNullable(index)
+	}
+	return false, false
+}
+
+func (r *cancelOnCloseRows) ColumnTypeLength(index int) (length int64, ok bool) {
+	if ct, ok := r.Rows.(driver.RowsColumnTypeLength); ok {
+		return ct.ColumnTypeLength(index)
+	}
+	return 0, false
+}
+
+func (r *cancelOnCloseRows) ColumnTypePrecisionScale(index int) (precision, scale int64, ok bool) {
+	if ct, ok := r.Rows.(driver.RowsColumnTypePrecisionScale); ok {
+		return ct.ColumnTypePrecisionScale(index)
+	}
+	return 0, 0, false
+}
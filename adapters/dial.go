@@ -0,0 +1,134 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/go-sql-driver/mysql"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"golang.org/x/net/proxy"
+)
+
+// DialFunc opens the connection to the database server at the address.
+type DialFunc func(ctx context.Context, addr string) (net.Conn, error)
+
+var dialNetworkSeq atomic.Int64
+
+// registerDialFunc registers the DialFunc to the MySQL driver under a unique network name.
+func registerDialFunc(dial DialFunc) string {
+	name := "adapters-dial-" + strconv.FormatInt(dialNetworkSeq.Add(1), 10)
+	mysql.RegisterDialContext(name, mysql.DialContextFunc(dial))
+	return name
+}
+
+// WithUnixSocket makes the connections go through the unix domain socket such as the one opened by Cloud SQL Auth Proxy instead of TCP.
+func WithUnixSocket(path string) NewConfigOption {
+	return func(cfg *Config) { cfg.Socket = path }
+}
+
+// WithDialer makes the connections opened by the DialFunc such as SOCKS5Dialer and SSHTunnelDialer.
+func WithDialer(dial DialFunc) NewConfigOption {
+	return func(cfg *Config) { cfg.Dial = dial }
+}
+
+// SOCKS5Dialer returns a DialFunc that connects through the SOCKS5 proxy.
+//
+// The auth may be nil if the proxy requires no authentication.
+func SOCKS5Dialer(proxyAddr string, auth *proxy.Auth) (DialFunc, error) {
+	d, err := proxy.SOCKS5("tcp", proxyAddr, auth, proxy.Direct)
+	if err != nil {
+		return nil, fmt.Errorf("proxy.SOCKS5: %w", err)
+	}
+	cd, ok := d.(proxy.ContextDialer)
+	if !ok {
+		return nil, fmt.Errorf("the SOCKS5 dialer does not support contexts")
+	}
+	return func(ctx context.Context, addr string) (net.Conn, error) {
+		return cd.DialContext(ctx, "tcp", addr)
+	}, nil
+}
+
+// SSHTunnelDialer returns a DialFunc that connects through the SSH server as a bastion.
+//
+// The SSH connection is opened on the first dial and shared by the database connections; it is opened again if it is closed.
+func SSHTunnelDialer(sshAddr string, sshCfg *ssh.ClientConfig) DialFunc {
+	t := &sshTunnel{addr: sshAddr, cfg: sshCfg}
+	return t.dial
+}
+
+func sshClientConfigFromFiles(user, keyFile, knownHostsFile string) (*ssh.ClientConfig, error) {
+	key, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile: %w", err)
+	}
+	signer, err := ssh.ParsePrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("ssh.ParsePrivateKey: %w", err)
+	}
+	hostKeyCallback, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("knownhosts.New: %w", err)
+	}
+	return &ssh.ClientConfig{
+		User:            user,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: hostKeyCallback,
+	}, nil
+}
+
+type sshTunnel struct {
+	addr string
+	cfg  *ssh.ClientConfig
+
+	mux    sync.Mutex
+	client *ssh.Client
+}
+
+func (t *sshTunnel) dial(ctx context.Context, addr string) (net.Conn, error) {
+	client, err := t.obtainClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := client.Dial("tcp", addr)
+	if err != nil {
+		t.reset(client)
+		return nil, fmt.Errorf("ssh.Client.Dial: %w", err)
+	}
+	return conn, nil
+}
+
+func (t *sshTunnel) obtainClient(ctx context.Context) (*ssh.Client, error) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.client != nil {
+		return t.client, nil
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", t.addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the SSH server: %w", err)
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, t.addr, t.cfg)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("ssh.NewClientConn: %w", err)
+	}
+	t.client = ssh.NewClient(sshConn, chans, reqs)
+	return t.client, nil
+}
+
+// reset forgets the SSH connection so that the next dial opens a new one.
+func (t *sshTunnel) reset(client *ssh.Client) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.client == client {
+		_ = client.Close()
+		t.client = nil
+	}
+}
@@ -0,0 +1,128 @@
+package adapters
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WithExplainSampling makes the DB handle run EXPLAIN for the fraction of the SELECT statements and record the plans as events on the current span.
+//
+// Each event describes a table of the plan with its access type, the chosen key and the estimated rows to examine.
+// The statements explicitly prepared by the callers are not sampled.
+func WithExplainSampling(fraction float64) OpenDBOption {
+	return func(cfg *openDBConfig) { cfg.wrap.explainFraction = fraction }
+}
+
+func shouldExplain(fraction float64, query string) bool {
+	if fraction <= 0 || rand.Float64() >= fraction {
+		return false
+	}
+	return strings.HasPrefix(strings.ToUpper(strings.TrimSpace(query)), "SELECT")
+}
+
+// explainPlanColumns are the columns of the MySQL EXPLAIN output recorded on the span.
+var explainPlanColumns = map[string]string{
+	"table":    "db.explain.table",
+	"type":     "db.explain.access_type",
+	"key":      "db.explain.key",
+	"rows":     "db.explain.rows",
+	"filtered": "db.explain.filtered",
+	"Extra":    "db.explain.extra",
+}
+
+// recordExplain runs EXPLAIN of the query on the connection and adds the plan to the span; the failures are recorded on the span rather than returned
+// because the plan is only a diagnostic.
+func recordExplain(ctx context.Context, conn driver.Conn, query string, args []driver.NamedValue) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	rows, closeFn, err := queryExplain(ctx, conn, "EXPLAIN "+query, args)
+	if err != nil {
+		span.AddEvent("db.explain.failed", trace.WithAttributes(attribute.String("error", err.Error())))
+		return
+	}
+	defer closeFn()
+	columns := rows.Columns()
+	values := make([]driver.Value, len(columns))
+	for {
+		if err := rows.Next(values); err != nil {
+			if !errors.Is(err, io.EOF) {
+				span.AddEvent("db.explain.failed", trace.WithAttributes(attribute.String("error", err.Error())))
+			}
+			return
+		}
+		attrs := make([]attribute.KeyValue, 0, len(explainPlanColumns))
+		for i, col := range columns {
+			key, ok := explainPlanColumns[col]
+			if !ok || values[i] == nil {
+				continue
+			}
+			attrs = append(attrs, explainAttribute(key, values[i]))
+		}
+		span.AddEvent("db.explain", trace.WithAttributes(attrs...))
+	}
+}
+
+func queryExplain(ctx context.Context, conn driver.Conn, query string, args []driver.NamedValue) (driver.Rows, func(), error) {
+	if queryer, ok := conn.(driver.QueryerContext); ok {
+		rows, err := queryer.QueryContext(ctx, query, args)
+		if err == nil {
+			return rows, func() { _ = rows.Close() }, nil
+		}
+		if err != driver.ErrSkip {
+			return nil, nil, err
+		}
+	}
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+	if preparer, ok := conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var rows driver.Rows
+	if queryer, ok := stmt.(driver.StmtQueryContext); ok {
+		rows, err = queryer.QueryContext(ctx, args)
+	} else {
+		rows, err = stmt.Query(namedValuesToValues(args))
+	}
+	if err != nil {
+		_ = stmt.Close()
+		return nil, nil, err
+	}
+	return rows, func() {
+		_ = rows.Close()
+		_ = stmt.Close()
+	}, nil
+}
+
+func explainAttribute(key string, v driver.Value) attribute.KeyValue {
+	switch v := v.(type) {
+	case int64:
+		return attribute.Int64(key, v)
+	case float64:
+		return attribute.Float64(key, v)
+	case []byte:
+		if n, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return attribute.Int64(key, n)
+		}
+		return attribute.String(key, string(v))
+	default:
+		return attribute.String(key, fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,117 @@
+package adapters
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var defaultRetryPrimaryInterval = time.Second * 30
+
+// Endpoint is the address of a database server.
+type Endpoint struct {
+	Host string
+	Port int
+}
+
+func (ep Endpoint) addr() string {
+	return net.JoinHostPort(ep.Host, strconv.Itoa(ep.Port))
+}
+
+// parseEndpoints parses the comma-separated list of host[:port].
+func parseEndpoints(s string) ([]Endpoint, error) {
+	var endpoints []Endpoint
+	for _, hostPort := range strings.Split(s, ",") {
+		hostPort = strings.TrimSpace(hostPort)
+		if hostPort == "" {
+			continue
+		}
+		host, portStr, err := net.SplitHostPort(hostPort)
+		if err != nil {
+			endpoints = append(endpoints, Endpoint{Host: hostPort})
+			continue
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port of %s: %w", hostPort, err)
+		}
+		endpoints = append(endpoints, Endpoint{Host: host, Port: port})
+	}
+	return endpoints, nil
+}
+
+// WithFailoverDSNs configures the DSNs tried in order when the DSN passed to OpenDB cannot be connected to.
+func WithFailoverDSNs(dsns ...string) OpenDBOption {
+	return func(cfg *openDBConfig) { cfg.failoverDSNs = append(cfg.failoverDSNs, dsns...) }
+}
+
+// WithRetryPrimaryInterval configures how long the DB handle keeps using the failover endpoint before trying the primary again.
+func WithRetryPrimaryInterval(d time.Duration) OpenDBOption {
+	return func(cfg *openDBConfig) { cfg.retryPrimaryInterval = d }
+}
+
+// failoverConnector connects with the current connector and moves to the next one when it fails.
+//
+// The pool discards the connections to the unreachable endpoint as they fail, so the new connections go to the endpoint that works.
+type failoverConnector struct {
+	connectors           []driver.Connector
+	retryPrimaryInterval time.Duration
+
+	mux          sync.Mutex
+	current      int
+	failedOverAt time.Time
+}
+
+var _ driver.Connector = (*failoverConnector)(nil)
+
+func (c *failoverConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	start := c.startIndex()
+	var errs []error
+	for i := 0; i < len(c.connectors); i++ {
+		idx := (start + i) % len(c.connectors)
+		conn, err := c.connectors[idx].Connect(ctx)
+		if err == nil {
+			c.settle(ctx, idx)
+			return conn, nil
+		}
+		errs = append(errs, fmt.Errorf("endpoint #%d: %w", idx, err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+func (c *failoverConnector) Driver() driver.Driver {
+	return c.connectors[0].Driver()
+}
+
+func (c *failoverConnector) startIndex() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.current != 0 && time.Since(c.failedOverAt) >= c.retryPrimaryInterval {
+		c.failedOverAt = time.Now()
+		return 0
+	}
+	return c.current
+}
+
+func (c *failoverConnector) settle(ctx context.Context, idx int) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.current == idx {
+		return
+	}
+	slog.WarnContext(ctx, "database endpoint switched", slog.Int("from", c.current), slog.Int("to", idx))
+	c.current = idx
+	if idx != 0 {
+		c.failedOverAt = time.Now()
+	}
+}
@@ -0,0 +1,103 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotCheckedYet = errors.New("not checked yet")
+
+	defaultHealthCheckInterval = time.Second * 5
+	defaultHealthCheckTimeout  = time.Second * 2
+)
+
+// Pinger is implemented by the DB handles.
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// StateChangeHook is called when the health of the dependency changes; err is nil if it became healthy.
+type StateChangeHook func(ctx context.Context, name string, err error)
+
+type NewHealthCheckerOption func(hc *HealthChecker)
+
+// WithHealthCheckInterval configures the interval between the pings.
+func WithHealthCheckInterval(d time.Duration) NewHealthCheckerOption {
+	return func(hc *HealthChecker) { hc.interval = d }
+}
+
+// WithHealthCheckTimeout configures the timeout of each ping.
+func WithHealthCheckTimeout(d time.Duration) NewHealthCheckerOption {
+	return func(hc *HealthChecker) { hc.timeout = d }
+}
+
+// WithStateChangeHook registers the hook called when the health changes.
+func WithStateChangeHook(hook StateChangeHook) NewHealthCheckerOption {
+	return func(hc *HealthChecker) { hc.hooks = append(hc.hooks, hook) }
+}
+
+// NewHealthChecker returns a HealthChecker that pings the DB handle.
+func NewHealthChecker(name string, db Pinger, optFns ...NewHealthCheckerOption) *HealthChecker {
+	hc := &HealthChecker{name: name, db: db, interval: defaultHealthCheckInterval, timeout: defaultHealthCheckTimeout, lastErr: ErrNotCheckedYet}
+	for _, f := range optFns {
+		f(hc)
+	}
+	return hc
+}
+
+// HealthChecker pings the DB handle in background and keeps the latest result.
+type HealthChecker struct {
+	name     string
+	db       Pinger
+	interval time.Duration
+	timeout  time.Duration
+	hooks    []StateChangeHook
+
+	mux     sync.RWMutex
+	lastErr error
+}
+
+// Run pings the DB handle every interval until the context is done.
+func (hc *HealthChecker) Run(ctx context.Context) {
+	ticker := time.NewTicker(hc.interval)
+	defer ticker.Stop()
+	for {
+		hc.check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check returns the result of the latest ping; it has the signature of the readiness checks.
+func (hc *HealthChecker) Check(context.Context) error {
+	hc.mux.RLock()
+	defer hc.mux.RUnlock()
+	return hc.lastErr
+}
+
+func (hc *HealthChecker) check(ctx context.Context) {
+	pingCtx, cancel := context.WithTimeout(ctx, hc.timeout)
+	defer cancel()
+	err := hc.db.PingContext(pingCtx)
+	if ctx.Err() != nil {
+		return
+	}
+
+	hc.mux.Lock()
+	prev := hc.lastErr
+	hc.lastErr = err
+	hc.mux.Unlock()
+
+	if (prev == nil) == (err == nil) || (prev == ErrNotCheckedYet && err == nil) {
+		return
+	}
+	for _, hook := range hc.hooks {
+		hook(ctx, hc.name, err)
+	}
+}
@@ -0,0 +1,30 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rdsauth "github.com/aws/aws-sdk-go-v2/feature/rds/auth"
+)
+
+// IAMAuth describes the RDS IAM database authentication.
+type IAMAuth struct {
+	Region      string
+	Credentials aws.CredentialsProvider
+}
+
+// WithIAMAuth makes the connections authenticate with the RDS IAM authentication tokens instead of the password.
+//
+// A token is generated for each new connection, and TLS is required because the token is sent in cleartext.
+func WithIAMAuth(region string, creds aws.CredentialsProvider) NewConfigOption {
+	return func(cfg *Config) { cfg.IAMAuth = &IAMAuth{Region: region, Credentials: creds} }
+}
+
+func (a *IAMAuth) buildToken(ctx context.Context, ep Endpoint, user string) (string, error) {
+	token, err := rdsauth.BuildAuthToken(ctx, ep.addr(), a.Region, user, a.Credentials)
+	if err != nil {
+		return "", fmt.Errorf("rdsauth.BuildAuthToken: %w", err)
+	}
+	return token, nil
+}
@@ -0,0 +1,95 @@
+package adapters
+
+import (
+	"regexp"
+	"strings"
+)
+
+var inListPattern = regexp.MustCompile(`(?i)\bIN\s*\(\s*\?(?:\s*,\s*\?)*\s*\)`)
+
+// NormalizeQuery returns the fingerprint of the query that is safe to record.
+//
+// The string and numeric literals are replaced with placeholders, the comments are stripped, the whitespaces are collapsed,
+// and the lists of placeholders in IN clauses are collapsed into IN (...), so the semantically identical queries share the same fingerprint.
+func NormalizeQuery(query string) string {
+	var b strings.Builder
+	b.Grow(len(query))
+	space := false
+	writeSpace := func() {
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+	}
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			space = true
+		case c == '-' && i+1 < len(query) && query[i+1] == '-', c == '#':
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+			space = true
+		case c == '/' && i+1 < len(query) && query[i+1] == '*':
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				i = len(query)
+			} else {
+				i += end + 3
+			}
+			space = true
+		case c == '\'' || c == '"':
+			i = skipQuoted(query, i, c)
+			writeSpace()
+			b.WriteByte('?')
+		case c == '`':
+			end := strings.IndexByte(query[i+1:], '`')
+			writeSpace()
+			if end < 0 {
+				b.WriteString(query[i:])
+				i = len(query)
+			} else {
+				b.WriteString(query[i : i+end+2])
+				i += end + 1
+			}
+		case isDigit(c) && !prevIsIdent(query, i):
+			for i+1 < len(query) && (isIdentByte(query[i+1]) || query[i+1] == '.') {
+				i++
+			}
+			writeSpace()
+			b.WriteByte('?')
+		default:
+			writeSpace()
+			b.WriteByte(c)
+		}
+	}
+	return inListPattern.ReplaceAllString(b.String(), "IN (...)")
+}
+
+// skipQuoted returns the index of the closing quote of the literal starting at the index.
+func skipQuoted(query string, start int, quote byte) int {
+	for i := start + 1; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			i++
+		case quote:
+			if i+1 < len(query) && query[i+1] == quote {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return len(query)
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+func isIdentByte(c byte) bool {
+	return isDigit(c) || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || c == '_' || c == '$'
+}
+
+func prevIsIdent(query string, i int) bool {
+	return i > 0 && isIdentByte(query[i-1])
+}
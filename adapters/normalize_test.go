@@ -0,0 +1,29 @@
+package adapters
+
+import "testing"
+
+func TestNormalizeQuery(t *testing.T) {
+	testCases := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{name: "string literal", query: "select * from users where name = 'alice'", want: "select * from users where name = ?"},
+		{name: "escaped quotes", query: `select * from users where name = 'it''s' or name = "a\"b"`, want: "select * from users where name = ? or name = ?"},
+		{name: "numeric literal", query: "select * from users limit 10 offset 2.5", want: "select * from users limit ? offset ?"},
+		{name: "digits in identifiers", query: "select * from tenant_1.users2 where id = 3", want: "select * from tenant_1.users2 where id = ?"},
+		{name: "quoted identifier", query: "select * from `tenant_1`.`users` where `name` = 'alice'", want: "select * from `tenant_1`.`users` where `name` = ?"},
+		{name: "whitespaces", query: "select *\n\tfrom   users\r\n", want: "select * from users"},
+		{name: "comments", query: "select * -- trailing\nfrom users # hash\n/* block */ where id = 1", want: "select * from users where id = ?"},
+		{name: "in list", query: "select * from users where id in (1, 2, 3)", want: "select * from users where id IN (...)"},
+		{name: "in list of placeholders", query: "select * from users where id IN (?,?)", want: "select * from users where id IN (...)"},
+		{name: "unterminated literal", query: "select 'abc", want: "select ?"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := NormalizeQuery(tc.query); got != tc.want {
+				t.Errorf("NormalizeQuery(%q) = %q, want %q", tc.query, got, tc.want)
+			}
+		})
+	}
+}
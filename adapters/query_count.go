@@ -0,0 +1,37 @@
+package adapters
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// WithQueryCounting makes the DB handle count the statements run with the contexts returned by WithQueryCounter.
+func WithQueryCounting() OpenDBOption {
+	return func(cfg *openDBConfig) { cfg.wrap.queryCounting = true }
+}
+
+type queryCounterCtxKey struct{}
+
+// WithQueryCounter returns a context that counts the statements run with it and its descendants, such as the statements run while serving a request.
+//
+// The statements are counted only by the DB handle opened with WithQueryCounting.
+func WithQueryCounter(ctx context.Context) context.Context {
+	return context.WithValue(ctx, queryCounterCtxKey{}, new(atomic.Int64))
+}
+
+// QueryCount returns the number of the statements counted within the context.
+//
+// If the context has no counter, the second return value is false.
+func QueryCount(ctx context.Context) (int64, bool) {
+	counter, ok := ctx.Value(queryCounterCtxKey{}).(*atomic.Int64)
+	if !ok {
+		return 0, false
+	}
+	return counter.Load(), true
+}
+
+func countQuery(ctx context.Context) {
+	if counter, ok := ctx.Value(queryCounterCtxKey{}).(*atomic.Int64); ok {
+		counter.Add(1)
+	}
+}
@@ -0,0 +1,79 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+var (
+	defaultRetryMaxAttempts = 3
+	defaultRetryBaseDelay   = time.Millisecond * 20
+	defaultRetryMaxDelay    = time.Second
+)
+
+const (
+	mysqlErrLockWaitTimeout = 1205
+	mysqlErrLockDeadlock    = 1213
+)
+
+type retryConfig struct {
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+}
+
+type RetryOption func(cfg *retryConfig)
+
+// WithMaxAttempts configures the number of the attempts including the first call.
+func WithMaxAttempts(n int) RetryOption {
+	return func(cfg *retryConfig) { cfg.maxAttempts = n }
+}
+
+// WithBackoff configures the delay before the first retry and the upper bound of the delays.
+func WithBackoff(base, max time.Duration) RetryOption {
+	return func(cfg *retryConfig) {
+		cfg.baseDelay = base
+		cfg.maxDelay = max
+	}
+}
+
+// IsTransientError reports whether the error is likely to succeed on retry: MySQL deadlocks and lock wait timeouts.
+//
+// Broken connections are not counted because the repos keep using the connection bound to the tenant for the request.
+func IsTransientError(err error) bool {
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		return myErr.Number == mysqlErrLockDeadlock || myErr.Number == mysqlErrLockWaitTimeout
+	}
+	return false
+}
+
+// Retry calls the function until it succeeds, returns a non-transient error, or the attempts run out.
+//
+// The delays between the attempts grow exponentially with full jitter, and the retry stops as soon as the context is done.
+// The function must be safe to call again; do not wrap the calls within a transaction because a deadlock rolls back the whole transaction.
+func Retry(ctx context.Context, fn func(ctx context.Context) error, optFns ...RetryOption) error {
+	cfg := &retryConfig{maxAttempts: defaultRetryMaxAttempts, baseDelay: defaultRetryBaseDelay, maxDelay: defaultRetryMaxDelay}
+	for _, f := range optFns {
+		f(cfg)
+	}
+	delay := cfg.baseDelay
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || attempt >= cfg.maxAttempts || !IsTransientError(err) {
+			return err
+		}
+		timer := time.NewTimer(time.Duration(rand.Int63n(int64(delay) + 1)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+		delay = min(delay*2, cfg.maxDelay)
+	}
+}
@@ -0,0 +1,76 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestIsTransientError(t *testing.T) {
+	testCases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "deadlock", err: &mysql.MySQLError{Number: mysqlErrLockDeadlock}, want: true},
+		{name: "lock wait timeout", err: fmt.Errorf("ExecContext: %w", &mysql.MySQLError{Number: mysqlErrLockWaitTimeout}), want: true},
+		{name: "duplicate entry", err: &mysql.MySQLError{Number: 1062}, want: false},
+		{name: "bad connection", err: mysql.ErrInvalidConn, want: false},
+		{name: "nil", err: nil, want: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := IsTransientError(tc.err); got != tc.want {
+				t.Errorf("IsTransientError() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRetry(t *testing.T) {
+	errTransient := &mysql.MySQLError{Number: mysqlErrLockDeadlock}
+	errPermanent := errors.New("permanent")
+	testCases := []struct {
+		name         string
+		errs         []error
+		cancel       bool
+		wantErr      error
+		wantAttempts int
+	}{
+		{name: "succeeded", errs: []error{nil}, wantAttempts: 1},
+		{name: "succeeded on retry", errs: []error{errTransient, errTransient, nil}, wantAttempts: 3},
+		{name: "permanent error", errs: []error{errPermanent}, wantErr: errPermanent, wantAttempts: 1},
+		{name: "attempts run out", errs: []error{errTransient, errTransient, errTransient, nil}, wantErr: errTransient, wantAttempts: 3},
+		{name: "canceled", errs: []error{errTransient, nil}, cancel: true, wantErr: context.Canceled, wantAttempts: 1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			backoff := time.Millisecond
+			if tc.cancel {
+				// the delay must outlast the cancellation
+				backoff = time.Hour
+			}
+			attempts := 0
+			err := Retry(ctx, func(context.Context) error {
+				err := tc.errs[attempts]
+				attempts++
+				if tc.cancel {
+					cancel()
+				}
+				return err
+			}, WithMaxAttempts(3), WithBackoff(backoff, backoff*2))
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("error = %v, want %v", err, tc.wantErr)
+			}
+			if attempts != tc.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tc.wantAttempts)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+// SecretsManagerAPI is a subset of the Secrets Manager client used to fetch the password.
+type SecretsManagerAPI interface {
+	GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+}
+
+// PasswordFromSecretsManager returns a PasswordSource that fetches the secret from AWS Secrets Manager.
+//
+// If the key is empty, the whole secret string is the password; otherwise the secret string is parsed as a JSON object and the value of the key is the password,
+// which matches the secrets that RDS manages.
+func PasswordFromSecretsManager(client SecretsManagerAPI, secretID, key string) PasswordSource {
+	return func(ctx context.Context) (string, error) {
+		out, err := client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(secretID)})
+		if err != nil {
+			return "", fmt.Errorf("secretsmanager.GetSecretValue: %w", err)
+		}
+		secret := aws.ToString(out.SecretString)
+		if key == "" {
+			return secret, nil
+		}
+		var fields map[string]any
+		if err := json.Unmarshal([]byte(secret), &fields); err != nil {
+			return "", fmt.Errorf("failed to decode the secret %s: %w", secretID, err)
+		}
+		password, ok := fields[key].(string)
+		if !ok {
+			return "", fmt.Errorf("the secret %s has no string field %q", secretID, key)
+		}
+		return password, nil
+	}
+}
+
+// PasswordFromVault returns a PasswordSource that reads the field of the secret stored in the Vault KV version 2 secrets engine.
+//
+// The path includes the mount, such as "secret/data/app/db".
+func PasswordFromVault(addr, token, path, field string) PasswordSource {
+	return func(ctx context.Context) (string, error) {
+		u, err := url.JoinPath(addr, "v1", strings.TrimPrefix(path, "/"))
+		if err != nil {
+			return "", fmt.Errorf("url.JoinPath: %w", err)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return "", fmt.Errorf("http.NewRequest: %w", err)
+		}
+		req.Header.Set("X-Vault-Token", token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return "", fmt.Errorf("http.Client.Do: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("vault responded with status %d for %s", resp.StatusCode, path)
+		}
+		var body struct {
+			Data struct {
+				Data map[string]any `json:"data"`
+			} `json:"data"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			return "", fmt.Errorf("failed to decode the vault response: %w", err)
+		}
+		password, ok := body.Data.Data[field].(string)
+		if !ok {
+			return "", fmt.Errorf("the vault secret %s has no string field %q", path, field)
+		}
+		return password, nil
+	}
+}
+
+// CachedPassword returns a PasswordSource that reuses the password fetched from the source until the refresh interval passes.
+//
+// If the refresh fails, the last password is kept so that a temporary outage of the secret store does not stop new connections.
+func CachedPassword(src PasswordSource, refreshInterval time.Duration) PasswordSource {
+	var (
+		mux       sync.Mutex
+		password  string
+		fetchedAt time.Time
+	)
+	return func(ctx context.Context) (string, error) {
+		mux.Lock()
+		defer mux.Unlock()
+		if !fetchedAt.IsZero() && time.Since(fetchedAt) < refreshInterval {
+			return password, nil
+		}
+		p, err := src(ctx)
+		if err != nil {
+			if fetchedAt.IsZero() {
+				return "", err
+			}
+			slog.WarnContext(ctx, "failed to refresh db password; keep using the last one", slog.String("error", err.Error()))
+			return password, nil
+		}
+		password, fetchedAt = p, time.Now()
+		return password, nil
+	}
+}
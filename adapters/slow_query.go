@@ -0,0 +1,36 @@
+package adapters
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/aereal/nagaya"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WithSlowQueryThreshold makes the DB handle report the statements that take the threshold or longer with a warning log and an event on the current span.
+//
+// The reports carry the query normalized by NormalizeQuery, the tenant and the duration.
+// The duration of a query is measured until the rows become readable, so it excludes the time to iterate them.
+func WithSlowQueryThreshold(threshold time.Duration) OpenDBOption {
+	return func(cfg *openDBConfig) { cfg.wrap.slowQueryThreshold = threshold }
+}
+
+func reportSlowQuery(ctx context.Context, threshold time.Duration, query string, startedAt time.Time) {
+	elapsed := time.Since(startedAt)
+	if threshold <= 0 || elapsed < threshold {
+		return
+	}
+	normalized := NormalizeQuery(query)
+	tenant, _ := nagaya.TenantFromContext(ctx)
+	slog.WarnContext(ctx, "slow query",
+		slog.String("db.statement", normalized),
+		slog.String("tenant", string(tenant)),
+		slog.Duration("duration", elapsed))
+	trace.SpanFromContext(ctx).AddEvent("slow query", trace.WithAttributes(
+		attribute.String("db.statement", normalized),
+		attribute.String("tenant", string(tenant)),
+		attribute.Int64("duration_ms", elapsed.Milliseconds())))
+}
@@ -0,0 +1,46 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/aereal/nagaya"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type routeCtxKey struct{}
+
+// WithRoute returns a new context that has the route pattern of the request, which WithSQLComment puts into the statements and the DB spans carry as http.route.
+func WithRoute(ctx context.Context, route string) context.Context {
+	return context.WithValue(ctx, routeCtxKey{}, route)
+}
+
+// WithSQLComment makes the DB handle append a sqlcommenter-style comment that has the route, the tenant and the traceparent to each statement,
+// so that the slow query log and the performance schema can be correlated with the requests and the tenants.
+func WithSQLComment() OpenDBOption {
+	return func(cfg *openDBConfig) { cfg.wrap.sqlComment = true }
+}
+
+func appendSQLComment(ctx context.Context, query string) string {
+	pairs := make([]string, 0, 3)
+	if route, ok := ctx.Value(routeCtxKey{}).(string); ok && route != "" {
+		pairs = append(pairs, sqlCommentPair("route", route))
+	}
+	if tenant, ok := nagaya.TenantFromContext(ctx); ok && tenant != "" {
+		pairs = append(pairs, sqlCommentPair("tenant", string(tenant)))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		pairs = append(pairs, sqlCommentPair("traceparent", fmt.Sprintf("00-%s-%s-%s", sc.TraceID(), sc.SpanID(), sc.TraceFlags())))
+	}
+	if len(pairs) == 0 {
+		return query
+	}
+	return query + " /*" + strings.Join(pairs, ",") + "*/"
+}
+
+// sqlCommentPair encodes the value so that it can neither close the comment nor the quotes.
+func sqlCommentPair(key, value string) string {
+	return key + "='" + url.QueryEscape(value) + "'"
+}
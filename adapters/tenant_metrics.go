@@ -0,0 +1,37 @@
+package adapters
+
+import (
+	"context"
+	"enjoymultitenancy/telemetry"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+// WithTenantGuard makes the DB handle record the durations of the statements as db.query.duration broken down by the tenant,
+// which the guard buckets to bound the cardinality.
+//
+// The metrics recorded by otelsql carry no tenant since their attributes are fixed when the handle is opened.
+func WithTenantGuard(g *telemetry.TenantGuard) OpenDBOption {
+	return func(cfg *openDBConfig) { cfg.wrap.tenantGuard = g }
+}
+
+func newQueryDurationHistogram(meter metric.Meter) metric.Float64Histogram {
+	h, err := meter.Float64Histogram("db.query.duration",
+		metric.WithDescription("The time taken by the statements, until the rows become readable."),
+		metric.WithUnit("s"))
+	if err != nil {
+		otel.Handle(err)
+		return noop.Float64Histogram{}
+	}
+	return h
+}
+
+func (cfg *wrapConfig) recordQueryDuration(ctx context.Context, startedAt time.Time) {
+	if cfg.tenantGuard == nil {
+		return
+	}
+	cfg.queryDuration.Record(ctx, time.Since(startedAt).Seconds(), metric.WithAttributes(cfg.tenantGuard.Attribute(ctx)))
+}
@@ -0,0 +1,55 @@
+package adapters
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+var ErrInvalidCABundle = errors.New("no certificates found in the CA bundle")
+
+// TLSConfig describes how to encrypt and verify the connections to the database.
+type TLSConfig struct {
+	// CAFile is the path to the PEM-encoded CA bundle that verifies the server; the system roots are used if empty.
+	CAFile string
+	// CertFile and KeyFile are the paths to the client certificate and its key; the client certificate is not sent if empty.
+	CertFile string
+	KeyFile  string
+	// ServerName overrides the host name verified against the server certificate.
+	ServerName string
+}
+
+// WithTLS makes the connections use TLS.
+//
+// The files are read each time a new connection is made, so renewed certificates are picked up without reopening the pool.
+func WithTLS(tc TLSConfig) NewConfigOption {
+	return func(cfg *Config) { cfg.TLS = &tc }
+}
+
+func (tc *TLSConfig) build(host string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: host}
+	if tc.ServerName != "" {
+		cfg.ServerName = tc.ServerName
+	}
+	if tc.CAFile != "" {
+		pem, err := os.ReadFile(tc.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("os.ReadFile: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, ErrInvalidCABundle
+		}
+		cfg.RootCAs = pool
+	}
+	if tc.CertFile != "" || tc.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(tc.CertFile, tc.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls.LoadX509KeyPair: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,109 @@
+package adapters
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	defaultWarmUpAttempts = 5
+	defaultWarmUpDelay    = time.Millisecond * 500
+	defaultWarmUpMaxDelay = time.Second * 5
+)
+
+// UnreachableError is an error type represents the database did not become reachable within the attempts at startup.
+type UnreachableError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *UnreachableError) Error() string {
+	return fmt.Sprintf("database is unreachable after %d attempts: %s", e.Attempts, e.Err)
+}
+
+func (e *UnreachableError) Unwrap() error {
+	return e.Err
+}
+
+type warmUpConfig struct {
+	attempts    int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	connections int
+}
+
+type WarmUpOption func(cfg *warmUpConfig)
+
+// WithWarmUpAttempts configures the number of the pings tried before giving up.
+func WithWarmUpAttempts(n int) WarmUpOption {
+	return func(cfg *warmUpConfig) { cfg.attempts = n }
+}
+
+// WithWarmUpBackoff configures the delay before the first retried ping and the upper bound of the delays.
+func WithWarmUpBackoff(base, max time.Duration) WarmUpOption {
+	return func(cfg *warmUpConfig) {
+		cfg.baseDelay = base
+		cfg.maxDelay = max
+	}
+}
+
+// WithWarmConnections configures the number of the connections opened in advance and left idle in the pool.
+//
+// The pool keeps at most the number set by sql.DB.SetMaxIdleConns, 2 by default, of them.
+func WithWarmConnections(n int) WarmUpOption {
+	return func(cfg *warmUpConfig) { cfg.connections = n }
+}
+
+// WarmUp pings the database until it responds and then opens the warm connections.
+//
+// It returns UnreachableError if the database never responds so that the server fails fast at startup.
+func WarmUp(ctx context.Context, db *sqlx.DB, optFns ...WarmUpOption) error {
+	cfg := &warmUpConfig{attempts: defaultWarmUpAttempts, baseDelay: defaultWarmUpDelay, maxDelay: defaultWarmUpMaxDelay}
+	for _, f := range optFns {
+		f(cfg)
+	}
+	if err := pingWithRetry(ctx, db, cfg); err != nil {
+		return err
+	}
+	conns := make([]*sql.Conn, 0, cfg.connections)
+	defer func() {
+		for _, conn := range conns {
+			_ = conn.Close()
+		}
+	}()
+	for i := 0; i < cfg.connections; i++ {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to open warm connection: %w", err)
+		}
+		conns = append(conns, conn)
+	}
+	return nil
+}
+
+func pingWithRetry(ctx context.Context, db *sqlx.DB, cfg *warmUpConfig) error {
+	delay := cfg.baseDelay
+	for attempt := 1; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		if attempt >= cfg.attempts {
+			return &UnreachableError{Attempts: attempt, Err: err}
+		}
+		timer := time.NewTimer(time.Duration(rand.Int63n(int64(delay) + 1)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return &UnreachableError{Attempts: attempt, Err: errors.Join(err, ctx.Err())}
+		case <-timer.C:
+		}
+		delay = min(delay*2, cfg.maxDelay)
+	}
+}
@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrKeyNotFound is an error represents no key in the key set matches the key ID.
+	ErrKeyNotFound = errors.New("key not found in JWKS")
+
+	defaultJWKSCacheTTL        = time.Hour
+	defaultJWKSMinRefreshDelay = time.Minute
+)
+
+// JWKS is a JSON Web Key Set fetched from the remote endpoint.
+//
+// The fetched keys are cached for the TTL and refetched on the lookup of unknown key ID, so the rotated keys are picked up without restarting.
+type JWKS struct {
+	url             string
+	client          *http.Client
+	ttl             time.Duration
+	minRefreshDelay time.Duration
+
+	mux       sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+type NewJWKSOption func(j *JWKS)
+
+// WithHTTPClient configures the HTTP client used to fetch the key set.
+func WithHTTPClient(c *http.Client) NewJWKSOption {
+	return func(j *JWKS) { j.client = c }
+}
+
+// WithCacheTTL configures the duration the fetched key set is cached.
+func WithCacheTTL(ttl time.Duration) NewJWKSOption {
+	return func(j *JWKS) { j.ttl = ttl }
+}
+
+// WithMinRefreshDelay configures the minimum delay between refetches triggered by unknown key IDs.
+func WithMinRefreshDelay(d time.Duration) NewJWKSOption {
+	return func(j *JWKS) { j.minRefreshDelay = d }
+}
+
+func NewJWKS(url string, optFns ...NewJWKSOption) *JWKS {
+	j := &JWKS{url: url}
+	for _, f := range optFns {
+		f(j)
+	}
+	if j.client == nil {
+		j.client = http.DefaultClient
+	}
+	if j.ttl == 0 {
+		j.ttl = defaultJWKSCacheTTL
+	}
+	if j.minRefreshDelay == 0 {
+		j.minRefreshDelay = defaultJWKSMinRefreshDelay
+	}
+	return j
+}
+
+// Key returns the public key identified by the key ID.
+func (j *JWKS) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	now := time.Now()
+	if j.keys == nil || now.Sub(j.fetchedAt) > j.ttl {
+		if err := j.refresh(ctx, now); err != nil {
+			return nil, err
+		}
+	}
+	if key, ok := j.keys[kid]; ok {
+		return key, nil
+	}
+	if now.Sub(j.fetchedAt) < j.minRefreshDelay {
+		return nil, ErrKeyNotFound
+	}
+	if err := j.refresh(ctx, now); err != nil {
+		return nil, err
+	}
+	if key, ok := j.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, ErrKeyNotFound
+}
+
+func (j *JWKS) refresh(ctx context.Context, now time.Time) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.url, nil)
+	if err != nil {
+		return fmt.Errorf("http.NewRequest: %w", err)
+	}
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch JWKS: unexpected status %d", resp.StatusCode)
+	}
+	var set jwkSet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+	j.keys = keys
+	j.fetchedAt = now
+	return nil
+}
+
+type jwkSet struct {
+	Keys []jwk `json:"keys"`
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve: %s", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("base64.DecodeString: %w", err)
+	}
+	return new(big.Int).SetBytes(b), nil
+}
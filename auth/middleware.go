@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware returns a middleware function that authenticates the request with the bearer token in the Authorization header.
+//
+// The request without a valid token is rejected with 401; otherwise the principal is bound to the request context and can be retrieved via PrincipalFromContext.
+func Middleware(v *Verifier) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			token, ok := bearerToken(r)
+			if !ok {
+				unauthorized(w, "bearer token required")
+				return
+			}
+			principal, err := v.Verify(ctx, token)
+			if err != nil {
+				slog.InfoContext(ctx, "failed to verify token", slog.String("error", err.Error()))
+				unauthorized(w, "invalid token")
+				return
+			}
+			trace.SpanFromContext(ctx).SetAttributes(semconv.EnduserID(principal.Subject))
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(ctx, principal)))
+		})
+	}
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "bearer") || token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+func unauthorized(w http.ResponseWriter, msg string) {
+	w.Header().Set("content-type", "application/json")
+	w.Header().Set("www-authenticate", `Bearer error="invalid_token"`)
+	w.WriteHeader(http.StatusUnauthorized)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
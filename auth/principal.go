@@ -0,0 +1,28 @@
+package auth
+
+import "context"
+
+// Principal is an authenticated subject of the request.
+type Principal struct {
+	// Subject is an identifier of the authenticated subject, taken from the sub claim.
+	Subject string
+	// Issuer is an issuer of the credential, taken from the iss claim.
+	Issuer string
+	// Claims are all claims of the verified token.
+	Claims map[string]any
+}
+
+type principalCtxKey struct{}
+
+// WithPrincipal returns new context that contains given principal.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalCtxKey{}, p)
+}
+
+// PrincipalFromContext extracts a principal in the context.
+//
+// If no principal is bound for the context, the second return value is a false.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalCtxKey{}).(*Principal)
+	return p, ok && p != nil
+}
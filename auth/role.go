@@ -0,0 +1,39 @@
+package auth
+
+import "fmt"
+
+// Role is a set of permissions granted to a member of the tenant.
+type Role string
+
+const (
+	// RoleViewer can read the resources of the tenant.
+	RoleViewer Role = "viewer"
+	// RoleEditor can read and modify the resources of the tenant.
+	RoleEditor Role = "editor"
+	// RoleOwner can do everything on the tenant including the management of the members.
+	RoleOwner Role = "owner"
+)
+
+var roleRanks = map[Role]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleOwner:  3,
+}
+
+// ParseRole returns a Role represented by the string.
+func ParseRole(s string) (Role, error) {
+	r := Role(s)
+	if _, ok := roleRanks[r]; !ok {
+		return "", fmt.Errorf("unknown role: %q", s)
+	}
+	return r, nil
+}
+
+// Satisfies returns whether the role has all permissions granted to the required role.
+func (r Role) Satisfies(required Role) bool {
+	rank, ok := roleRanks[r]
+	if !ok {
+		return false
+	}
+	return rank >= roleRanks[required]
+}
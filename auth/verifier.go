@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	// ErrKeyIDRequired is an error represents the token has no kid header.
+	ErrKeyIDRequired = errors.New("kid header is required")
+	// ErrSubjectRequired is an error represents the token has no sub claim.
+	ErrSubjectRequired = errors.New("sub claim is required")
+
+	defaultValidMethods = []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}
+)
+
+type NewVerifierOption func(v *Verifier)
+
+// WithIssuer makes the verifier require the iss claim to be equal to given issuer.
+func WithIssuer(iss string) NewVerifierOption {
+	return func(v *Verifier) { v.issuer = iss }
+}
+
+// WithAudience makes the verifier require the aud claim to contain given audience.
+func WithAudience(aud string) NewVerifierOption {
+	return func(v *Verifier) { v.audience = aud }
+}
+
+// WithLeeway configures the allowed clock skew on validating time-based claims.
+func WithLeeway(leeway time.Duration) NewVerifierOption {
+	return func(v *Verifier) { v.leeway = leeway }
+}
+
+// NewVerifier returns a Verifier that verifies tokens signed with the keys in given key set.
+func NewVerifier(keys *JWKS, optFns ...NewVerifierOption) *Verifier {
+	v := &Verifier{keys: keys}
+	for _, f := range optFns {
+		f(v)
+	}
+	return v
+}
+
+// Verifier verifies JWTs and builds principals from them.
+type Verifier struct {
+	keys     *JWKS
+	issuer   string
+	audience string
+	leeway   time.Duration
+}
+
+// Verify verifies the signature and the claims of the token and returns a principal represented by the token.
+func (v *Verifier) Verify(ctx context.Context, token string) (*Principal, error) {
+	parserOpts := []jwt.ParserOption{
+		jwt.WithValidMethods(defaultValidMethods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(v.leeway),
+	}
+	if v.issuer != "" {
+		parserOpts = append(parserOpts, jwt.WithIssuer(v.issuer))
+	}
+	if v.audience != "" {
+		parserOpts = append(parserOpts, jwt.WithAudience(v.audience))
+	}
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(token, claims, v.keyFunc(ctx), parserOpts...); err != nil {
+		return nil, fmt.Errorf("jwt.Parse: %w", err)
+	}
+	sub, _ := claims.GetSubject()
+	if sub == "" {
+		return nil, ErrSubjectRequired
+	}
+	iss, _ := claims.GetIssuer()
+	return &Principal{Subject: sub, Issuer: iss, Claims: claims}, nil
+}
+
+func (v *Verifier) keyFunc(ctx context.Context) jwt.Keyfunc {
+	return func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, ErrKeyIDRequired
+		}
+		return v.keys.Key(ctx, kid)
+	}
+}
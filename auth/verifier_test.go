@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestVerifier_Verify(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(jwkSet{Keys: []jwk{{
+			Kty: "RSA",
+			Kid: "key_1",
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	}))
+	t.Cleanup(srv.Close)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	validClaims := jwt.MapClaims{"sub": "user_1", "iss": "https://issuer.example", "exp": now.Add(time.Hour).Unix()}
+	signRS256 := func(kid string, claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		s, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	testCases := []struct {
+		name        string
+		token       string
+		wantErr     error
+		wantSubject string
+	}{
+		{name: "ok", token: signRS256("key_1", validClaims), wantSubject: "user_1"},
+		{
+			name: "bad alg/HS256",
+			token: func() string {
+				s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims).SignedString([]byte("secret"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return s
+			}(),
+			wantErr: jwt.ErrTokenSignatureInvalid,
+		},
+		{
+			name: "bad alg/none",
+			token: func() string {
+				s, err := jwt.NewWithClaims(jwt.SigningMethodNone, validClaims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return s
+			}(),
+			wantErr: jwt.ErrTokenSignatureInvalid,
+		},
+		{
+			name: "signed with other key",
+			token: func() string {
+				token := jwt.NewWithClaims(jwt.SigningMethodES256, validClaims)
+				token.Header["kid"] = "key_1"
+				s, err := token.SignedString(ecKey)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return s
+			}(),
+			wantErr: jwt.ErrTokenSignatureInvalid,
+		},
+		{name: "missing kid", token: signRS256("", validClaims), wantErr: ErrKeyIDRequired},
+		{name: "unknown kid", token: signRS256("key_2", validClaims), wantErr: ErrKeyNotFound},
+		{name: "missing sub", token: signRS256("key_1", jwt.MapClaims{"exp": now.Add(time.Hour).Unix()}), wantErr: ErrSubjectRequired},
+		{name: "missing exp", token: signRS256("key_1", jwt.MapClaims{"sub": "user_1"}), wantErr: jwt.ErrTokenRequiredClaimMissing},
+		{name: "expired", token: signRS256("key_1", jwt.MapClaims{"sub": "user_1", "exp": now.Add(-time.Minute).Unix()}), wantErr: jwt.ErrTokenExpired},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			v := NewVerifier(NewJWKS(srv.URL))
+			principal, err := v.Verify(context.Background(), tc.token)
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if principal.Subject != tc.wantSubject {
+				t.Errorf("Subject = %q, want %q", principal.Subject, tc.wantSubject)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+// Package buildinfo provides the version of the running binary.
+//
+// The values are embedded by the linker flags such as:
+//
+//	go build -ldflags "-X enjoymultitenancy/buildinfo.version=v1.2.3 -X enjoymultitenancy/buildinfo.commit=$(git rev-parse HEAD) -X enjoymultitenancy/buildinfo.buildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/server
+//
+// The version and the commit not embedded are filled by the build info the Go toolchain records.
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+var (
+	version   string
+	commit    string
+	buildTime string
+)
+
+// Info is the version of the running binary.
+type Info struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+	// CommitTime is the time of the commit recorded by the Go toolchain.
+	CommitTime string `json:"commit_time,omitempty"`
+	// BuildTime is the time the binary was built; it is empty unless it is embedded by the linker flags.
+	BuildTime string `json:"build_time,omitempty"`
+	GoVersion string `json:"go_version"`
+	// Modified reports whether the binary was built from the working tree with the uncommitted changes.
+	Modified bool `json:"modified"`
+}
+
+var get = sync.OnceValue(func() Info {
+	info := Info{Version: version, Commit: commit, BuildTime: buildTime, GoVersion: runtime.Version()}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && bi.Main.Version != "(devel)" {
+			info.Version = bi.Main.Version
+		}
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				info.CommitTime = setting.Value
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+	if info.Version == "" {
+		info.Version = "devel"
+	}
+	return info
+})
+
+// Get returns the version of the running binary.
+func Get() Info {
+	return get()
+}
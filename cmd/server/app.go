@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"enjoymultitenancy/adapters"
+	"enjoymultitenancy/buildinfo"
+	"enjoymultitenancy/logging"
+	"enjoymultitenancy/repos"
+	"enjoymultitenancy/telemetry"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aereal/nagaya"
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/contrib/instrumentation/host"
+	"go.opentelemetry.io/contrib/instrumentation/runtime"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+)
+
+// app is the wiring shared by the commands: the logging, the OpenTelemetry instrumentation and the DB handle configured by the env vars.
+type app struct {
+	logLevel        *slog.LevelVar
+	initialLogLevel slog.Level
+	tenantGuard     *telemetry.TenantGuard
+	meterProvider   *sdkmetric.MeterProvider
+	metricsReader   *sdkmetric.ManualReader
+	queryBudget     int
+	db              *sqlx.DB
+	ngy             *nagaya.Nagaya[*sqlx.DB, *sqlx.Conn]
+	tenantRepo      *repos.TenantRepo
+	closers         []func(ctx context.Context)
+}
+
+func newApp(ctx context.Context) (_ *app, err error) {
+	a := &app{logLevel: new(slog.LevelVar)}
+	defer func() {
+		if err != nil {
+			a.close(ctx)
+		}
+	}()
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		if err := a.logLevel.UnmarshalText([]byte(v)); err != nil {
+			return nil, fmt.Errorf("invalid LOG_LEVEL: %w", err)
+		}
+	}
+	a.initialLogLevel = a.logLevel.Level()
+	logOpts := []logging.InitOption{logging.WithLevel(a.logLevel)}
+	if burst, err := strconv.Atoi(os.Getenv("LOG_SAMPLING_BURST")); err == nil && burst > 0 {
+		interval, err := time.ParseDuration(os.Getenv("LOG_SAMPLING_INTERVAL"))
+		if err != nil || interval <= 0 {
+			interval = time.Second
+		}
+		logOpts = append(logOpts, logging.WithSampling(burst, interval))
+	}
+	logging.Init(logOpts...)
+	if limit, err := strconv.Atoi(os.Getenv("TENANT_METRICS_LIMIT")); err == nil && limit > 0 {
+		var guardOpts []telemetry.NewTenantGuardOption
+		if tenants := os.Getenv("TENANT_METRICS_TENANTS"); tenants != "" {
+			guardOpts = append(guardOpts, telemetry.WithTenants(strings.Split(tenants, ",")...))
+		}
+		a.tenantGuard = telemetry.NewTenantGuard(limit, guardOpts...)
+	}
+	a.metricsReader = sdkmetric.NewManualReader()
+	tp, mp, lp, err := setupOtel(ctx, a.tenantGuard != nil, a.metricsReader)
+	if err != nil {
+		return nil, fmt.Errorf("setupOtel: %w", err)
+	}
+	a.meterProvider = mp
+	a.closers = append(a.closers, func(ctx context.Context) {
+		ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+		defer cancel()
+		if tp != nil {
+			if err := tp.Shutdown(ctx); err != nil {
+				slog.WarnContext(ctx, "failed to shutdown TracerProvider", slog.String("error", err.Error()))
+			}
+		}
+		if err := mp.Shutdown(ctx); err != nil {
+			slog.WarnContext(ctx, "failed to shutdown MeterProvider", slog.String("error", err.Error()))
+		}
+		if lp != nil {
+			if err := lp.Shutdown(ctx); err != nil {
+				slog.WarnContext(ctx, "failed to shutdown LoggerProvider", slog.String("error", err.Error()))
+			}
+		}
+	})
+	if tp != nil {
+		otel.SetTracerProvider(tp)
+	} else {
+		otel.SetTracerProvider(tracenoop.NewTracerProvider())
+	}
+	otel.SetMeterProvider(mp)
+	if lp != nil {
+		logging.Init(append(logOpts, logging.WithOTelLoggerProvider(lp))...)
+	}
+	var dbOpts []adapters.OpenDBOption
+	if os.Getenv("DB_STATEMENT_FINGERPRINT") == "true" {
+		dbOpts = append(dbOpts, adapters.WithStatementFingerprint())
+	}
+	if threshold, err := time.ParseDuration(os.Getenv("DB_SLOW_QUERY_THRESHOLD")); err == nil && threshold > 0 {
+		dbOpts = append(dbOpts, adapters.WithSlowQueryThreshold(threshold))
+	}
+	if timeout, err := time.ParseDuration(os.Getenv("DB_STATEMENT_TIMEOUT")); err == nil && timeout > 0 {
+		dbOpts = append(dbOpts, adapters.WithStatementTimeout(timeout))
+	}
+	if os.Getenv("DB_SQL_COMMENT") == "true" {
+		dbOpts = append(dbOpts, adapters.WithSQLComment())
+	}
+	if interval, err := time.ParseDuration(os.Getenv("DB_RETRY_PRIMARY_INTERVAL")); err == nil && interval > 0 {
+		dbOpts = append(dbOpts, adapters.WithRetryPrimaryInterval(interval))
+	}
+	if fraction, err := strconv.ParseFloat(os.Getenv("DB_EXPLAIN_SAMPLING"), 64); err == nil && fraction > 0 {
+		dbOpts = append(dbOpts, adapters.WithExplainSampling(fraction))
+	}
+	a.queryBudget, _ = strconv.Atoi(os.Getenv("QUERY_BUDGET"))
+	if a.queryBudget > 0 {
+		dbOpts = append(dbOpts, adapters.WithQueryCounting())
+	}
+	if a.tenantGuard != nil {
+		dbOpts = append(dbOpts, adapters.WithTenantGuard(a.tenantGuard))
+	}
+	db, err := openDB(ctx, dbOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("openDB: %w", err)
+	}
+	a.db = db
+	a.closers = append(a.closers, func(ctx context.Context) {
+		if err := db.Close(); err != nil {
+			slog.WarnContext(ctx, "failed to gracefully close DB connection", slog.String("error", err.Error()))
+		}
+	})
+	a.ngy = nagaya.New[*sqlx.DB, *sqlx.Conn](db, func(ctx context.Context, db *sqlx.DB) (*sqlx.Conn, error) { return db.Connx(ctx) })
+	a.tenantRepo = repos.NewTenantRepo(repos.WithDB(db))
+	return a, nil
+}
+
+// close releases the resources in the reverse order of the acquisition.
+func (a *app) close(ctx context.Context) {
+	for i := len(a.closers) - 1; i >= 0; i-- {
+		a.closers[i](ctx)
+	}
+}
+
+// startProcessMetrics starts collecting the metrics of the Go runtime and the host, which the long-running commands report.
+func (a *app) startProcessMetrics() error {
+	if err := runtime.Start(runtime.WithMeterProvider(a.meterProvider)); err != nil {
+		return fmt.Errorf("failed to start runtime metrics instrumentation: %w", err)
+	}
+	if err := host.Start(host.WithMeterProvider(a.meterProvider)); err != nil {
+		return fmt.Errorf("failed to start host metrics instrumentation: %w", err)
+	}
+	return nil
+}
+
+// runInTenant calls the function with the context bound to the connection of the tenant, as the apartment middleware does for the requests,
+// so that the repos built with a.ngy work outside of the requests.
+func (a *app) runInTenant(ctx context.Context, tenant string, fn func(ctx context.Context) error) error {
+	var err error
+	called := false
+	mw := nagaya.Middleware[*sqlx.DB, *sqlx.Conn](a.ngy,
+		nagaya.WithGetTenantFn(func(*http.Request) (nagaya.Tenant, bool) { return nagaya.Tenant(tenant), true }),
+		nagaya.WithErrorHandler(func(_ http.ResponseWriter, _ *http.Request, bindErr error) { err = bindErr }))
+	r, reqErr := http.NewRequestWithContext(ctx, http.MethodGet, "/", nil)
+	if reqErr != nil {
+		return fmt.Errorf("http.NewRequest: %w", reqErr)
+	}
+	mw(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		called = true
+		err = fn(r.Context())
+	})).ServeHTTP(discardResponseWriter{}, r)
+	if !called && err == nil {
+		err = errors.New("the connection of the tenant is not bound")
+	}
+	return err
+}
+
+type discardResponseWriter struct{}
+
+func (discardResponseWriter) Header() http.Header         { return http.Header{} }
+func (discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (discardResponseWriter) WriteHeader(int)             {}
+
+// openDB opens the DB handle for DATABASE_URL if set, otherwise for the DB_* env vars.
+func openDB(ctx context.Context, optFns ...adapters.OpenDBOption) (*sqlx.DB, error) {
+	if dbURL := os.Getenv("DATABASE_URL"); dbURL != "" {
+		return adapters.Open(dbURL, optFns...)
+	}
+	cfg, err := adapters.ConfigFromEnv(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return adapters.OpenDBWithConfig(cfg, optFns...)
+}
+
+// setupOtel creates the providers exporting the signals as configured by the standard env vars; see otlpExporterConfigFromEnv.
+//
+// The TracerProvider and the LoggerProvider are nil if the signals are not exported, and the readers are registered to the MeterProvider besides the exporter.
+// Unless keepTenant is true, the tenant is dropped from the attributes of the HTTP server, the apartment and the SLO metrics to bound their cardinality.
+// The metrics carry the exemplars of the sampled traces only if OTEL_GO_X_EXEMPLAR=true is set by the deployment.
+func setupOtel(ctx context.Context, keepTenant bool, readers ...sdkmetric.Reader) (*sdktrace.TracerProvider, *sdkmetric.MeterProvider, *sdklog.LoggerProvider, error) {
+	traceCfg, err := otlpExporterConfigFromEnv(otlpTraces)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	metricCfg, err := otlpExporterConfigFromEnv(otlpMetrics)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	logCfg, err := otlpExporterConfigFromEnv(otlpLogs)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	res, err := resource.New(
+		ctx,
+		resource.WithHost(),
+		resource.WithOS(),
+		resource.WithProcess(),
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(
+			semconv.ServiceName("enjoy-multitenancy"),
+			semconv.ServiceVersion(buildinfo.Get().Version),
+			semconv.DeploymentEnvironment("local"),
+		),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("resource.New: %w", err)
+	}
+	var tp *sdktrace.TracerProvider
+	if traceCfg.exporter != "" {
+		exporter, err := newTraceExporter(ctx, traceCfg)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		tp = sdktrace.NewTracerProvider(
+			sdktrace.WithBatcher(exporter),
+			sdktrace.WithResource(res),
+		)
+	}
+	mpOpts := []sdkmetric.Option{sdkmetric.WithResource(res)}
+	if metricCfg.exporter != "" {
+		metricExporter, err := newOTLPMetricExporter(ctx, metricCfg)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		mpOpts = append(mpOpts, sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter)))
+	}
+	for _, r := range readers {
+		mpOpts = append(mpOpts, sdkmetric.WithReader(r))
+	}
+	if !keepTenant {
+		dropTenant := sdkmetric.Stream{AttributeFilter: attribute.NewDenyKeysFilter(telemetry.TenantKey)}
+		mpOpts = append(mpOpts, sdkmetric.WithView(
+			sdkmetric.NewView(sdkmetric.Instrument{Name: "http.server.*"}, dropTenant),
+			sdkmetric.NewView(sdkmetric.Instrument{Name: "apartment.duration"}, dropTenant),
+			sdkmetric.NewView(sdkmetric.Instrument{Name: "slo.requests"}, dropTenant),
+		))
+	}
+	mp := sdkmetric.NewMeterProvider(mpOpts...)
+	if logCfg.exporter == "" {
+		return tp, mp, nil, nil
+	}
+	logExporter, err := newOTLPLogExporter(ctx, logCfg)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	lp := sdklog.NewLoggerProvider(
+		sdklog.WithProcessor(sdklog.NewBatchProcessor(logExporter)),
+		sdklog.WithResource(res),
+	)
+	return tp, mp, lp, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"enjoymultitenancy/web"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+const defaultConfigReloadInterval = time.Second * 10
+
+// configFile is the config file of the runtime settings, which is reloaded without restarting the server.
+type configFile struct {
+	path    string
+	modTime time.Time
+	size    int64
+}
+
+func (f *configFile) load() (*web.RuntimeConfig, error) {
+	stat, err := os.Stat(f.path)
+	if err != nil {
+		return nil, fmt.Errorf("os.Stat: %w", err)
+	}
+	body, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile: %w", err)
+	}
+	f.modTime, f.size = stat.ModTime(), stat.Size()
+	cfg := new(web.RuntimeConfig)
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("json.Decode: %w", err)
+	}
+	return cfg, nil
+}
+
+func (f *configFile) changed() bool {
+	stat, err := os.Stat(f.path)
+	if err != nil {
+		return false
+	}
+	return !stat.ModTime().Equal(f.modTime) || stat.Size() != f.size
+}
+
+// reload loads the config file and applies it to the server; the server keeps the last valid config if the file is invalid.
+func (f *configFile) reload(ctx context.Context, srv *web.Server, trigger string) {
+	cfg, err := f.load()
+	if err == nil {
+		err = srv.ApplyRuntimeConfig(ctx, cfg)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to reload config; keep the last valid config", slog.String("path", f.path), slog.String("trigger", trigger), slog.String("error", err.Error()))
+		return
+	}
+	slog.InfoContext(ctx, "config reloaded", slog.String("path", f.path), slog.String("trigger", trigger))
+}
+
+// watch reloads the config file on every signal, and whenever the file is modified if the interval is positive.
+func (f *configFile) watch(ctx context.Context, srv *web.Server, signals <-chan os.Signal, interval time.Duration) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-signals:
+			if !ok {
+				return
+			}
+			f.reload(ctx, srv, "signal")
+		case <-tick:
+			if f.changed() {
+				f.reload(ctx, srv, "modified")
+			}
+		}
+	}
+}
@@ -2,87 +2,82 @@ package main
 
 import (
 	"context"
-	"enjoymultitenancy/adapters"
-	"enjoymultitenancy/logging"
-	"enjoymultitenancy/repos"
-	"enjoymultitenancy/web"
+	"errors"
+	"flag"
 	"fmt"
 	"log/slog"
 	"os"
-	"time"
-
-	"github.com/aereal/nagaya"
-	"github.com/jmoiron/sqlx"
-	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
-	"go.opentelemetry.io/otel/sdk/resource"
-	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"strings"
 )
 
+const usage = `usage: server <command> [arguments]
+
+The commands are:
+
+	serve                  run the HTTP server (default)
+	worker                 run the background jobs such as the outbox relay without the HTTP server
+	migrate [tenant...]    apply the pending migrations to the shared database and the tenants
+	seed [flags]           provision the fake tenants and register the fake users to the tenants
+	tenant create <id>     provision the database of the tenant and register it
+	tenant list            list the registered tenants
+	tenant suspend <id>    suspend the tenant
+
+All the commands are configured by the same env vars such as DATABASE_URL and LOG_LEVEL.`
+
+// command is a subcommand of the server.
+type command interface {
+	// parse parses the arguments of the command; it is called before the app is initialized so that the invalid arguments fail fast.
+	parse(args []string) error
+	run(ctx context.Context, a *app) error
+}
+
+func newCommand(name string) (command, bool) {
+	switch name {
+	case "serve":
+		return &serveCommand{}, true
+	case "migrate":
+		return &migrateCommand{}, true
+	case "seed":
+		return &seedCommand{}, true
+	case "tenant":
+		return &tenantCommand{}, true
+	case "worker":
+		return &workerCommand{}, true
+	default:
+		return nil, false
+	}
+}
+
 func main() {
-	os.Exit(run())
+	os.Exit(run(os.Args[1:]))
 }
 
-func run() int {
-	logging.Init()
-	ctx := context.Background()
-	tp, err := setupOtel(ctx)
-	if err != nil {
-		slog.ErrorContext(ctx, "failed to setup OpenTelemetry instrumentation", slog.String("error", err.Error()))
-		return 1
+func run(args []string) int {
+	name := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	cmd, ok := newCommand(name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n\n%s\n", name, usage)
+		return 2
 	}
-	defer func() {
-		ctx, cancel := context.WithTimeout(ctx, time.Second*5)
-		defer cancel()
-		if err := tp.Shutdown(ctx); err != nil {
-			slog.WarnContext(ctx, "failed to shutdown TracerProvider", slog.String("error", err.Error()))
+	if err := cmd.parse(args); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, err)
 		}
-	}()
-	otel.SetTracerProvider(tp)
-	db, err := adapters.OpenDB(os.Getenv("DSN"))
+		return 2
+	}
+	ctx := context.Background()
+	a, err := newApp(ctx)
 	if err != nil {
-		slog.ErrorContext(ctx, "failed to create DB", slog.String("error", err.Error()))
+		slog.ErrorContext(ctx, "failed to initialize", slog.String("error", err.Error()))
 		return 1
 	}
-	defer func() {
-		if err := db.Close(); err != nil {
-			slog.WarnContext(ctx, "failed to gracefully close DB connection", slog.String("error", err.Error()))
-		}
-	}()
-	ngy := nagaya.New[*sqlx.DB, *sqlx.Conn](db, func(ctx context.Context, db *sqlx.DB) (*sqlx.Conn, error) { return db.Connx(ctx) })
-	userRepo := repos.NewUserRepo(repos.WithNagaya(ngy))
-	mw := nagaya.Middleware[*sqlx.DB, *sqlx.Conn](ngy, nagaya.GetTenantFromHeader("tenant-id"))
-	srv := web.NewServer(web.WithUserRepo(userRepo), web.WithPort(os.Getenv("PORT")), web.WithApartmentMiddleware(mw))
-	if err := srv.Start(ctx); err != nil {
-		slog.ErrorContext(ctx, "failed to start server", slog.String("error", err.Error()))
+	defer a.close(ctx)
+	if err := cmd.run(ctx, a); err != nil {
+		slog.ErrorContext(ctx, "command failed", slog.String("command", name), slog.String("error", err.Error()))
 		return 1
 	}
 	return 0
 }
-
-func setupOtel(ctx context.Context) (*sdktrace.TracerProvider, error) {
-	exporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithInsecure())
-	if err != nil {
-		return nil, fmt.Errorf("otlptracegrpc.New: %w", err)
-	}
-	res, err := resource.New(
-		ctx,
-		resource.WithHost(),
-		resource.WithOS(),
-		resource.WithProcess(),
-		resource.WithTelemetrySDK(),
-		resource.WithAttributes(
-			semconv.ServiceName("enjoy-multitenancy"),
-			semconv.DeploymentEnvironment("local"),
-		),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("resource.New: %w", err)
-	}
-	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exporter),
-		sdktrace.WithResource(res),
-	)
-	return tp, nil
-}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"enjoymultitenancy/schema"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+// migrateCommand creates the missing tables of the shared database and applies the pending migrations to the databases of the tenants.
+type migrateCommand struct {
+	status  bool
+	tenants []string
+}
+
+func (c *migrateCommand) parse(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fs.BoolVar(&c.status, "status", false, "print the versions of the registered tenants instead of migrating them")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: server migrate [-status] [tenant...]\n\nAll the registered tenants are migrated if no tenant is given.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	c.tenants = fs.Args()
+	return nil
+}
+
+func (c *migrateCommand) run(ctx context.Context, a *app) error {
+	runner := schema.NewRunner(a.db, a.tenantRepo)
+	if c.status {
+		statuses, err := runner.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "TENANT\tVERSION\tPENDING\t(latest: %d)\n", schema.LatestVersion())
+		for _, status := range statuses {
+			fmt.Fprintf(w, "%s\t%d\t%d\t\n", status.Tenant, status.Version, status.Pending)
+		}
+		return w.Flush()
+	}
+	_, err := runner.Run(ctx, c.tenants...)
+	return err
+}
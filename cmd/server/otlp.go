@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const (
+	otlpProtocolGRPC         = "grpc"
+	otlpProtocolHTTPProtobuf = "http/protobuf"
+
+	exporterOTLP    = "otlp"
+	exporterConsole = "console"
+	exporterNone    = "none"
+)
+
+// otlpSignal is the kind of the telemetry exported over OTLP, which names the env vars such as OTEL_EXPORTER_OTLP_TRACES_ENDPOINT.
+type otlpSignal struct {
+	name            string
+	defaultExporter string
+	// exporters is the values OTEL_<signal>_EXPORTER accepts besides none.
+	exporters []string
+	// protocols is the protocols the exporters available for the signal speak; the first one is the default.
+	protocols []string
+}
+
+var (
+	otlpTraces  = otlpSignal{name: "TRACES", defaultExporter: exporterOTLP, exporters: []string{exporterOTLP, exporterConsole}, protocols: []string{otlpProtocolGRPC, otlpProtocolHTTPProtobuf}}
+	otlpMetrics = otlpSignal{name: "METRICS", defaultExporter: exporterOTLP, exporters: []string{exporterOTLP}, protocols: []string{otlpProtocolGRPC, otlpProtocolHTTPProtobuf}}
+	otlpLogs    = otlpSignal{name: "LOGS", defaultExporter: exporterNone, exporters: []string{exporterOTLP}, protocols: []string{otlpProtocolHTTPProtobuf}}
+)
+
+// otlpExporterConfig is the config of the exporter of a signal given by the standard env vars.
+//
+// The rest of the standard env vars such as the endpoint, the headers, the compression and the certificates are read by the exporters themselves.
+type otlpExporterConfig struct {
+	// exporter is the value of OTEL_<signal>_EXPORTER such as otlp; it is empty if the signal is not exported.
+	exporter string
+	protocol string
+	// insecure is set if neither the endpoint nor the transport security is configured, so that the signal is exported to the local collector in plain text.
+	insecure bool
+}
+
+// otlpExporterConfigFromEnv reads OTEL_SDK_DISABLED, OTEL_<signal>_EXPORTER and the protocol of the signal.
+//
+// OTEL_<signal>_EXPORTER accepts otlp or none, and console for the traces to write the spans to stdout; the logs are not exported unless it is set to otlp.
+// The protocol is given by OTEL_EXPORTER_OTLP_<signal>_PROTOCOL, or OTEL_EXPORTER_OTLP_PROTOCOL if the signal can speak it;
+// the traces and the metrics are exported over gRPC by default, and the logs are always exported over http/protobuf.
+func otlpExporterConfigFromEnv(signal otlpSignal) (otlpExporterConfig, error) {
+	var cfg otlpExporterConfig
+	if strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") {
+		return cfg, nil
+	}
+	exporterEnv := "OTEL_" + signal.name + "_EXPORTER"
+	exporter := os.Getenv(exporterEnv)
+	if exporter == "" {
+		exporter = signal.defaultExporter
+	}
+	if exporter == exporterNone {
+		return cfg, nil
+	}
+	if !slices.Contains(signal.exporters, exporter) {
+		return cfg, fmt.Errorf("%s: unsupported exporter %q for the %s; available: %s, %s", exporterEnv, exporter, strings.ToLower(signal.name), strings.Join(signal.exporters, ", "), exporterNone)
+	}
+	cfg.exporter = exporter
+	if exporter != exporterOTLP {
+		return cfg, nil
+	}
+	cfg.protocol = signal.protocols[0]
+	protocolEnv := "OTEL_EXPORTER_OTLP_" + signal.name + "_PROTOCOL"
+	switch v, fallback := os.Getenv(protocolEnv), os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL"); {
+	case v != "":
+		if !slices.Contains(signal.protocols, v) {
+			return cfg, fmt.Errorf("%s: unsupported protocol %q for the %s; available: %s", protocolEnv, v, strings.ToLower(signal.name), strings.Join(signal.protocols, ", "))
+		}
+		cfg.protocol = v
+	// the generic one applies only to the signals that can speak the protocol, such as grpc for the traces while the logs keep http/protobuf
+	case slices.Contains(signal.protocols, fallback):
+		cfg.protocol = fallback
+	}
+	cfg.insecure = firstEnv(
+		"OTEL_EXPORTER_OTLP_ENDPOINT",
+		"OTEL_EXPORTER_OTLP_"+signal.name+"_ENDPOINT",
+		"OTEL_EXPORTER_OTLP_INSECURE",
+		"OTEL_EXPORTER_OTLP_"+signal.name+"_INSECURE",
+	) == ""
+	return cfg, nil
+}
+
+func newTraceExporter(ctx context.Context, cfg otlpExporterConfig) (sdktrace.SpanExporter, error) {
+	if cfg.exporter == exporterConsole {
+		exporter, err := stdouttrace.New()
+		if err != nil {
+			return nil, fmt.Errorf("stdouttrace.New: %w", err)
+		}
+		return exporter, nil
+	}
+	if cfg.protocol == otlpProtocolHTTPProtobuf {
+		var opts []otlptracehttp.Option
+		if cfg.insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err := otlptracehttp.New(ctx, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("otlptracehttp.New: %w", err)
+		}
+		return exporter, nil
+	}
+	var opts []otlptracegrpc.Option
+	if cfg.insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("otlptracegrpc.New: %w", err)
+	}
+	return exporter, nil
+}
+
+func newOTLPMetricExporter(ctx context.Context, cfg otlpExporterConfig) (sdkmetric.Exporter, error) {
+	if cfg.protocol == otlpProtocolHTTPProtobuf {
+		var opts []otlpmetrichttp.Option
+		if cfg.insecure {
+			opts = append(opts, otlpmetrichttp.WithInsecure())
+		}
+		exporter, err := otlpmetrichttp.New(ctx, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("otlpmetrichttp.New: %w", err)
+		}
+		return exporter, nil
+	}
+	var opts []otlpmetricgrpc.Option
+	if cfg.insecure {
+		opts = append(opts, otlpmetricgrpc.WithInsecure())
+	}
+	exporter, err := otlpmetricgrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("otlpmetricgrpc.New: %w", err)
+	}
+	return exporter, nil
+}
+
+func newOTLPLogExporter(ctx context.Context, cfg otlpExporterConfig) (sdklog.Exporter, error) {
+	var opts []otlploghttp.Option
+	if cfg.insecure {
+		opts = append(opts, otlploghttp.WithInsecure())
+	}
+	exporter, err := otlploghttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("otlploghttp.New: %w", err)
+	}
+	return exporter, nil
+}
+
+// firstEnv returns the value of the first env var that is set.
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
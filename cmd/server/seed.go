@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"enjoymultitenancy/repos"
+	"enjoymultitenancy/schema"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math/rand"
+
+	"github.com/rs/xid"
+)
+
+// seedBatchSize is the number of the users registered by a statement.
+const seedBatchSize = 500
+
+// seedCommand provisions the fake tenants and registers the fake users to them, for the demos, the load tests and the local development.
+type seedCommand struct {
+	newTenants int
+	users      int
+	events     bool
+	tenants    []string
+}
+
+func (c *seedCommand) parse(args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: server seed [flags] [tenant...]\n\nThe given tenants and the tenants provisioned by -tenants are seeded; all the active tenants are seeded if neither is given.")
+		fs.PrintDefaults()
+	}
+	fs.IntVar(&c.newTenants, "tenants", 0, "the number of the tenants to provision")
+	fs.IntVar(&c.users, "users", 10, "the number of the users registered to each tenant")
+	fs.BoolVar(&c.events, "events", false, "record the events of the registered users to the outbox")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if c.newTenants < 0 || c.users < 0 {
+		return errors.New("-tenants and -users must not be negative")
+	}
+	c.tenants = fs.Args()
+	for _, tenant := range c.tenants {
+		if err := repos.ValidateTenantID(tenant); err != nil {
+			return fmt.Errorf("%w: %q", err, tenant)
+		}
+	}
+	return nil
+}
+
+func (c *seedCommand) run(ctx context.Context, a *app) error {
+	tenants := c.tenants
+	for i := 0; i < c.newTenants; i++ {
+		tenant := "seed_" + xid.New().String()
+		if _, err := schema.MigrateTenant(ctx, a.db, tenant); err != nil {
+			return fmt.Errorf("failed to provision the database of tenant %s: %w", tenant, err)
+		}
+		if _, err := a.tenantRepo.CreateTenant(ctx, tenant); err != nil {
+			return fmt.Errorf("CreateTenant: %w", err)
+		}
+		slog.InfoContext(ctx, "provisioned tenant", slog.String("tenant", tenant))
+		tenants = append(tenants, tenant)
+	}
+	if len(tenants) == 0 {
+		registered, err := a.tenantRepo.ListTenants(ctx)
+		if err != nil {
+			return fmt.Errorf("ListTenants: %w", err)
+		}
+		for _, tenant := range registered {
+			if tenant.SuspendedAt == nil {
+				tenants = append(tenants, tenant.ID)
+			}
+		}
+	}
+	userRepoOpts := []repos.NewUserRepoOption{repos.WithNagaya(a.ngy)}
+	if c.events {
+		userRepoOpts = append(userRepoOpts, repos.WithOutbox())
+	}
+	userRepo := repos.NewUserRepo(userRepoOpts...)
+	for _, tenant := range tenants {
+		for registered := 0; registered < c.users; registered += seedBatchSize {
+			users := make([]*repos.UserToRegister, min(seedBatchSize, c.users-registered))
+			for i := range users {
+				users[i] = &repos.UserToRegister{Name: fakeUserName()}
+			}
+			err := a.runInTenant(ctx, tenant, func(ctx context.Context) error {
+				_, err := userRepo.RegisterUsers(ctx, users)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("failed to seed tenant %s: %w", tenant, err)
+			}
+		}
+		slog.InfoContext(ctx, "seeded tenant", slog.String("tenant", tenant), slog.Int("users", c.users))
+	}
+	return nil
+}
+
+var (
+	fakeGivenNames  = []string{"alice", "bob", "carol", "dave", "emma", "frank", "grace", "haruto", "ines", "jun", "kenji", "liam", "mia", "noah", "olivia", "priya", "quinn", "ren", "sakura", "taro", "uma", "victor", "wei", "yui", "zoe"}
+	fakeFamilyNames = []string{"anderson", "brown", "chen", "garcia", "ito", "kim", "kobayashi", "lopez", "martin", "mueller", "nakamura", "nguyen", "patel", "rossi", "sato", "smith", "suzuki", "takahashi", "tanaka", "watanabe", "yamamoto"}
+)
+
+// fakeUserName returns a name looking like a real user such as "sakura.tanaka.cs7g1mi3b2v6p4ke0s1g"; the xid suffix makes the names unique by construction.
+func fakeUserName() string {
+	return fmt.Sprintf("%s.%s.%s", fakeGivenNames[rand.Intn(len(fakeGivenNames))], fakeFamilyNames[rand.Intn(len(fakeFamilyNames))], xid.New().String())
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"enjoymultitenancy/adapters"
+	"enjoymultitenancy/auth"
+	"enjoymultitenancy/buildinfo"
+	"enjoymultitenancy/repos"
+	"enjoymultitenancy/schema"
+	"enjoymultitenancy/web"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aereal/nagaya"
+	"github.com/jmoiron/sqlx"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// serveCommand runs the HTTP server configured by the env vars until it receives SIGINT or SIGTERM.
+type serveCommand struct{}
+
+func (c *serveCommand) parse(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprintln(fs.Output(), "usage: server serve") }
+	return fs.Parse(args)
+}
+
+func (c *serveCommand) run(ctx context.Context, a *app) error {
+	info := buildinfo.Get()
+	slog.InfoContext(ctx, "starting server",
+		slog.String("version", info.Version),
+		slog.String("commit", info.Commit),
+		slog.String("build_time", info.BuildTime),
+		slog.String("go_version", info.GoVersion))
+	if err := a.startProcessMetrics(); err != nil {
+		return err
+	}
+	var warmUpOpts []adapters.WarmUpOption
+	if n, err := strconv.Atoi(os.Getenv("DB_WARM_CONNECTIONS")); err == nil && n > 0 {
+		a.db.SetMaxIdleConns(n)
+		warmUpOpts = append(warmUpOpts, adapters.WithWarmConnections(n))
+	}
+	if err := adapters.WarmUp(ctx, a.db, warmUpOpts...); err != nil {
+		return fmt.Errorf("adapters.WarmUp: %w", err)
+	}
+	userRepoOpts := []repos.NewUserRepoOption{repos.WithNagaya(a.ngy)}
+	outboxURL := os.Getenv("OUTBOX_PUBLISH_URL")
+	if outboxURL != "" {
+		userRepoOpts = append(userRepoOpts, repos.WithOutbox())
+	}
+	var responseCache *web.ResponseCache
+	if ttl, err := time.ParseDuration(os.Getenv("RESPONSE_CACHE_TTL")); err == nil && ttl > 0 {
+		responseCache = web.NewResponseCache(ttl)
+		userRepoOpts = append(userRepoOpts, repos.WithUserWriteHook(responseCache.InvalidateResource))
+	}
+	baseUserRepo := repos.NewUserRepo(userRepoOpts...)
+	var userRepo web.UserRepository = baseUserRepo
+	if ttl, err := time.ParseDuration(os.Getenv("USER_CACHE_TTL")); err == nil && ttl > 0 {
+		var backend repos.CacheBackend = repos.NewMemoryCacheBackend()
+		if redisURL := os.Getenv("REDIS_URL"); redisURL != "" {
+			redisOpts, err := redis.ParseURL(redisURL)
+			if err != nil {
+				return fmt.Errorf("failed to parse REDIS_URL: %w", err)
+			}
+			backend = repos.NewRedisCacheBackend(redis.NewClient(redisOpts))
+		}
+		userRepo = repos.NewCachedUserRepo(baseUserRepo, backend, ttl)
+	}
+	tenantResolver := web.NewTenantResolver("tenant-id", a.tenantRepo)
+	mw := nagaya.Middleware[*sqlx.DB, *sqlx.Conn](a.ngy, nagaya.WithGetTenantFn(tenantResolver.GetTenant), nagaya.WithRequestIDGenerator(web.RequestIDGenerator()))
+	dbBreaker := adapters.NewCircuitBreaker("mysql")
+	dbHealth := adapters.NewHealthChecker("mysql", a.db, adapters.WithStateChangeHook(func(ctx context.Context, name string, err error) {
+		if err == nil {
+			slog.InfoContext(ctx, "database recovered", slog.String("db", name))
+			return
+		}
+		slog.ErrorContext(ctx, "database became unhealthy", slog.String("db", name), slog.String("error", err.Error()))
+		dbBreaker.Trip()
+	}))
+	healthCtx, stopHealthCheck := context.WithCancel(ctx)
+	defer stopHealthCheck()
+	go dbHealth.Run(healthCtx)
+	// the background jobs are left to server worker if EMBED_WORKER is false
+	if os.Getenv("EMBED_WORKER") != "false" {
+		jobsCtx, stopJobs := context.WithCancel(ctx)
+		_, waitJobs := startBackgroundJobs(jobsCtx, a)
+		defer func() {
+			stopJobs()
+			waitJobs()
+		}()
+	}
+	schemaRunner := schema.NewRunner(a.db, a.tenantRepo)
+	srvOpts := []web.NewServerOption{
+		web.WithUserRepo(userRepo),
+		web.WithPort(os.Getenv("PORT")),
+		web.WithAdminPort(os.Getenv("ADMIN_PORT")),
+		web.WithApartmentMiddleware(mw),
+		web.WithTLS(os.Getenv("TLS_CERT_FILE"), os.Getenv("TLS_KEY_FILE")),
+		web.WithDB(a.db),
+		web.WithReadinessCheck("mysql", dbHealth.Check),
+		web.WithReadinessCheck("schema", schemaRunner.CheckUpToDate),
+		web.WithCircuitBreaker(dbBreaker),
+		web.WithTransactor(repos.NewTransactor(a.ngy)),
+		web.WithTenantRepo(a.tenantRepo),
+		web.WithTenantResolver(tenantResolver),
+		web.WithLogLevel(a.logLevel),
+		web.WithMetricsCollector(a.metricsReader),
+		web.WithSchemaMigrator(schemaRunner),
+	}
+	if delay, err := time.ParseDuration(os.Getenv("PRE_STOP_DELAY")); err == nil {
+		srvOpts = append(srvOpts, web.WithPreStopDelay(delay))
+	}
+	if maxInFlight, err := strconv.Atoi(os.Getenv("MAX_IN_FLIGHT_REQUESTS")); err == nil && maxInFlight > 0 {
+		queueDepth, _ := strconv.Atoi(os.Getenv("REQUEST_QUEUE_DEPTH"))
+		srvOpts = append(srvOpts, web.WithConcurrencyLimit(maxInFlight, queueDepth))
+	}
+	if mirrorURL, err := url.Parse(os.Getenv("MIRROR_UPSTREAM_URL")); err == nil && mirrorURL.Host != "" {
+		fraction, _ := strconv.ParseFloat(os.Getenv("MIRROR_FRACTION"), 64)
+		srvOpts = append(srvOpts, web.WithMirror(web.NewUpstreamMirror(mirrorURL), fraction))
+	}
+	if responseCache != nil {
+		srvOpts = append(srvOpts, web.WithResponseCache(responseCache))
+	}
+	if adminToken := os.Getenv("ADMIN_TOKEN"); adminToken != "" {
+		srvOpts = append(srvOpts, web.WithAdminMiddleware(auth.StaticTokenMiddleware("admin", adminToken)))
+	}
+	if jwksURL := os.Getenv("JWKS_URL"); jwksURL != "" {
+		verifier := auth.NewVerifier(auth.NewJWKS(jwksURL), auth.WithIssuer(os.Getenv("JWT_ISSUER")), auth.WithAudience(os.Getenv("JWT_AUDIENCE")))
+		srvOpts = append(srvOpts, web.WithAuthMiddleware(auth.Middleware(verifier)), web.WithMembershipRepo(repos.NewMembershipRepo(repos.WithMembershipNagaya(a.ngy))))
+	}
+	if os.Getenv("AUDIT_LOG") == "true" {
+		srvOpts = append(srvOpts, web.WithAuditRepo(repos.NewAuditRepo(repos.WithAuditNagaya(a.ngy))))
+	}
+	if a.queryBudget > 0 {
+		srvOpts = append(srvOpts, web.WithQueryBudget(a.queryBudget))
+	}
+	if a.tenantGuard != nil {
+		srvOpts = append(srvOpts, web.WithTenantGuard(a.tenantGuard))
+	}
+	if sloTargets := os.Getenv("SLO_TARGETS"); sloTargets != "" {
+		sloOpts, err := parseSLOTargets(sloTargets)
+		if err != nil {
+			return fmt.Errorf("invalid SLO_TARGETS: %w", err)
+		}
+		srvOpts = append(srvOpts, sloOpts...)
+		if window, err := time.ParseDuration(os.Getenv("SLO_WINDOW")); err == nil && window > 0 {
+			srvOpts = append(srvOpts, web.WithSLOWindow(window))
+		}
+	}
+	if os.Getenv("PPROF") == "true" {
+		srvOpts = append(srvOpts, web.WithPprof())
+	}
+	if os.Getenv("GRACEFUL_UPGRADE") == "true" {
+		srvOpts = append(srvOpts, web.WithGracefulUpgrade())
+	}
+	if os.Getenv("H2C") == "true" {
+		srvOpts = append(srvOpts, web.WithH2C())
+	}
+	toggle := make(chan os.Signal, 1)
+	notifyToggleDebugLogs(toggle)
+	defer signal.Stop(toggle)
+	go toggleDebugLogs(ctx, toggle, a.logLevel, a.initialLogLevel)
+	srv := web.NewServer(srvOpts...)
+	// the config file supersedes MAX_IN_FLIGHT_REQUESTS and REQUEST_QUEUE_DEPTH, and is reloaded on SIGHUP as well as the TLS certificate
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		cf := &configFile{path: path}
+		cfg, err := cf.load()
+		if err != nil {
+			return fmt.Errorf("failed to load CONFIG_FILE: %w", err)
+		}
+		if err := srv.ApplyRuntimeConfig(ctx, cfg); err != nil {
+			return fmt.Errorf("invalid CONFIG_FILE: %w", err)
+		}
+		interval := defaultConfigReloadInterval
+		if v, err := time.ParseDuration(os.Getenv("CONFIG_RELOAD_INTERVAL")); err == nil {
+			interval = v
+		}
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		defer signal.Stop(hup)
+		watchCtx, stopWatch := context.WithCancel(ctx)
+		defer stopWatch()
+		go cf.watch(watchCtx, srv, hup, interval)
+	}
+	if err := srv.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start server: %w", err)
+	}
+	return nil
+}
+
+// parseSLOTargets parses the SLO targets separated by semicolons such as "GET /users=300ms/0.999;POST /users=1s/0.99".
+//
+// Each target consists of the route, the latency threshold and the objective; the latency threshold may be empty to consider only the server errors.
+func parseSLOTargets(v string) ([]web.NewServerOption, error) {
+	var opts []web.NewServerOption
+	for _, entry := range strings.Split(v, ";") {
+		route, spec, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			return nil, fmt.Errorf("no target is given for %q", entry)
+		}
+		latencySpec, objectiveSpec, ok := strings.Cut(spec, "/")
+		if !ok {
+			return nil, fmt.Errorf("no objective is given for %q", route)
+		}
+		var target web.SLOTarget
+		if latencySpec != "" {
+			latency, err := time.ParseDuration(latencySpec)
+			if err != nil {
+				return nil, fmt.Errorf("time.ParseDuration: %w", err)
+			}
+			target.Latency = latency
+		}
+		objective, err := strconv.ParseFloat(objectiveSpec, 64)
+		if err != nil {
+			return nil, fmt.Errorf("strconv.ParseFloat: %w", err)
+		}
+		if objective <= 0 || objective >= 1 {
+			return nil, fmt.Errorf("the objective of %q must be between 0 and 1 exclusive", route)
+		}
+		target.Objective = objective
+		opts = append(opts, web.WithSLO(route, target))
+	}
+	return opts, nil
+}
+
+// toggleDebugLogs switches the log level between debug and the initial level on every signal, which is SIGUSR1 on the platforms supporting it.
+func toggleDebugLogs(ctx context.Context, signals <-chan os.Signal, level *slog.LevelVar, initial slog.Level) {
+	for range signals {
+		next := slog.LevelDebug
+		if level.Level() == slog.LevelDebug {
+			next = initial
+		}
+		level.Set(next)
+		slog.WarnContext(ctx, "log level changed by signal", slog.String("to", next.String()))
+	}
+}
+
+// webhookPublisher returns a publisher that POSTs the events to the URL as JSON.
+//
+// The event ID is sent as Idempotency-Key so that the receiver can drop the redelivered events.
+func webhookPublisher(endpoint string) repos.Publisher {
+	client := &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport), Timeout: time.Second * 10}
+	return repos.PublisherFunc(func(ctx context.Context, event *repos.OutboxEvent) error {
+		body, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("json.Marshal: %w", err)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("http.NewRequest: %w", err)
+		}
+		req.Header.Set("content-type", "application/json")
+		req.Header.Set("idempotency-key", event.ID)
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("http.Client.Do: %w", err)
+		}
+		defer resp.Body.Close()
+		_, _ = io.Copy(io.Discard, resp.Body)
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("the outbox endpoint responded with status %d", resp.StatusCode)
+		}
+		return nil
+	})
+}
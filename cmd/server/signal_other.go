@@ -0,0 +1,8 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package main
+
+import "os"
+
+// notifyToggleDebugLogs is no-op since SIGUSR1 is not available on the platform.
+func notifyToggleDebugLogs(c chan<- os.Signal) {}
@@ -0,0 +1,14 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyToggleDebugLogs relays SIGUSR1, which is not taken by the reloads on SIGHUP nor the graceful upgrade on SIGUSR2.
+func notifyToggleDebugLogs(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGUSR1)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"enjoymultitenancy/repos"
+	"enjoymultitenancy/schema"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+const tenantUsage = `usage: server tenant <create|list|suspend> [id]`
+
+// tenantCommand manages the registry of the tenants.
+type tenantCommand struct {
+	action string
+	id     string
+}
+
+func (c *tenantCommand) parse(args []string) error {
+	fs := flag.NewFlagSet("tenant", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprintln(fs.Output(), tenantUsage) }
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	args = fs.Args()
+	if len(args) == 0 {
+		return errors.New(tenantUsage)
+	}
+	c.action = args[0]
+	switch c.action {
+	case "list":
+		if len(args) != 1 {
+			return errors.New(tenantUsage)
+		}
+	case "create", "suspend":
+		if len(args) != 2 {
+			return errors.New(tenantUsage)
+		}
+		c.id = args[1]
+		if err := repos.ValidateTenantID(c.id); err != nil {
+			return fmt.Errorf("%w: %q", err, c.id)
+		}
+	default:
+		return fmt.Errorf("unknown action: %s\n%s", c.action, tenantUsage)
+	}
+	return nil
+}
+
+func (c *tenantCommand) run(ctx context.Context, a *app) error {
+	switch c.action {
+	case "create":
+		if _, err := schema.MigrateTenant(ctx, a.db, c.id); err != nil {
+			return fmt.Errorf("failed to provision the database: %w", err)
+		}
+		tenant, err := a.tenantRepo.CreateTenant(ctx, c.id)
+		if err != nil {
+			return fmt.Errorf("CreateTenant: %w", err)
+		}
+		fmt.Fprintln(os.Stdout, tenant.ID)
+	case "suspend":
+		if err := a.tenantRepo.SuspendTenant(ctx, c.id); err != nil {
+			return fmt.Errorf("SuspendTenant: %w", err)
+		}
+	case "list":
+		tenants, err := a.tenantRepo.ListTenants(ctx)
+		if err != nil {
+			return fmt.Errorf("ListTenants: %w", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tCREATED AT\tSUSPENDED AT")
+		for _, tenant := range tenants {
+			suspendedAt := "-"
+			if tenant.SuspendedAt != nil {
+				suspendedAt = tenant.SuspendedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", tenant.ID, tenant.CreatedAt.Format(time.RFC3339), suspendedAt)
+		}
+		return w.Flush()
+	}
+	return nil
+}
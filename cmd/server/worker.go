@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"enjoymultitenancy/repos"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// workerCommand runs the background jobs without the HTTP listener until it receives SIGINT or SIGTERM, so that they can be scaled apart from the servers.
+type workerCommand struct{}
+
+func (c *workerCommand) parse(args []string) error {
+	fs := flag.NewFlagSet("worker", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: server worker\n\nThe jobs are configured by OUTBOX_PUBLISH_URL, OUTBOX_RETENTION and AUDIT_LOG_RETENTION as serve does.")
+	}
+	return fs.Parse(args)
+}
+
+func (c *workerCommand) run(ctx context.Context, a *app) error {
+	if err := a.startProcessMetrics(); err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	jobs, wait := startBackgroundJobs(ctx, a)
+	if len(jobs) == 0 {
+		return errors.New("no background job is configured")
+	}
+	slog.InfoContext(ctx, "worker started", slog.Any("jobs", jobs))
+	<-ctx.Done()
+	wait()
+	slog.InfoContext(ctx, "worker stopped")
+	return nil
+}
+
+// startBackgroundJobs starts the background jobs configured by the env vars, and returns their names and the function waiting for them to stop after the context is done.
+//
+// The jobs of the multiple processes can run at once: the outbox relay locks the events with SKIP LOCKED and the prune is idempotent.
+func startBackgroundJobs(ctx context.Context, a *app) ([]string, func()) {
+	var (
+		jobs []string
+		wg   sync.WaitGroup
+	)
+	start := func(name string, run func(ctx context.Context)) {
+		jobs = append(jobs, name)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			run(ctx)
+		}()
+	}
+	if outboxURL := os.Getenv("OUTBOX_PUBLISH_URL"); outboxURL != "" {
+		var relayOpts []repos.NewOutboxRelayOption
+		if interval, err := time.ParseDuration(os.Getenv("OUTBOX_POLL_INTERVAL")); err == nil && interval > 0 {
+			relayOpts = append(relayOpts, repos.WithOutboxPollInterval(interval))
+		}
+		start("outbox_relay", repos.NewOutboxRelay(a.db, a.tenantRepo, webhookPublisher(outboxURL), relayOpts...).Run)
+	}
+	var policies []repos.RetentionPolicy
+	if maxAge, err := time.ParseDuration(os.Getenv("OUTBOX_RETENTION")); err == nil && maxAge > 0 {
+		policies = append(policies, repos.OutboxRetention(maxAge))
+	}
+	if maxAge, err := time.ParseDuration(os.Getenv("AUDIT_LOG_RETENTION")); err == nil && maxAge > 0 {
+		policies = append(policies, repos.AuditLogRetention(maxAge))
+	}
+	if len(policies) > 0 {
+		var prunerOpts []repos.NewRetentionPrunerOption
+		if interval, err := time.ParseDuration(os.Getenv("RETENTION_INTERVAL")); err == nil && interval > 0 {
+			prunerOpts = append(prunerOpts, repos.WithRetentionInterval(interval))
+		}
+		start("retention_pruner", repos.NewRetentionPruner(a.db, a.tenantRepo, policies, prunerOpts...).Run)
+	}
+	return jobs, wg.Wait
+}
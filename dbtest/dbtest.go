@@ -0,0 +1,169 @@
+// Package dbtest provides helpers to test the repos against MySQL running in a container.
+//
+// The tests using the helpers are skipped if Docker is not available.
+package dbtest
+
+import (
+	"context"
+	"enjoymultitenancy/adapters"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/aereal/nagaya"
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/xid"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/mysql"
+)
+
+const (
+	defaultImage          = "mysql:8.2.0"
+	sharedDatabase        = "multi_tenancy_app"
+	templateTenant        = "tenant_1"
+	throwawayTenantPrefix = "dbtest_"
+)
+
+type config struct {
+	image      string
+	schemaFile string
+}
+
+type Option func(cfg *config)
+
+// WithImage configures the image of the MySQL container.
+func WithImage(image string) Option {
+	return func(cfg *config) { cfg.image = image }
+}
+
+// WithSchemaFile configures the SQL file loaded on the start of the container instead of etc/ddl.sql.
+//
+// The file must create the template tenant tenant_1 that NewTenant copies the tables from.
+func WithSchemaFile(path string) Option {
+	return func(cfg *config) { cfg.schemaFile = path }
+}
+
+// MySQL is a MySQL server running in a container and loaded with the schema.
+type MySQL struct {
+	// DB is the handle to the shared database which can also access the databases of the tenants.
+	DB *sqlx.DB
+	// Nagaya binds the connections of the tenants; use RunInTenant to call the repos with it.
+	Nagaya *nagaya.Nagaya[*sqlx.DB, *sqlx.Conn]
+	// DSN is the data source name of the shared database.
+	DSN string
+}
+
+// StartMySQL starts a MySQL container loaded with the schema and returns the handles to it.
+//
+// The container is terminated on the cleanup of the test.
+// Starting a container takes seconds, so share a MySQL within the package and isolate the tests with NewTenant.
+func StartMySQL(t testing.TB, opts ...Option) *MySQL {
+	t.Helper()
+	cfg := &config{image: defaultImage, schemaFile: defaultSchemaFile()}
+	for _, o := range opts {
+		o(cfg)
+	}
+	ctx := context.Background()
+	skipIfNoDocker(t)
+
+	container, err := mysql.RunContainer(ctx,
+		testcontainers.WithImage(cfg.image),
+		mysql.WithUsername("root"),
+		mysql.WithPassword(""),
+		mysql.WithDatabase(sharedDatabase),
+		mysql.WithScripts(cfg.schemaFile),
+	)
+	if err != nil {
+		t.Fatalf("failed to start MySQL container: %s", err)
+	}
+	t.Cleanup(func() {
+		if err := container.Terminate(context.Background()); err != nil {
+			t.Logf("failed to terminate MySQL container: %s", err)
+		}
+	})
+	dsn, err := container.ConnectionString(ctx)
+	if err != nil {
+		t.Fatalf("failed to get the DSN of MySQL container: %s", err)
+	}
+	db, err := adapters.OpenDB(dsn)
+	if err != nil {
+		t.Fatalf("failed to open DB: %s", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := adapters.WarmUp(ctx, db); err != nil {
+		t.Fatalf("failed to connect to MySQL container: %s", err)
+	}
+	return &MySQL{
+		DB:     db,
+		Nagaya: nagaya.New[*sqlx.DB, *sqlx.Conn](db, func(ctx context.Context, db *sqlx.DB) (*sqlx.Conn, error) { return db.Connx(ctx) }),
+		DSN:    dsn,
+	}
+}
+
+// NewTenant provisions a throwaway tenant that has the same tables as the template tenant and is registered to the tenants table.
+//
+// The database of the tenant is dropped on the cleanup of the test.
+func (m *MySQL) NewTenant(t testing.TB) nagaya.Tenant {
+	t.Helper()
+	ctx := context.Background()
+	tenant := throwawayTenantPrefix + xid.New().String()
+	var tables []string
+	if err := m.DB.SelectContext(ctx, &tables, "select table_name from information_schema.tables where table_schema = ? and table_type = 'BASE TABLE'", templateTenant); err != nil {
+		t.Fatalf("failed to list the tables of the template tenant: %s", err)
+	}
+	stmts := []string{fmt.Sprintf("create database `%s`", tenant)}
+	for _, table := range tables {
+		stmts = append(stmts, fmt.Sprintf("create table `%s`.`%s` like `%s`.`%s`", tenant, table, templateTenant, table))
+	}
+	stmts = append(stmts, fmt.Sprintf("insert into `%s`.tenants (id) values ('%s')", sharedDatabase, tenant))
+	t.Cleanup(func() {
+		ctx := context.Background()
+		_, _ = m.DB.ExecContext(ctx, fmt.Sprintf("delete from `%s`.tenants where id = ?", sharedDatabase), tenant)
+		_, _ = m.DB.ExecContext(ctx, fmt.Sprintf("drop database if exists `%s`", tenant))
+	})
+	for _, stmt := range stmts {
+		if _, err := m.DB.ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("failed to provision tenant %s: %s", tenant, err)
+		}
+	}
+	return nagaya.Tenant(tenant)
+}
+
+// RunInTenant calls the function with the context bound to the connection of the tenant, as the apartment middleware does for the requests.
+//
+// The repos built with m.Nagaya work with the context only within the function.
+func (m *MySQL) RunInTenant(t testing.TB, tenant nagaya.Tenant, fn func(ctx context.Context)) {
+	t.Helper()
+	mw := nagaya.Middleware[*sqlx.DB, *sqlx.Conn](m.Nagaya, nagaya.WithGetTenantFn(func(*http.Request) (nagaya.Tenant, bool) { return tenant, true }))
+	called := false
+	h := mw(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		called = true
+		fn(r.Context())
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !called {
+		t.Fatalf("failed to bind the connection of tenant %s: %s", tenant, rec.Body.String())
+	}
+}
+
+func skipIfNoDocker(t testing.TB) {
+	t.Helper()
+	provider, err := testcontainers.ProviderDocker.GetProvider()
+	if err != nil {
+		t.Skipf("Docker is not available: %s", err)
+	}
+	defer provider.Close()
+	if err := provider.Health(context.Background()); err != nil {
+		t.Skipf("Docker is not available: %s", err)
+	}
+}
+
+// defaultSchemaFile returns the path to etc/ddl.sql of the module, located relative to this file so that it works from any package.
+func defaultSchemaFile() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "etc", "ddl.sql")
+}
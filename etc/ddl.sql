@@ -7,6 +7,35 @@ create table if not exists users (
   name varchar(255) not null unique
 ) ENGINE=INNODB DEFAULT CHARSET=utf8mb4 collate=utf8mb4_unicode_ci;
 
+create table if not exists memberships (
+  subject varchar(255) character set ascii primary key,
+  role enum('owner', 'editor', 'viewer') not null
+) ENGINE=INNODB DEFAULT CHARSET=utf8mb4 collate=utf8mb4_unicode_ci;
+
+create table if not exists outbox (
+  id char(20) character set ascii primary key,
+  event_type varchar(255) character set ascii not null,
+  payload json not null,
+  traceparent varchar(55) character set ascii not null default '',
+  created_at datetime(6) not null default current_timestamp(6),
+  published_at datetime(6) null,
+  claimed_until datetime(6) null,
+  key published_at_id (published_at, id)
+) ENGINE=INNODB DEFAULT CHARSET=utf8mb4 collate=utf8mb4_unicode_ci;
+
+create table if not exists audit_logs (
+  id char(20) character set ascii primary key,
+  principal varchar(255) not null,
+  action varchar(64) character set ascii not null,
+  resource varchar(255) not null,
+  before_state json null,
+  after_state json null,
+  request_id varchar(64) character set ascii not null default '',
+  created_at datetime(6) not null default current_timestamp(6),
+  key principal_id (principal, id),
+  key resource_id (resource, id)
+) ENGINE=INNODB DEFAULT CHARSET=utf8mb4 collate=utf8mb4_unicode_ci;
+
 create database tenant_2;
 
 use tenant_2;
@@ -16,6 +45,35 @@ create table if not exists users (
   name varchar(255) not null unique
 ) ENGINE=INNODB DEFAULT CHARSET=utf8mb4 collate=utf8mb4_unicode_ci;
 
+create table if not exists memberships (
+  subject varchar(255) character set ascii primary key,
+  role enum('owner', 'editor', 'viewer') not null
+) ENGINE=INNODB DEFAULT CHARSET=utf8mb4 collate=utf8mb4_unicode_ci;
+
+create table if not exists outbox (
+  id char(20) character set ascii primary key,
+  event_type varchar(255) character set ascii not null,
+  payload json not null,
+  traceparent varchar(55) character set ascii not null default '',
+  created_at datetime(6) not null default current_timestamp(6),
+  published_at datetime(6) null,
+  claimed_until datetime(6) null,
+  key published_at_id (published_at, id)
+) ENGINE=INNODB DEFAULT CHARSET=utf8mb4 collate=utf8mb4_unicode_ci;
+
+create table if not exists audit_logs (
+  id char(20) character set ascii primary key,
+  principal varchar(255) not null,
+  action varchar(64) character set ascii not null,
+  resource varchar(255) not null,
+  before_state json null,
+  after_state json null,
+  request_id varchar(64) character set ascii not null default '',
+  created_at datetime(6) not null default current_timestamp(6),
+  key principal_id (principal, id),
+  key resource_id (resource, id)
+) ENGINE=INNODB DEFAULT CHARSET=utf8mb4 collate=utf8mb4_unicode_ci;
+
 create database tenant_3;
 
 use tenant_3;
@@ -24,3 +82,48 @@ create table if not exists users (
   id char(20) character set ascii primary key,
   name varchar(255) not null unique
 ) ENGINE=INNODB DEFAULT CHARSET=utf8mb4 collate=utf8mb4_unicode_ci;
+
+create table if not exists memberships (
+  subject varchar(255) character set ascii primary key,
+  role enum('owner', 'editor', 'viewer') not null
+) ENGINE=INNODB DEFAULT CHARSET=utf8mb4 collate=utf8mb4_unicode_ci;
+
+create table if not exists outbox (
+  id char(20) character set ascii primary key,
+  event_type varchar(255) character set ascii not null,
+  payload json not null,
+  traceparent varchar(55) character set ascii not null default '',
+  created_at datetime(6) not null default current_timestamp(6),
+  published_at datetime(6) null,
+  claimed_until datetime(6) null,
+  key published_at_id (published_at, id)
+) ENGINE=INNODB DEFAULT CHARSET=utf8mb4 collate=utf8mb4_unicode_ci;
+
+create table if not exists audit_logs (
+  id char(20) character set ascii primary key,
+  principal varchar(255) not null,
+  action varchar(64) character set ascii not null,
+  resource varchar(255) not null,
+  before_state json null,
+  after_state json null,
+  request_id varchar(64) character set ascii not null default '',
+  created_at datetime(6) not null default current_timestamp(6),
+  key principal_id (principal, id),
+  key resource_id (resource, id)
+) ENGINE=INNODB DEFAULT CHARSET=utf8mb4 collate=utf8mb4_unicode_ci;
+
+use multi_tenancy_app;
+
+create table if not exists tenants (
+  id varchar(64) character set ascii primary key,
+  created_at datetime not null default current_timestamp,
+  suspended_at datetime null
+) ENGINE=INNODB DEFAULT CHARSET=utf8mb4 collate=utf8mb4_unicode_ci;
+
+insert into tenants (id) values ('tenant_1'), ('tenant_2'), ('tenant_3');
+
+create table if not exists tenant_domains (
+  domain varchar(253) character set ascii primary key,
+  tenant_id varchar(64) character set ascii not null,
+  foreign key (tenant_id) references tenants (id) on delete cascade
+) ENGINE=INNODB DEFAULT CHARSET=utf8mb4 collate=utf8mb4_unicode_ci;
@@ -4,13 +4,45 @@ import (
 	"context"
 	"log/slog"
 	"os"
+	"time"
 
+	"github.com/aereal/nagaya"
+	"go.opentelemetry.io/otel/log"
 	"go.opentelemetry.io/otel/trace"
 )
 
-func Init() {
-	opts := &slog.HandlerOptions{AddSource: true}
-	handler := &otelTraceIDHandler{Handler: slog.NewJSONHandler(os.Stdout, opts)}
+type InitOption func(cfg *initConfig)
+
+type initConfig struct {
+	samplingBurst    int
+	samplingInterval time.Duration
+	loggerProvider   log.LoggerProvider
+	level            slog.Leveler
+}
+
+// WithLevel configures the minimum level of the records; give slog.LevelVar to change the level at runtime.
+//
+// The level is slog.LevelInfo by default.
+func WithLevel(level slog.Leveler) InitOption {
+	return func(cfg *initConfig) { cfg.level = level }
+}
+
+func Init(optFns ...InitOption) {
+	cfg := &initConfig{level: slog.LevelInfo}
+	for _, f := range optFns {
+		f(cfg)
+	}
+	opts := &slog.HandlerOptions{AddSource: true, Level: cfg.level}
+	var handler slog.Handler = slog.NewJSONHandler(os.Stdout, opts)
+	if cfg.loggerProvider != nil {
+		handler = &fanoutHandler{handlers: []slog.Handler{handler, newOTelHandler(cfg.loggerProvider, cfg.level)}}
+	}
+	handler = &tenantHandler{Handler: handler}
+	handler = &requestIDHandler{Handler: handler}
+	handler = &otelTraceIDHandler{Handler: handler}
+	if cfg.samplingBurst > 0 && cfg.samplingInterval > 0 {
+		handler = newSamplingHandler(handler, cfg.samplingBurst, cfg.samplingInterval)
+	}
 	slog.SetDefault(slog.New(handler))
 }
 
@@ -26,3 +58,25 @@ func (h *otelTraceIDHandler) Handle(ctx context.Context, record slog.Record) err
 	}
 	return h.Handler.Handle(ctx, record)
 }
+
+// tenantHandler adds the tenant bound to the context to the records as tenant.id, so the call sites need not log it.
+type tenantHandler struct {
+	slog.Handler
+}
+
+var _ slog.Handler = (*tenantHandler)(nil)
+
+func (h *tenantHandler) Handle(ctx context.Context, record slog.Record) error {
+	if tenant, ok := nagaya.TenantFromContext(ctx); ok {
+		record.AddAttrs(slog.String("tenant.id", string(tenant)))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *tenantHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &tenantHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *tenantHandler) WithGroup(name string) slog.Handler {
+	return &tenantHandler{Handler: h.Handler.WithGroup(name)}
+}
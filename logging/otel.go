@@ -0,0 +1,86 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"go.opentelemetry.io/contrib/bridges/otelslog"
+	"go.opentelemetry.io/otel/log"
+)
+
+// WithOTelLoggerProvider makes the logger export the records through the LoggerProvider in addition to the standard output.
+//
+// The exported records carry the trace context of the calls and the resource of the provider, so they land in the same backend as the spans and the metrics.
+func WithOTelLoggerProvider(lp log.LoggerProvider) InitOption {
+	return func(cfg *initConfig) { cfg.loggerProvider = lp }
+}
+
+func newOTelHandler(lp log.LoggerProvider, level slog.Leveler) slog.Handler {
+	return &levelHandler{Handler: otelslog.NewHandler("enjoymultitenancy", otelslog.WithLoggerProvider(lp)), level: level}
+}
+
+// levelHandler drops the records below the level, which the bridge does not filter by itself.
+type levelHandler struct {
+	slog.Handler
+	level slog.Leveler
+}
+
+var _ slog.Handler = (*levelHandler)(nil)
+
+func (h *levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level.Level() && h.Handler.Enabled(ctx, level)
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{Handler: h.Handler.WithAttrs(attrs), level: h.level}
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{Handler: h.Handler.WithGroup(name), level: h.level}
+}
+
+// fanoutHandler passes the records to every handler.
+type fanoutHandler struct {
+	handlers []slog.Handler
+}
+
+var _ slog.Handler = (*fanoutHandler)(nil)
+
+func (h *fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range h.handlers {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *fanoutHandler) Handle(ctx context.Context, record slog.Record) error {
+	var errs []error
+	for _, handler := range h.handlers {
+		if !handler.Enabled(ctx, record.Level) {
+			continue
+		}
+		if err := handler.Handle(ctx, record.Clone()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h *fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make([]slog.Handler, len(h.handlers))
+	for i, handler := range h.handlers {
+		handlers[i] = handler.WithAttrs(attrs)
+	}
+	return &fanoutHandler{handlers: handlers}
+}
+
+func (h *fanoutHandler) WithGroup(name string) slog.Handler {
+	handlers := make([]slog.Handler, len(h.handlers))
+	for i, handler := range h.handlers {
+		handlers[i] = handler.WithGroup(name)
+	}
+	return &fanoutHandler{handlers: handlers}
+}
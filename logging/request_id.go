@@ -0,0 +1,41 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+)
+
+type requestIDCtxKey struct{}
+
+// ContextWithRequestID returns the context that carries the ID of the request being served.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDCtxKey{}, id)
+}
+
+// RequestIDFromContext returns the ID of the request carried by the context.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDCtxKey{}).(string)
+	return id, ok && id != ""
+}
+
+// requestIDHandler adds the request ID carried by the context to the records as request.id, which groups the logs of a request even if tracing is off.
+type requestIDHandler struct {
+	slog.Handler
+}
+
+var _ slog.Handler = (*requestIDHandler)(nil)
+
+func (h *requestIDHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id, ok := RequestIDFromContext(ctx); ok {
+		record.AddAttrs(slog.String("request.id", id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *requestIDHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &requestIDHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *requestIDHandler) WithGroup(name string) slog.Handler {
+	return &requestIDHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,96 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// WithSampling limits the records below the warning level to the burst per interval for each message, so the high-volume logs such as the ones of every request do not flood the output.
+//
+// The records of the warning level or above are always logged.
+// The first record logged after some records were dropped carries the number of them as sampling.dropped.
+func WithSampling(burst int, interval time.Duration) InitOption {
+	return func(cfg *initConfig) {
+		cfg.samplingBurst = burst
+		cfg.samplingInterval = interval
+	}
+}
+
+// samplingHandler rate-limits the records per level and message.
+//
+// The messages are expected to be constant strings with the variable parts given as attributes, so the number of the keys is bounded.
+type samplingHandler struct {
+	slog.Handler
+	burst    int
+	interval time.Duration
+	state    *samplingState
+}
+
+type samplingState struct {
+	mux     sync.Mutex
+	windows map[samplingKey]*samplingWindow
+}
+
+type samplingKey struct {
+	level   slog.Level
+	message string
+}
+
+type samplingWindow struct {
+	startedAt time.Time
+	count     int
+	dropped   int
+}
+
+var _ slog.Handler = (*samplingHandler)(nil)
+
+func newSamplingHandler(h slog.Handler, burst int, interval time.Duration) *samplingHandler {
+	return &samplingHandler{Handler: h, burst: burst, interval: interval, state: &samplingState{windows: map[samplingKey]*samplingWindow{}}}
+}
+
+func (h *samplingHandler) Handle(ctx context.Context, record slog.Record) error {
+	if record.Level >= slog.LevelWarn {
+		return h.Handler.Handle(ctx, record)
+	}
+	allowed, dropped := h.state.take(samplingKey{level: record.Level, message: record.Message}, record.Time, h.burst, h.interval)
+	if !allowed {
+		return nil
+	}
+	if dropped > 0 {
+		record.AddAttrs(slog.Int("sampling.dropped", dropped))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *samplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &samplingHandler{Handler: h.Handler.WithAttrs(attrs), burst: h.burst, interval: h.interval, state: h.state}
+}
+
+func (h *samplingHandler) WithGroup(name string) slog.Handler {
+	return &samplingHandler{Handler: h.Handler.WithGroup(name), burst: h.burst, interval: h.interval, state: h.state}
+}
+
+// take reports whether the record of the key is allowed at the time, and the number of the records dropped since the last allowed one.
+func (s *samplingState) take(key samplingKey, now time.Time, burst int, interval time.Duration) (bool, int) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	w, ok := s.windows[key]
+	if !ok || now.Sub(w.startedAt) >= interval {
+		var dropped int
+		if ok {
+			dropped = w.dropped
+		}
+		s.windows[key] = &samplingWindow{startedAt: now, count: 1}
+		return true, dropped
+	}
+	if w.count >= burst {
+		w.dropped++
+		return false, 0
+	}
+	w.count++
+	dropped := w.dropped
+	w.dropped = 0
+	return true, dropped
+}
@@ -0,0 +1,91 @@
+// Package reporting delivers the unexpected errors, such as the panics and the failures of the queries, to an error tracking service.
+package reporting
+
+import (
+	"context"
+	"enjoymultitenancy/logging"
+	"sync/atomic"
+
+	"github.com/aereal/nagaya"
+	"github.com/dimfeld/httptreemux/v5"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Event is an error reported with the context it occurred in.
+type Event struct {
+	Err error
+	// Panic is true if the error is recovered from a panic; Stack has the stack trace of the panicking goroutine.
+	Panic     bool
+	Stack     []byte
+	Tenant    string
+	Route     string
+	RequestID string
+	TraceID   string
+	SpanID    string
+}
+
+// Reporter delivers the events to an error tracking service such as Sentry.
+//
+// Report is called synchronously from the request, so the implementations should send the events in the background.
+type Reporter interface {
+	Report(ctx context.Context, event *Event)
+}
+
+// ReporterFunc is an adapter to use the function as Reporter.
+type ReporterFunc func(ctx context.Context, event *Event)
+
+func (f ReporterFunc) Report(ctx context.Context, event *Event) { f(ctx, event) }
+
+type reporterHolder struct {
+	reporter Reporter
+}
+
+var globalReporter atomic.Pointer[reporterHolder]
+
+// SetReporter registers the reporter the errors are reported to; the errors are discarded until it is called.
+func SetReporter(r Reporter) {
+	globalReporter.Store(&reporterHolder{reporter: r})
+}
+
+// Report reports the error along with the tenant, the route, the request ID and the trace context carried by the context.
+func Report(ctx context.Context, err error) {
+	report(ctx, &Event{Err: err})
+}
+
+// ReportPanic reports the error recovered from a panic with the stack trace.
+func ReportPanic(ctx context.Context, err error, stack []byte) {
+	report(ctx, &Event{Err: err, Panic: true, Stack: stack})
+}
+
+func report(ctx context.Context, event *Event) {
+	if scope, ok := ctx.Value(scopeCtxKey{}).(*atomic.Bool); ok {
+		scope.Store(true)
+	}
+	holder := globalReporter.Load()
+	if holder == nil || holder.reporter == nil || event.Err == nil {
+		return
+	}
+	if tenant, ok := nagaya.TenantFromContext(ctx); ok {
+		event.Tenant = string(tenant)
+	}
+	event.Route = httptreemux.ContextRoute(ctx)
+	event.RequestID, _ = logging.RequestIDFromContext(ctx)
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		event.TraceID = sc.TraceID().String()
+		event.SpanID = sc.SpanID().String()
+	}
+	holder.reporter.Report(ctx, event)
+}
+
+type scopeCtxKey struct{}
+
+// WithScope returns the context that remembers whether an error is reported within it, such as while serving a request.
+func WithScope(ctx context.Context) context.Context {
+	return context.WithValue(ctx, scopeCtxKey{}, new(atomic.Bool))
+}
+
+// Reported reports whether an error has been reported within the scope of the context.
+func Reported(ctx context.Context) bool {
+	scope, ok := ctx.Value(scopeCtxKey{}).(*atomic.Bool)
+	return ok && scope.Load()
+}
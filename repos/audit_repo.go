@@ -0,0 +1,262 @@
+package repos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aereal/nagaya"
+	"github.com/doug-martin/goqu/v9"
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/xid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	ErrAuditActionRequired = errors.New("audit.action is required")
+
+	defaultListAuditEntriesLimit = 50
+	maxListAuditEntriesLimit     = 500
+)
+
+type NewAuditRepoOption func(r *AuditRepo)
+
+func WithAuditNagaya(ngy *nagaya.Nagaya[*sqlx.DB, *sqlx.Conn]) NewAuditRepoOption {
+	return func(r *AuditRepo) { r.ngy = ngy }
+}
+
+func NewAuditRepo(optFns ...NewAuditRepoOption) *AuditRepo {
+	r := &AuditRepo{
+		tracer: otel.GetTracerProvider().Tracer("repos.AuditRepo"),
+	}
+	for _, f := range optFns {
+		f(r)
+	}
+	r.tables.auditLogs = goqu.Dialect("mysql").From("audit_logs")
+	return r
+}
+
+// AuditRepo records the changes made within the current tenant for the compliance review.
+//
+// The entries are written through the transaction started by Transactor if any, so that they are committed along with the changes they describe.
+type AuditRepo struct {
+	tracer trace.Tracer
+	ngy    *nagaya.Nagaya[*sqlx.DB, *sqlx.Conn]
+	tables struct {
+		auditLogs *goqu.SelectDataset
+	}
+}
+
+// AuditEntry is a record of who did what to which resource.
+//
+// Before and After are the JSON snapshots of the resource around the change; Before is empty on creation and After is empty on deletion.
+type AuditEntry struct {
+	ID        string          `db:"id" json:"id"`
+	Principal string          `db:"principal" json:"principal"`
+	Action    string          `db:"action" json:"action"`
+	Resource  string          `db:"resource" json:"resource"`
+	Before    json.RawMessage `db:"before_state" json:"before,omitempty"`
+	After     json.RawMessage `db:"after_state" json:"after,omitempty"`
+	RequestID string          `db:"request_id" json:"request_id"`
+	CreatedAt time.Time       `db:"created_at" json:"created_at"`
+}
+
+type listAuditEntriesConfig struct {
+	after     string
+	limit     int
+	principal string
+	action    string
+	resource  string
+	since     time.Time
+	until     time.Time
+}
+
+type ListAuditEntriesOption func(cfg *listAuditEntriesConfig)
+
+// WithAuditEntriesAfter makes ListAuditEntries return the entries older than the cursor returned as NextCursor.
+func WithAuditEntriesAfter(cursor string) ListAuditEntriesOption {
+	return func(cfg *listAuditEntriesConfig) { cfg.after = cursor }
+}
+
+// WithAuditEntriesLimit configures the max number of the entries in a page.
+func WithAuditEntriesLimit(limit int) ListAuditEntriesOption {
+	return func(cfg *listAuditEntriesConfig) { cfg.limit = limit }
+}
+
+// WithAuditPrincipal narrows the entries down to the changes made by the principal.
+func WithAuditPrincipal(principal string) ListAuditEntriesOption {
+	return func(cfg *listAuditEntriesConfig) { cfg.principal = principal }
+}
+
+// WithAuditAction narrows the entries down to the action.
+func WithAuditAction(action string) ListAuditEntriesOption {
+	return func(cfg *listAuditEntriesConfig) { cfg.action = action }
+}
+
+// WithAuditResource narrows the entries down to the changes of the resource.
+func WithAuditResource(resource string) ListAuditEntriesOption {
+	return func(cfg *listAuditEntriesConfig) { cfg.resource = resource }
+}
+
+// WithAuditPeriod narrows the entries down to the ones created in [since, until); the zero values mean unbounded.
+func WithAuditPeriod(since, until time.Time) ListAuditEntriesOption {
+	return func(cfg *listAuditEntriesConfig) {
+		cfg.since = since
+		cfg.until = until
+	}
+}
+
+// AuditPage is a page of the audit entries from the newest; NextCursor is empty on the last page.
+type AuditPage struct {
+	Entries    []*AuditEntry
+	NextCursor string
+}
+
+// RecordAudit records the entry; the ID and the creation time are assigned by the repo.
+func (r *AuditRepo) RecordAudit(ctx context.Context, entry *AuditEntry) (err error) {
+	ctx, span := r.tracer.Start(ctx, "RecordAudit")
+	defer span.End()
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			reportError(ctx, err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetStatus(codes.Ok, "")
+		}
+	}()
+
+	if entry == nil || entry.Action == "" {
+		return ErrAuditActionRequired
+	}
+	span.SetAttributes(attribute.String("audit.action", entry.Action), attribute.String("audit.resource", entry.Resource))
+
+	record := goqu.Record{
+		"id":         xid.New().String(),
+		"principal":  entry.Principal,
+		"action":     entry.Action,
+		"resource":   entry.Resource,
+		"request_id": entry.RequestID,
+	}
+	if len(entry.Before) > 0 {
+		record["before_state"] = string(entry.Before)
+	}
+	if len(entry.After) > 0 {
+		record["after_state"] = string(entry.After)
+	}
+	query, args, err := r.tables.auditLogs.Insert().
+		Prepared(true).
+		Rows(record).
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+
+	q, err := obtainQueryer(ctx, r.ngy)
+	if err != nil {
+		return err
+	}
+	res, err := q.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("ExecContext: %w", err)
+	}
+	_, _ = recordRowsAffected(span, res)
+	return nil
+}
+
+// ListAuditEntries returns a page of the audit entries from the newest.
+func (r *AuditRepo) ListAuditEntries(ctx context.Context, opts ...ListAuditEntriesOption) (_ *AuditPage, err error) {
+	ctx, span := r.tracer.Start(ctx, "ListAuditEntries")
+	defer span.End()
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			reportError(ctx, err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetStatus(codes.Ok, "")
+		}
+	}()
+
+	cfg := &listAuditEntriesConfig{limit: defaultListAuditEntriesLimit}
+	for _, o := range opts {
+		o(cfg)
+	}
+	if cfg.limit <= 0 {
+		cfg.limit = defaultListAuditEntriesLimit
+	}
+	cfg.limit = min(cfg.limit, maxListAuditEntriesLimit)
+
+	ds := r.tables.auditLogs.
+		Select("id", "principal", "action", "resource", "before_state", "after_state", "request_id", "created_at").
+		Order(goqu.C("id").Desc()).
+		Limit(uint(cfg.limit + 1))
+	if cfg.after != "" {
+		ds = ds.Where(goqu.C("id").Lt(cfg.after))
+	}
+	if cfg.principal != "" {
+		ds = ds.Where(goqu.C("principal").Eq(cfg.principal))
+	}
+	if cfg.action != "" {
+		ds = ds.Where(goqu.C("action").Eq(cfg.action))
+	}
+	if cfg.resource != "" {
+		ds = ds.Where(goqu.C("resource").Eq(cfg.resource))
+	}
+	if !cfg.since.IsZero() {
+		ds = ds.Where(goqu.C("created_at").Gte(cfg.since))
+	}
+	if !cfg.until.IsZero() {
+		ds = ds.Where(goqu.C("created_at").Lt(cfg.until))
+	}
+	query, args, err := ds.Prepared(true).ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	q, err := obtainQueryer(ctx, r.ngy)
+	if err != nil {
+		return nil, err
+	}
+	var rows []*auditEntryDTO
+	if err := sqlx.SelectContext(ctx, q, &rows, query, args...); err != nil {
+		return nil, fmt.Errorf("SelectContext: %w", err)
+	}
+	recordRowsReturned(span, len(rows))
+	page := &AuditPage{Entries: make([]*AuditEntry, 0, min(len(rows), cfg.limit))}
+	for i, row := range rows {
+		if i == cfg.limit {
+			page.NextCursor = page.Entries[i-1].ID
+			break
+		}
+		page.Entries = append(page.Entries, row.entry())
+	}
+	return page, nil
+}
+
+type auditEntryDTO struct {
+	ID        string    `db:"id"`
+	Principal string    `db:"principal"`
+	Action    string    `db:"action"`
+	Resource  string    `db:"resource"`
+	Before    *string   `db:"before_state"`
+	After     *string   `db:"after_state"`
+	RequestID string    `db:"request_id"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+func (dto *auditEntryDTO) entry() *AuditEntry {
+	e := &AuditEntry{ID: dto.ID, Principal: dto.Principal, Action: dto.Action, Resource: dto.Resource, RequestID: dto.RequestID, CreatedAt: dto.CreatedAt}
+	if dto.Before != nil {
+		e.Before = json.RawMessage(*dto.Before)
+	}
+	if dto.After != nil {
+		e.After = json.RawMessage(*dto.After)
+	}
+	return e
+}
@@ -0,0 +1,259 @@
+package repos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/aereal/nagaya"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+)
+
+// CacheBackend stores the encoded entries of the caching decorators.
+type CacheBackend interface {
+	// Get returns the value of the key; the second value is false if the key is missing or expired.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+var defaultMemoryCacheMaxEntries = 10000
+
+type NewMemoryCacheBackendOption func(b *MemoryCacheBackend)
+
+// WithMemoryCacheMaxEntries configures the maximum number of the entries kept by MemoryCacheBackend.
+func WithMemoryCacheMaxEntries(n int) NewMemoryCacheBackendOption {
+	return func(b *MemoryCacheBackend) { b.maxEntries = n }
+}
+
+// NewMemoryCacheBackend returns a CacheBackend that keeps the entries in the process memory.
+//
+// The expired entries are dropped when they are read, or swept when the backend is full; Set is ignored while the backend is full of live entries.
+func NewMemoryCacheBackend(optFns ...NewMemoryCacheBackendOption) *MemoryCacheBackend {
+	b := &MemoryCacheBackend{entries: map[string]*memoryCacheEntry{}}
+	for _, f := range optFns {
+		f(b)
+	}
+	if b.maxEntries == 0 {
+		b.maxEntries = defaultMemoryCacheMaxEntries
+	}
+	return b
+}
+
+type MemoryCacheBackend struct {
+	maxEntries int
+
+	mux     sync.RWMutex
+	entries map[string]*memoryCacheEntry
+}
+
+type memoryCacheEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+var _ CacheBackend = (*MemoryCacheBackend)(nil)
+
+func (b *MemoryCacheBackend) Get(_ context.Context, key string) ([]byte, bool, error) {
+	b.mux.RLock()
+	entry, ok := b.entries[key]
+	b.mux.RUnlock()
+	if !ok {
+		return nil, false, nil
+	}
+	if time.Now().After(entry.expiresAt) {
+		b.mux.Lock()
+		if current, ok := b.entries[key]; ok && current == entry {
+			delete(b.entries, key)
+		}
+		b.mux.Unlock()
+		return nil, false, nil
+	}
+	return entry.value, true, nil
+}
+
+func (b *MemoryCacheBackend) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	now := time.Now()
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if _, ok := b.entries[key]; !ok && len(b.entries) >= b.maxEntries {
+		for k, entry := range b.entries {
+			if now.After(entry.expiresAt) {
+				delete(b.entries, k)
+			}
+		}
+		if len(b.entries) >= b.maxEntries {
+			return nil
+		}
+	}
+	b.entries[key] = &memoryCacheEntry{value: value, expiresAt: now.Add(ttl)}
+	return nil
+}
+
+func (b *MemoryCacheBackend) Delete(_ context.Context, keys ...string) error {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	for _, key := range keys {
+		delete(b.entries, key)
+	}
+	return nil
+}
+
+// NewRedisCacheBackend returns a CacheBackend that stores the entries in Redis, which lets the processes share the cache and its invalidations.
+func NewRedisCacheBackend(client redis.UniversalClient) *RedisCacheBackend {
+	return &RedisCacheBackend{client: client}
+}
+
+type RedisCacheBackend struct {
+	client redis.UniversalClient
+}
+
+var _ CacheBackend = (*RedisCacheBackend)(nil)
+
+func (b *RedisCacheBackend) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := b.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("redis.Get: %w", err)
+	}
+	return value, true, nil
+}
+
+func (b *RedisCacheBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := b.client.Set(ctx, key, value, ttl).Err(); err != nil {
+		return fmt.Errorf("redis.Set: %w", err)
+	}
+	return nil
+}
+
+func (b *RedisCacheBackend) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	if err := b.client.Del(ctx, keys...).Err(); err != nil {
+		return fmt.Errorf("redis.Del: %w", err)
+	}
+	return nil
+}
+
+// NewCachedUserRepo returns CachedUserRepo that caches the users fetched by the repo in the backend for the TTL.
+func NewCachedUserRepo(repo *UserRepo, backend CacheBackend, ttl time.Duration) *CachedUserRepo {
+	return &CachedUserRepo{UserRepo: repo, backend: backend, ttl: ttl}
+}
+
+// CachedUserRepo is a read-through caching decorator of UserRepo.
+//
+// FetchUserByName reads the cache first and the concurrent misses of the same user share a single query.
+// The writes through the decorator drop the entries of the affected users; the writes made by the other processes are visible after the TTL unless the backend is shared.
+// The keys include the tenant bound to the context, and the calls without a tenant or with WithUserFields bypass the cache.
+// The failures of the backend are logged and the decorator falls back to the repo.
+type CachedUserRepo struct {
+	*UserRepo
+	backend CacheBackend
+	ttl     time.Duration
+	group   singleflight.Group
+}
+
+func userCacheKey(tenant nagaya.Tenant, name string) string {
+	return fmt.Sprintf("users:%s:name:%s", tenant, name)
+}
+
+func (r *CachedUserRepo) FetchUserByName(ctx context.Context, name string, opts ...FetchUserOption) (*User, error) {
+	tenant, ok := nagaya.TenantFromContext(ctx)
+	if !ok || len(opts) > 0 || name == "" {
+		return r.UserRepo.FetchUserByName(ctx, name, opts...)
+	}
+	key := userCacheKey(tenant, name)
+	if b, ok, err := r.backend.Get(ctx, key); err != nil {
+		slog.WarnContext(ctx, "failed to read the user cache", slog.String("key", key), slog.String("error", err.Error()))
+	} else if ok {
+		user := new(User)
+		if err := json.Unmarshal(b, user); err == nil {
+			return user, nil
+		}
+	}
+	v, err, _ := r.group.Do(key, func() (any, error) {
+		// the result is shared with the other callers, so the cancellation of the first caller must not fail them
+		ctx := context.WithoutCancel(ctx)
+		user, err := r.UserRepo.FetchUserByName(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		b, err := json.Marshal(user)
+		if err != nil {
+			return nil, fmt.Errorf("json.Marshal: %w", err)
+		}
+		if err := r.backend.Set(ctx, key, b, r.ttl); err != nil {
+			slog.WarnContext(ctx, "failed to write the user cache", slog.String("key", key), slog.String("error", err.Error()))
+		}
+		return user, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	user := *v.(*User)
+	return &user, nil
+}
+
+func (r *CachedUserRepo) RegisterUser(ctx context.Context, user *UserToRegister) (*User, error) {
+	registered, err := r.UserRepo.RegisterUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	r.invalidate(ctx, registered.Name)
+	return registered, nil
+}
+
+func (r *CachedUserRepo) RegisterUsers(ctx context.Context, users []*UserToRegister) ([]*User, error) {
+	registered, err := r.UserRepo.RegisterUsers(ctx, users)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(registered))
+	for i, user := range registered {
+		names[i] = user.Name
+	}
+	r.invalidate(ctx, names...)
+	return registered, nil
+}
+
+func (r *CachedUserRepo) UpdateUser(ctx context.Context, name string, opts ...UpdateUserOption) (*User, error) {
+	user, err := r.UserRepo.UpdateUser(ctx, name, opts...)
+	if err != nil {
+		return nil, err
+	}
+	r.invalidate(ctx, name, user.Name)
+	return user, nil
+}
+
+func (r *CachedUserRepo) DeleteUser(ctx context.Context, name string) error {
+	if err := r.UserRepo.DeleteUser(ctx, name); err != nil {
+		return err
+	}
+	r.invalidate(ctx, name)
+	return nil
+}
+
+// invalidate drops the entries of the users after the transaction commits if the context has one, so that the concurrent reads do not cache the rows being overwritten.
+func (r *CachedUserRepo) invalidate(ctx context.Context, names ...string) {
+	tenant, ok := nagaya.TenantFromContext(ctx)
+	if !ok {
+		return
+	}
+	keys := make([]string, len(names))
+	for i, name := range names {
+		keys[i] = userCacheKey(tenant, name)
+	}
+	afterCommit(ctx, func() {
+		if err := r.backend.Delete(context.WithoutCancel(ctx), keys...); err != nil {
+			slog.WarnContext(ctx, "failed to invalidate the user cache", slog.String("error", err.Error()))
+		}
+	})
+}
@@ -0,0 +1,141 @@
+package repos
+
+import (
+	"context"
+	"enjoymultitenancy/dbtest"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheBackend(t *testing.T) {
+	ctx := context.Background()
+	testCases := []struct {
+		name    string
+		setup   func(b *MemoryCacheBackend)
+		key     string
+		wantOK  bool
+		wantLen int
+	}{
+		{
+			name:    "hit",
+			setup:   func(b *MemoryCacheBackend) { _ = b.Set(ctx, "k1", []byte("v1"), time.Minute) },
+			key:     "k1",
+			wantOK:  true,
+			wantLen: 1,
+		},
+		{
+			name:    "expired",
+			setup:   func(b *MemoryCacheBackend) { _ = b.Set(ctx, "k1", []byte("v1"), -time.Second) },
+			key:     "k1",
+			wantOK:  false,
+			wantLen: 0,
+		},
+		{
+			name: "deleted",
+			setup: func(b *MemoryCacheBackend) {
+				_ = b.Set(ctx, "k1", []byte("v1"), time.Minute)
+				_ = b.Delete(ctx, "k1")
+			},
+			key:     "k1",
+			wantOK:  false,
+			wantLen: 0,
+		},
+		{
+			name: "full of live entries",
+			setup: func(b *MemoryCacheBackend) {
+				_ = b.Set(ctx, "k1", []byte("v1"), time.Minute)
+				_ = b.Set(ctx, "k2", []byte("v2"), time.Minute)
+				_ = b.Set(ctx, "k3", []byte("v3"), time.Minute)
+			},
+			key:     "k3",
+			wantOK:  false,
+			wantLen: 2,
+		},
+		{
+			name: "full and overwritten",
+			setup: func(b *MemoryCacheBackend) {
+				_ = b.Set(ctx, "k1", []byte("v1"), time.Minute)
+				_ = b.Set(ctx, "k2", []byte("v2"), time.Minute)
+				_ = b.Set(ctx, "k2", []byte("v2'"), time.Minute)
+			},
+			key:     "k2",
+			wantOK:  true,
+			wantLen: 2,
+		},
+		{
+			name: "expired entries are swept when full",
+			setup: func(b *MemoryCacheBackend) {
+				_ = b.Set(ctx, "k1", []byte("v1"), -time.Second)
+				_ = b.Set(ctx, "k2", []byte("v2"), -time.Second)
+				_ = b.Set(ctx, "k3", []byte("v3"), time.Minute)
+			},
+			key:     "k3",
+			wantOK:  true,
+			wantLen: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := NewMemoryCacheBackend(WithMemoryCacheMaxEntries(2))
+			tc.setup(b)
+			_, ok, err := b.Get(ctx, tc.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tc.wantOK {
+				t.Errorf("Get(%q): ok = %v, want %v", tc.key, ok, tc.wantOK)
+			}
+			if len(b.entries) != tc.wantLen {
+				t.Errorf("entries = %d, want %d", len(b.entries), tc.wantLen)
+			}
+		})
+	}
+}
+
+func TestCachedUserRepo(t *testing.T) {
+	db := dbtest.StartMySQL(t)
+	tenant := db.NewTenant(t)
+	backend := NewMemoryCacheBackend()
+	repo := NewCachedUserRepo(NewUserRepo(WithNagaya(db.Nagaya)), backend, time.Minute)
+	key := userCacheKey(tenant, "alice")
+	cached := func(ctx context.Context) bool {
+		_, ok, _ := backend.Get(ctx, key)
+		return ok
+	}
+
+	db.RunInTenant(t, tenant, func(ctx context.Context) {
+		if _, err := repo.RegisterUser(ctx, &UserToRegister{Name: "alice"}); err != nil {
+			t.Fatalf("RegisterUser: %s", err)
+		}
+
+		t.Run("the loader is not canceled by the caller", func(t *testing.T) {
+			canceledCtx, cancel := context.WithCancel(ctx)
+			cancel()
+			if _, err := repo.FetchUserByName(canceledCtx, "alice"); err != nil {
+				t.Fatalf("FetchUserByName: %s", err)
+			}
+			if !cached(ctx) {
+				t.Error("the user is not cached")
+			}
+		})
+
+		t.Run("invalidated after commit", func(t *testing.T) {
+			err := NewTransactor(db.Nagaya).RunInTx(ctx, func(ctx context.Context) error {
+				if _, err := repo.UpdateUser(ctx, "alice", WithNewUserName("alice2")); err != nil {
+					return err
+				}
+				if !cached(ctx) {
+					return fmt.Errorf("the user is invalidated before the commit")
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cached(ctx) {
+				t.Error("the user is not invalidated after the commit")
+			}
+		})
+	})
+}
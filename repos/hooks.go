@@ -0,0 +1,38 @@
+package repos
+
+import (
+	"context"
+	"enjoymultitenancy/reporting"
+	"errors"
+)
+
+// WriteHook is a function called after the repo successfully writes the resource within the tenant bound to the context.
+type WriteHook func(ctx context.Context, resource string)
+
+const resourceUsers = "users"
+
+// reportError reports the error to the error tracking service unless it is an expected outcome such as ErrNotFound, a validation error or a canceled request.
+func reportError(ctx context.Context, err error) {
+	var (
+		validationErr   *ValidationError
+		importErr       *UserImportError
+		unknownFieldErr *UnknownFieldError
+	)
+	switch {
+	case errors.Is(err, ErrNotFound),
+		errors.Is(err, ErrNothingToUpdate),
+		errors.Is(err, ErrUserAlreadyExists),
+		errors.Is(err, ErrInvalidDomain),
+		errors.Is(err, ErrInvalidTenantID),
+		errors.Is(err, ErrTenantAlreadyExists),
+		errors.Is(err, ErrSubjectRequired),
+		errors.Is(err, ErrAuditActionRequired),
+		errors.Is(err, context.Canceled),
+		errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &validationErr),
+		errors.As(err, &importErr),
+		errors.As(err, &unknownFieldErr):
+		return
+	}
+	reporting.Report(ctx, err)
+}
@@ -0,0 +1,136 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	"enjoymultitenancy/auth"
+	"errors"
+	"fmt"
+
+	"github.com/aereal/nagaya"
+	"github.com/doug-martin/goqu/v9"
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var ErrSubjectRequired = errors.New("membership.subject is required")
+
+type NewMembershipRepoOption func(r *MembershipRepo)
+
+func WithMembershipNagaya(ngy *nagaya.Nagaya[*sqlx.DB, *sqlx.Conn]) NewMembershipRepoOption {
+	return func(r *MembershipRepo) { r.ngy = ngy }
+}
+
+func NewMembershipRepo(optFns ...NewMembershipRepoOption) *MembershipRepo {
+	r := &MembershipRepo{
+		tracer: otel.GetTracerProvider().Tracer("repos.MembershipRepo"),
+	}
+	for _, f := range optFns {
+		f(r)
+	}
+	r.tables.memberships = goqu.Dialect("mysql").From("memberships")
+	return r
+}
+
+// MembershipRepo manages the roles granted to the subjects within the current tenant.
+type MembershipRepo struct {
+	tracer trace.Tracer
+	ngy    *nagaya.Nagaya[*sqlx.DB, *sqlx.Conn]
+	tables struct {
+		memberships *goqu.SelectDataset
+	}
+}
+
+type Membership struct {
+	Subject string    `db:"subject"`
+	Role    auth.Role `db:"role"`
+}
+
+func (r *MembershipRepo) FetchMembership(ctx context.Context, subject string) (_ *Membership, err error) {
+	ctx, span := r.tracer.Start(ctx, "FetchMembership", trace.WithAttributes(attribute.String("membership.subject", subject)))
+	defer span.End()
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			reportError(ctx, err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetStatus(codes.Ok, "")
+		}
+	}()
+
+	if subject == "" {
+		return nil, ErrSubjectRequired
+	}
+
+	query, args, err := r.tables.memberships.
+		Where(goqu.C("subject").Eq(subject)).
+		Limit(1).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	q, err := obtainQueryer(ctx, r.ngy)
+	if err != nil {
+		return nil, err
+	}
+	membership := new(Membership)
+	if err := sqlx.GetContext(ctx, q, membership, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			recordRowsReturned(span, 0)
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	recordRowsReturned(span, 1)
+	return membership, nil
+}
+
+// GrantRole grants the role to the subject, replacing the existing role, and reports whether the membership was created, updated or unchanged.
+func (r *MembershipRepo) GrantRole(ctx context.Context, subject string, role auth.Role) (_ PutResult, err error) {
+	ctx, span := r.tracer.Start(ctx, "GrantRole", trace.WithAttributes(attribute.String("membership.subject", subject), attribute.String("membership.role", string(role))))
+	defer span.End()
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			reportError(ctx, err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetStatus(codes.Ok, "")
+		}
+	}()
+
+	if subject == "" {
+		return PutUnchanged, ErrSubjectRequired
+	}
+	if _, err := auth.ParseRole(string(role)); err != nil {
+		return PutUnchanged, err
+	}
+
+	query, args, err := r.tables.memberships.Insert().
+		Prepared(true).
+		Rows(&Membership{Subject: subject, Role: role}).
+		OnConflict(goqu.DoUpdate("subject", goqu.Record{"role": role})).
+		ToSQL()
+	if err != nil {
+		return PutUnchanged, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	q, err := obtainQueryer(ctx, r.ngy)
+	if err != nil {
+		return PutUnchanged, err
+	}
+	res, err := q.ExecContext(ctx, query, args...)
+	if err != nil {
+		return PutUnchanged, fmt.Errorf("ExecContext: %w", err)
+	}
+	affected, err := recordRowsAffected(span, res)
+	if err != nil {
+		return PutUnchanged, fmt.Errorf("RowsAffected: %w", err)
+	}
+	return putResultOf(affected), nil
+}
@@ -0,0 +1,374 @@
+package repos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/xid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// EventUserRegistered is recorded for each user registered by RegisterUser or RegisterUsers; the payload is the User.
+	EventUserRegistered = "user.registered"
+
+	outboxTable = "outbox"
+
+	defaultOutboxPollInterval = time.Second
+	defaultOutboxBatchSize    = 100
+	defaultOutboxClaimTTL     = time.Minute * 5
+)
+
+// OutboxEvent is a domain event recorded to the outbox table of the tenant.
+type OutboxEvent struct {
+	ID          string          `db:"id" json:"id"`
+	Type        string          `db:"event_type" json:"type"`
+	Payload     json.RawMessage `db:"payload" json:"payload"`
+	Traceparent string          `db:"traceparent" json:"-"`
+	CreatedAt   time.Time       `db:"created_at" json:"created_at"`
+	Tenant      string          `db:"-" json:"tenant"`
+}
+
+// Publisher delivers the events to the outside of the service, such as a message broker.
+//
+// The relay may call Publish for the same event more than once, so the subscribers must deduplicate the events by ID.
+type Publisher interface {
+	Publish(ctx context.Context, event *OutboxEvent) error
+}
+
+// PublisherFunc is an adapter to use the function as Publisher.
+type PublisherFunc func(ctx context.Context, event *OutboxEvent) error
+
+func (f PublisherFunc) Publish(ctx context.Context, event *OutboxEvent) error { return f(ctx, event) }
+
+type outboxRow struct {
+	ID          string `db:"id"`
+	Type        string `db:"event_type"`
+	Payload     string `db:"payload"`
+	Traceparent string `db:"traceparent"`
+}
+
+// appendOutbox records the events of the type with the payloads to the outbox table of the current tenant.
+//
+// The trace context of the current span is stored along with the events so that the relay can link the publish to the request.
+func appendOutbox(ctx context.Context, q queryer, eventType string, payloads ...any) error {
+	if len(payloads) == 0 {
+		return nil
+	}
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	rows := make([]any, len(payloads))
+	for i, payload := range payloads {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to encode the payload of %s: %w", eventType, err)
+		}
+		rows[i] = &outboxRow{ID: xid.New().String(), Type: eventType, Payload: string(b), Traceparent: carrier.Get("traceparent")}
+	}
+	query, args, err := goqu.Dialect("mysql").Insert(outboxTable).Prepared(true).Rows(rows...).ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+	if _, err := q.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("ExecContext: %w", err)
+	}
+	return nil
+}
+
+type TenantLister interface {
+	ListTenants(ctx context.Context) ([]*Tenant, error)
+}
+
+type NewOutboxRelayOption func(r *OutboxRelay)
+
+// WithOutboxPollInterval configures the interval between the polls of the outbox tables.
+func WithOutboxPollInterval(interval time.Duration) NewOutboxRelayOption {
+	return func(r *OutboxRelay) { r.pollInterval = interval }
+}
+
+// WithOutboxBatchSize configures the max number of the events that the relay publishes per tenant in a poll.
+func WithOutboxBatchSize(n int) NewOutboxRelayOption {
+	return func(r *OutboxRelay) { r.batchSize = n }
+}
+
+// WithOutboxClaimTTL configures how long the events claimed by the relay are hidden from the other relays.
+//
+// The relay stops publishing the claimed events once the TTL passes, and the events claimed by a relay that died are published by the others after the TTL.
+func WithOutboxClaimTTL(ttl time.Duration) NewOutboxRelayOption {
+	return func(r *OutboxRelay) { r.claimTTL = ttl }
+}
+
+// NewOutboxRelay returns OutboxRelay that publishes the events in the outbox tables of the tenants listed by the lister.
+//
+// The db must be able to access the databases of all tenants because the relay runs outside of the requests bound to a tenant.
+func NewOutboxRelay(db *sqlx.DB, tenants TenantLister, publisher Publisher, optFns ...NewOutboxRelayOption) *OutboxRelay {
+	r := &OutboxRelay{
+		tracer:       otel.GetTracerProvider().Tracer("repos.OutboxRelay"),
+		db:           db,
+		tenants:      tenants,
+		publisher:    publisher,
+		pollInterval: defaultOutboxPollInterval,
+		batchSize:    defaultOutboxBatchSize,
+		claimTTL:     defaultOutboxClaimTTL,
+	}
+	for _, f := range optFns {
+		f(r)
+	}
+	return r
+}
+
+// OutboxRelay publishes the events recorded to the outbox tables and marks them as published.
+//
+// The delivery is at-least-once: an event is published again if the relay fails to mark it after the publish.
+// The events are claimed in a short transaction locking the rows with SKIP LOCKED and published outside of it,
+// so the relays of the multiple processes do not publish the same events concurrently without holding the locks and the connection during the publishes.
+type OutboxRelay struct {
+	tracer       trace.Tracer
+	db           *sqlx.DB
+	tenants      TenantLister
+	publisher    Publisher
+	pollInterval time.Duration
+	batchSize    int
+	claimTTL     time.Duration
+}
+
+// Run polls the outbox tables until the context is done.
+func (r *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+	for {
+		if err := r.RelayOnce(ctx); err != nil && ctx.Err() == nil {
+			slog.WarnContext(ctx, "failed to relay the outbox events", slog.String("error", err.Error()))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayOnce publishes the pending events of every tenant once.
+//
+// A failure of a tenant does not stop the relay of the other tenants; the errors are joined.
+func (r *OutboxRelay) RelayOnce(ctx context.Context) error {
+	tenants, err := r.tenants.ListTenants(ctx)
+	if err != nil {
+		return fmt.Errorf("ListTenants: %w", err)
+	}
+	var errs []error
+	for _, tenant := range tenants {
+		if err := r.relayTenant(ctx, tenant.ID); err != nil {
+			errs = append(errs, fmt.Errorf("tenant %s: %w", tenant.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *OutboxRelay) relayTenant(ctx context.Context, tenantID string) (err error) {
+	ctx, span := r.tracer.Start(ctx, "RelayOutbox", trace.WithAttributes(attribute.String("tenant", tenantID)))
+	defer span.End()
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			reportError(ctx, err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetStatus(codes.Ok, "")
+		}
+	}()
+
+	table := goqu.Dialect("mysql").From(goqu.S(tenantID).Table(outboxTable))
+	events, err := r.claim(ctx, table)
+	if err != nil {
+		return err
+	}
+	recordRowsReturned(span, len(events))
+	if len(events) == 0 {
+		return nil
+	}
+
+	claimedAt := time.Now()
+	published := make([]string, 0, len(events))
+	var publishErr error
+	for _, event := range events {
+		// the rest may have been claimed by the other relays
+		if time.Since(claimedAt) >= r.claimTTL {
+			break
+		}
+		event.Tenant = tenantID
+		if publishErr = r.publish(ctx, event); publishErr != nil {
+			break
+		}
+		published = append(published, event.ID)
+	}
+	// the claims that may have been taken over by the other relays must not be released
+	var unpublished []string
+	if time.Since(claimedAt) < r.claimTTL {
+		for _, event := range events[len(published):] {
+			unpublished = append(unpublished, event.ID)
+		}
+	}
+	if err := r.settle(ctx, span, table, published, unpublished); err != nil {
+		return errors.Join(publishErr, err)
+	}
+	return publishErr
+}
+
+// claim returns the pending events not claimed by the other relays and claims them for the claim TTL.
+func (r *OutboxRelay) claim(ctx context.Context, table *goqu.SelectDataset) (_ []*OutboxEvent, err error) {
+	query, args, err := table.
+		Select("id", "event_type", "payload", "traceparent", "created_at").
+		Where(
+			goqu.C("published_at").IsNull(),
+			goqu.Or(goqu.C("claimed_until").IsNull(), goqu.C("claimed_until").Lt(goqu.L("current_timestamp(6)"))),
+		).
+		Order(goqu.C("id").Asc()).
+		Limit(uint(r.batchSize)).
+		ForUpdate(exp.SkipLocked).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("BeginTxx: %w", err)
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		if rbErr := tx.Rollback(); rbErr != nil {
+			err = errors.Join(err, fmt.Errorf("Rollback: %w", rbErr))
+		}
+	}()
+	var events []*OutboxEvent
+	if err := tx.SelectContext(ctx, &events, query, args...); err != nil {
+		return nil, fmt.Errorf("SelectContext: %w", err)
+	}
+	if len(events) > 0 {
+		ids := make([]string, len(events))
+		for i, event := range events {
+			ids[i] = event.ID
+		}
+		claimQuery, claimArgs, err := table.Update().
+			Prepared(true).
+			Set(goqu.Record{"claimed_until": goqu.L("current_timestamp(6) + interval ? microsecond", r.claimTTL.Microseconds())}).
+			Where(goqu.C("id").In(ids)).
+			ToSQL()
+		if err != nil {
+			return nil, fmt.Errorf("failed to build query: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, claimQuery, claimArgs...); err != nil {
+			return nil, fmt.Errorf("ExecContext: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("Commit: %w", err)
+	}
+	return events, nil
+}
+
+// settle marks the published events and releases the claims of the rest so that they are published by the next poll.
+//
+// It is not canceled with the context, since the published events are published again unless they are marked.
+func (r *OutboxRelay) settle(ctx context.Context, span trace.Span, table *goqu.SelectDataset, published, unpublished []string) (err error) {
+	if len(published) == 0 && len(unpublished) == 0 {
+		return nil
+	}
+	ctx = context.WithoutCancel(ctx)
+	var markQuery, releaseQuery string
+	var markArgs, releaseArgs []any
+	if len(published) > 0 {
+		markQuery, markArgs, err = table.Update().
+			Prepared(true).
+			Set(goqu.Record{"published_at": goqu.L("current_timestamp(6)")}).
+			Where(goqu.C("id").In(published)).
+			ToSQL()
+		if err != nil {
+			return fmt.Errorf("failed to build query: %w", err)
+		}
+	}
+	if len(unpublished) > 0 {
+		releaseQuery, releaseArgs, err = table.Update().
+			Prepared(true).
+			Set(goqu.Record{"claimed_until": nil}).
+			Where(goqu.C("id").In(unpublished)).
+			ToSQL()
+		if err != nil {
+			return fmt.Errorf("failed to build query: %w", err)
+		}
+	}
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("BeginTxx: %w", err)
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		if rbErr := tx.Rollback(); rbErr != nil {
+			err = errors.Join(err, fmt.Errorf("Rollback: %w", rbErr))
+		}
+	}()
+	if markQuery != "" {
+		res, err := tx.ExecContext(ctx, markQuery, markArgs...)
+		if err != nil {
+			return fmt.Errorf("ExecContext: %w", err)
+		}
+		_, _ = recordRowsAffected(span, res)
+	}
+	if releaseQuery != "" {
+		if _, err := tx.ExecContext(ctx, releaseQuery, releaseArgs...); err != nil {
+			return fmt.Errorf("ExecContext: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("Commit: %w", err)
+	}
+	return nil
+}
+
+// publish publishes the event within a span linked to the trace of the request that recorded the event.
+func (r *OutboxRelay) publish(ctx context.Context, event *OutboxEvent) (err error) {
+	opts := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("tenant", event.Tenant),
+			attribute.String("outbox.event.id", event.ID),
+			attribute.String("outbox.event.type", event.Type)),
+	}
+	if event.Traceparent != "" {
+		origin := propagation.TraceContext{}.Extract(context.Background(), propagation.MapCarrier{"traceparent": event.Traceparent})
+		if sc := trace.SpanContextFromContext(origin); sc.IsValid() {
+			opts = append(opts, trace.WithLinks(trace.Link{SpanContext: sc}))
+		}
+	}
+	ctx, span := r.tracer.Start(ctx, "outbox.publish", opts...)
+	defer span.End()
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			reportError(ctx, err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetStatus(codes.Ok, "")
+		}
+	}()
+
+	if err := r.publisher.Publish(ctx, event); err != nil {
+		return fmt.Errorf("Publish: %w", err)
+	}
+	return nil
+}
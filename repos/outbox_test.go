@@ -0,0 +1,97 @@
+package repos
+
+import (
+	"context"
+	"enjoymultitenancy/dbtest"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestOutboxRelay_RelayOnce(t *testing.T) {
+	db := dbtest.StartMySQL(t)
+	errPublish := errors.New("oops")
+	testCases := []struct {
+		name string
+		// claimedByOthers are the events claimed by the other relays
+		claimedByOthers []string
+		failOn          string
+		wantPublished   []string
+		wantErr         error
+		wantState       map[string]string
+	}{
+		{
+			name:          "all published",
+			wantPublished: []string{"evt_1", "evt_2", "evt_3"},
+			wantState:     map[string]string{"evt_1": "published", "evt_2": "published", "evt_3": "published"},
+		},
+		{
+			name:          "publish failed",
+			failOn:        "evt_2",
+			wantPublished: []string{"evt_1"},
+			wantErr:       errPublish,
+			wantState:     map[string]string{"evt_1": "published", "evt_2": "pending", "evt_3": "pending"},
+		},
+		{
+			name:            "claimed by others",
+			claimedByOthers: []string{"evt_2"},
+			wantPublished:   []string{"evt_1", "evt_3"},
+			wantState:       map[string]string{"evt_1": "published", "evt_2": "claimed", "evt_3": "published"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tenant := string(db.NewTenant(t))
+			ctx := context.Background()
+			for _, id := range []string{"evt_1", "evt_2", "evt_3"} {
+				if _, err := db.DB.ExecContext(ctx, fmt.Sprintf("insert into `%s`.outbox (id, event_type, payload) values (?, ?, '{}')", tenant), id, EventUserRegistered); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, id := range tc.claimedByOthers {
+				if _, err := db.DB.ExecContext(ctx, fmt.Sprintf("update `%s`.outbox set claimed_until = current_timestamp(6) + interval 1 minute where id = ?", tenant), id); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var published []string
+			publisher := PublisherFunc(func(_ context.Context, event *OutboxEvent) error {
+				if event.ID == tc.failOn {
+					return errPublish
+				}
+				published = append(published, event.ID)
+				return nil
+			})
+			relay := NewOutboxRelay(db.DB, stubTenantLister{tenant}, publisher)
+			if err := relay.RelayOnce(ctx); !errors.Is(err, tc.wantErr) {
+				t.Errorf("error = %v, want %v", err, tc.wantErr)
+			}
+			if !slices.Equal(published, tc.wantPublished) {
+				t.Errorf("published = %v, want %v", published, tc.wantPublished)
+			}
+			var rows []struct {
+				ID    string `db:"id"`
+				State string `db:"state"`
+			}
+			query := "select id, case when published_at is not null then 'published' when claimed_until > current_timestamp(6) then 'claimed' else 'pending' end as state from `%s`.outbox"
+			if err := db.DB.SelectContext(ctx, &rows, fmt.Sprintf(query, tenant)); err != nil {
+				t.Fatal(err)
+			}
+			for _, row := range rows {
+				if state := row.State; state != tc.wantState[row.ID] {
+					t.Errorf("%s: state = %s, want %s", row.ID, state, tc.wantState[row.ID])
+				}
+			}
+		})
+	}
+}
+
+type stubTenantLister []string
+
+func (l stubTenantLister) ListTenants(context.Context) ([]*Tenant, error) {
+	tenants := make([]*Tenant, len(l))
+	for i, id := range l {
+		tenants[i] = &Tenant{ID: id}
+	}
+	return tenants, nil
+}
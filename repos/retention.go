@@ -0,0 +1,154 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	defaultRetentionInterval  = time.Hour
+	defaultRetentionBatchSize = 1000
+)
+
+// RetentionPolicy is the retention period of the rows of a table of the tenants.
+type RetentionPolicy struct {
+	table  string
+	column string
+	maxAge time.Duration
+}
+
+// OutboxRetention deletes the outbox events published more than maxAge ago; the events not published yet are kept.
+func OutboxRetention(maxAge time.Duration) RetentionPolicy {
+	return RetentionPolicy{table: outboxTable, column: "published_at", maxAge: maxAge}
+}
+
+// AuditLogRetention deletes the audit entries recorded more than maxAge ago.
+func AuditLogRetention(maxAge time.Duration) RetentionPolicy {
+	return RetentionPolicy{table: "audit_logs", column: "created_at", maxAge: maxAge}
+}
+
+type NewRetentionPrunerOption func(p *RetentionPruner)
+
+// WithRetentionInterval configures the interval between the prunes, an hour by default.
+func WithRetentionInterval(interval time.Duration) NewRetentionPrunerOption {
+	return func(p *RetentionPruner) { p.interval = interval }
+}
+
+// NewRetentionPruner returns RetentionPruner that applies the policies to the databases of the tenants listed by the lister.
+//
+// The db must be able to access the databases of all tenants because the pruner runs outside of the requests bound to a tenant.
+func NewRetentionPruner(db *sqlx.DB, tenants TenantLister, policies []RetentionPolicy, optFns ...NewRetentionPrunerOption) *RetentionPruner {
+	p := &RetentionPruner{
+		tracer:    otel.GetTracerProvider().Tracer("repos.RetentionPruner"),
+		db:        db,
+		tenants:   tenants,
+		policies:  policies,
+		interval:  defaultRetentionInterval,
+		batchSize: defaultRetentionBatchSize,
+	}
+	for _, f := range optFns {
+		f(p)
+	}
+	return p
+}
+
+// RetentionPruner deletes the rows beyond the retention periods from the tables of the tenants.
+//
+// The rows are deleted in the batches so that a prune does not hold the locks of a large number of the rows at once.
+type RetentionPruner struct {
+	tracer    trace.Tracer
+	db        *sqlx.DB
+	tenants   TenantLister
+	policies  []RetentionPolicy
+	interval  time.Duration
+	batchSize int
+}
+
+// Run prunes the tables periodically until the context is done.
+func (p *RetentionPruner) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		if err := p.PruneOnce(ctx); err != nil && ctx.Err() == nil {
+			slog.WarnContext(ctx, "failed to prune the expired rows", slog.String("error", err.Error()))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PruneOnce applies the policies to every tenant once.
+//
+// A failure of a tenant does not stop the prune of the other tenants; the errors are joined.
+func (p *RetentionPruner) PruneOnce(ctx context.Context) error {
+	tenants, err := p.tenants.ListTenants(ctx)
+	if err != nil {
+		return fmt.Errorf("ListTenants: %w", err)
+	}
+	var errs []error
+	for _, tenant := range tenants {
+		for _, policy := range p.policies {
+			if err := p.pruneTable(ctx, tenant.ID, policy); err != nil {
+				errs = append(errs, fmt.Errorf("tenant %s: %s: %w", tenant.ID, policy.table, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (p *RetentionPruner) pruneTable(ctx context.Context, tenantID string, policy RetentionPolicy) (err error) {
+	ctx, span := p.tracer.Start(ctx, "PruneTable", trace.WithAttributes(attribute.String("tenant", tenantID), attribute.String("db.sql.table", policy.table)))
+	defer span.End()
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			reportError(ctx, err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetStatus(codes.Ok, "")
+		}
+	}()
+
+	query, args, err := goqu.Dialect("mysql").Delete(goqu.S(tenantID).Table(policy.table)).
+		Prepared(true).
+		Where(goqu.C(policy.column).Lt(goqu.L("current_timestamp(6) - interval ? microsecond", policy.maxAge.Microseconds()))).
+		Order(goqu.C(policy.column).Asc()).
+		Limit(uint(p.batchSize)).
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+	var total int64
+	for {
+		res, err := p.db.ExecContext(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("ExecContext: %w", err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("RowsAffected: %w", err)
+		}
+		total += n
+		if n < int64(p.batchSize) {
+			break
+		}
+	}
+	span.SetAttributes(attrRowsAffected.Int64(total))
+	if total > 0 {
+		slog.InfoContext(ctx, "pruned the expired rows", slog.String("tenant", tenantID), slog.String("table", policy.table), slog.Int64("rows", total))
+	}
+	return nil
+}
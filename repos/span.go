@@ -0,0 +1,52 @@
+package repos
+
+import (
+	"database/sql"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	attrRowsAffected = attribute.Key("db.rows_affected")
+	attrRowsReturned = attribute.Key("db.rows_returned")
+)
+
+// PutResult tells how an upsert changed the row.
+type PutResult int
+
+const (
+	// PutUnchanged means the row already had the same values.
+	PutUnchanged PutResult = iota
+	// PutCreated means the row was inserted.
+	PutCreated
+	// PutUpdated means the existing row was updated.
+	PutUpdated
+)
+
+// putResultOf interprets the rows affected by INSERT ... ON DUPLICATE KEY UPDATE, which MySQL reports as 1 for an insert, 2 for an update and 0 for no change.
+func putResultOf(affected int64) PutResult {
+	switch affected {
+	case 1:
+		return PutCreated
+	case 0:
+		return PutUnchanged
+	default:
+		return PutUpdated
+	}
+}
+
+// recordRowsAffected sets the rows affected by the statement on the span and returns it.
+func recordRowsAffected(span trace.Span, res sql.Result) (int64, error) {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	span.SetAttributes(attrRowsAffected.Int64(n))
+	return n, nil
+}
+
+// recordRowsReturned sets the rows returned by the query on the span.
+func recordRowsReturned(span trace.Span, n int) {
+	span.SetAttributes(attrRowsReturned.Int(n))
+}
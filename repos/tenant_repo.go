@@ -0,0 +1,376 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type NewTenantRepoOption func(r *TenantRepo)
+
+// WithDB configures the database that stores the tenant registry shared by all tenants.
+func WithDB(db *sqlx.DB) NewTenantRepoOption {
+	return func(r *TenantRepo) { r.db = db }
+}
+
+func NewTenantRepo(optFns ...NewTenantRepoOption) *TenantRepo {
+	r := &TenantRepo{
+		tracer: otel.GetTracerProvider().Tracer("repos.TenantRepo"),
+	}
+	for _, f := range optFns {
+		f(r)
+	}
+	r.tables.tenants = goqu.Dialect("mysql").From("tenants")
+	r.tables.tenantDomains = goqu.Dialect("mysql").From("tenant_domains")
+	return r
+}
+
+// TenantRepo manages the registry of the tenants.
+//
+// Unlike other repos, it accesses the shared database directly instead of the connection bound to the current tenant.
+type TenantRepo struct {
+	tracer trace.Tracer
+	db     *sqlx.DB
+	tables struct {
+		tenants       *goqu.SelectDataset
+		tenantDomains *goqu.SelectDataset
+	}
+}
+
+var (
+	ErrInvalidDomain       = errors.New("invalid domain")
+	ErrInvalidTenantID     = errors.New("invalid tenant id")
+	ErrTenantAlreadyExists = errors.New("tenant already exists")
+
+	domainLabelPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+	tenantIDPattern    = regexp.MustCompile(`^[a-z0-9_]{1,64}$`)
+)
+
+type Tenant struct {
+	ID        string    `db:"id" json:"id"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	// SuspendedAt is the time the tenant was suspended at, or nil if the tenant is active.
+	SuspendedAt *time.Time `db:"suspended_at" json:"suspended_at,omitempty"`
+}
+
+// ValidateTenantID reports ErrInvalidTenantID unless the ID consists of lowercase letters, digits and underscores,
+// which is also the name of the database of the tenant.
+func ValidateTenantID(id string) error {
+	if !tenantIDPattern.MatchString(id) {
+		return ErrInvalidTenantID
+	}
+	return nil
+}
+
+// CreateTenant registers the tenant; the database of the tenant is expected to be provisioned separately.
+//
+// ErrTenantAlreadyExists is returned if the tenant is already registered.
+func (r *TenantRepo) CreateTenant(ctx context.Context, id string) (_ *Tenant, err error) {
+	ctx, span := r.tracer.Start(ctx, "CreateTenant", trace.WithAttributes(attribute.String("tenant.id", id)))
+	defer span.End()
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			reportError(ctx, err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetStatus(codes.Ok, "")
+		}
+	}()
+
+	if err := ValidateTenantID(id); err != nil {
+		return nil, err
+	}
+	query, args, err := r.tables.tenants.Insert().
+		Prepared(true).
+		Rows(goqu.Record{"id": id}).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+	if _, err := r.db.ExecContext(ctx, query, args...); err != nil {
+		if isDuplicateEntry(err) {
+			return nil, ErrTenantAlreadyExists
+		}
+		return nil, fmt.Errorf("ExecContext: %w", err)
+	}
+	return r.findTenant(ctx, id)
+}
+
+// SuspendTenant marks the tenant suspended, which makes the server reject the requests to the tenant.
+//
+// Suspending the suspended tenant is no-op; ErrNotFound is returned if the tenant does not exist.
+func (r *TenantRepo) SuspendTenant(ctx context.Context, id string) (err error) {
+	ctx, span := r.tracer.Start(ctx, "SuspendTenant", trace.WithAttributes(attribute.String("tenant.id", id)))
+	defer span.End()
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			reportError(ctx, err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetStatus(codes.Ok, "")
+		}
+	}()
+
+	query, args, err := r.tables.tenants.Update().
+		Prepared(true).
+		Set(goqu.Record{"suspended_at": goqu.L("current_timestamp")}).
+		Where(goqu.C("id").Eq(id), goqu.C("suspended_at").IsNull()).
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("ExecContext: %w", err)
+	}
+	if n, err := recordRowsAffected(span, res); err == nil && n == 0 {
+		if _, err := r.findTenant(ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FindTenant returns the registered tenant, including the suspended one; ErrNotFound is returned if the tenant does not exist.
+func (r *TenantRepo) FindTenant(ctx context.Context, id string) (_ *Tenant, err error) {
+	ctx, span := r.tracer.Start(ctx, "FindTenant", trace.WithAttributes(attribute.String("tenant.id", id)))
+	defer span.End()
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			reportError(ctx, err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetStatus(codes.Ok, "")
+		}
+	}()
+
+	return r.findTenant(ctx, id)
+}
+
+func (r *TenantRepo) findTenant(ctx context.Context, id string) (*Tenant, error) {
+	query, args, err := r.tables.tenants.Where(goqu.C("id").Eq(id)).Limit(1).ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+	tenant := new(Tenant)
+	if err := r.db.GetContext(ctx, tenant, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return tenant, nil
+}
+
+func (r *TenantRepo) ListTenants(ctx context.Context) (_ []*Tenant, err error) {
+	ctx, span := r.tracer.Start(ctx, "ListTenants")
+	defer span.End()
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			reportError(ctx, err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetStatus(codes.Ok, "")
+		}
+	}()
+
+	query, args, err := r.tables.tenants.Order(goqu.C("id").Asc()).ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+	var tenants []*Tenant
+	if err := r.db.SelectContext(ctx, &tenants, query, args...); err != nil {
+		return nil, err
+	}
+	recordRowsReturned(span, len(tenants))
+	return tenants, nil
+}
+
+// TenantDomain is a custom domain brought by the tenant.
+type TenantDomain struct {
+	Domain   string `db:"domain" json:"domain"`
+	TenantID string `db:"tenant_id" json:"tenant_id"`
+}
+
+// NormalizeDomain validates the domain and returns its canonical form.
+func NormalizeDomain(domain string) (string, error) {
+	d := strings.TrimSuffix(strings.ToLower(domain), ".")
+	if d == "" || len(d) > 253 {
+		return "", ErrInvalidDomain
+	}
+	labels := strings.Split(d, ".")
+	if len(labels) < 2 {
+		return "", ErrInvalidDomain
+	}
+	for _, label := range labels {
+		if !domainLabelPattern.MatchString(label) {
+			return "", ErrInvalidDomain
+		}
+	}
+	return d, nil
+}
+
+func (r *TenantRepo) FindTenantByDomain(ctx context.Context, domain string) (_ *Tenant, err error) {
+	ctx, span := r.tracer.Start(ctx, "FindTenantByDomain", trace.WithAttributes(attribute.String("tenant.domain", domain)))
+	defer span.End()
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			reportError(ctx, err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetStatus(codes.Ok, "")
+		}
+	}()
+
+	domain, err = NormalizeDomain(domain)
+	if err != nil {
+		return nil, err
+	}
+	query, args, err := r.tables.tenants.
+		Select(goqu.I("tenants.id"), goqu.I("tenants.created_at"), goqu.I("tenants.suspended_at")).
+		InnerJoin(goqu.T("tenant_domains"), goqu.On(goqu.I("tenant_domains.tenant_id").Eq(goqu.I("tenants.id")))).
+		Where(goqu.I("tenant_domains.domain").Eq(domain), goqu.I("tenants.suspended_at").IsNull()).
+		Limit(1).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+	tenant := new(Tenant)
+	if err := r.db.GetContext(ctx, tenant, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			recordRowsReturned(span, 0)
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	recordRowsReturned(span, 1)
+	return tenant, nil
+}
+
+func (r *TenantRepo) ListDomains(ctx context.Context) (_ []*TenantDomain, err error) {
+	ctx, span := r.tracer.Start(ctx, "ListDomains")
+	defer span.End()
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			reportError(ctx, err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetStatus(codes.Ok, "")
+		}
+	}()
+
+	query, args, err := r.tables.tenantDomains.Order(goqu.C("domain").Asc()).ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+	var domains []*TenantDomain
+	if err := r.db.SelectContext(ctx, &domains, query, args...); err != nil {
+		return nil, err
+	}
+	recordRowsReturned(span, len(domains))
+	return domains, nil
+}
+
+// PutDomain maps the domain to the tenant, replacing the existing mapping of the domain.
+//
+// The result tells whether the mapping was created, moved from another tenant or unchanged.
+// ErrNotFound is returned if the tenant does not exist.
+func (r *TenantRepo) PutDomain(ctx context.Context, domain string, tenantID string) (_ *TenantDomain, _ PutResult, err error) {
+	ctx, span := r.tracer.Start(ctx, "PutDomain", trace.WithAttributes(attribute.String("tenant.domain", domain), attribute.String("tenant.id", tenantID)))
+	defer span.End()
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			reportError(ctx, err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetStatus(codes.Ok, "")
+		}
+	}()
+
+	domain, err = NormalizeDomain(domain)
+	if err != nil {
+		return nil, PutUnchanged, err
+	}
+	existsQuery, existsArgs, err := r.tables.tenants.Select(goqu.C("id")).Where(goqu.C("id").Eq(tenantID)).Limit(1).ToSQL()
+	if err != nil {
+		return nil, PutUnchanged, fmt.Errorf("failed to build query: %w", err)
+	}
+	var id string
+	if err := r.db.GetContext(ctx, &id, existsQuery, existsArgs...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, PutUnchanged, ErrNotFound
+		}
+		return nil, PutUnchanged, err
+	}
+
+	td := &TenantDomain{Domain: domain, TenantID: tenantID}
+	query, args, err := r.tables.tenantDomains.Insert().
+		Prepared(true).
+		Rows(td).
+		OnConflict(goqu.DoUpdate("domain", goqu.Record{"tenant_id": tenantID})).
+		ToSQL()
+	if err != nil {
+		return nil, PutUnchanged, fmt.Errorf("failed to build query: %w", err)
+	}
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, PutUnchanged, fmt.Errorf("ExecContext: %w", err)
+	}
+	affected, err := recordRowsAffected(span, res)
+	if err != nil {
+		return nil, PutUnchanged, fmt.Errorf("RowsAffected: %w", err)
+	}
+	return td, putResultOf(affected), nil
+}
+
+func (r *TenantRepo) DeleteDomain(ctx context.Context, domain string) (err error) {
+	ctx, span := r.tracer.Start(ctx, "DeleteDomain", trace.WithAttributes(attribute.String("tenant.domain", domain)))
+	defer span.End()
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			reportError(ctx, err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetStatus(codes.Ok, "")
+		}
+	}()
+
+	domain, err = NormalizeDomain(domain)
+	if err != nil {
+		return err
+	}
+	query, args, err := r.tables.tenantDomains.Delete().
+		Prepared(true).
+		Where(goqu.C("domain").Eq(domain)).
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("ExecContext: %w", err)
+	}
+	if n, err := recordRowsAffected(span, res); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
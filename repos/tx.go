@@ -0,0 +1,95 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"enjoymultitenancy/adapters"
+	"github.com/aereal/nagaya"
+	"github.com/jmoiron/sqlx"
+)
+
+type (
+	txCtxKey          struct{}
+	afterCommitCtxKey struct{}
+)
+
+func NewTransactor(ngy *nagaya.Nagaya[*sqlx.DB, *sqlx.Conn]) *Transactor {
+	return &Transactor{ngy: ngy}
+}
+
+// Transactor runs functions within transactions that span the calls to the repos.
+type Transactor struct {
+	ngy *nagaya.Nagaya[*sqlx.DB, *sqlx.Conn]
+}
+
+// RunInTx runs the function within a transaction on the connection bound to the current tenant.
+//
+// The repos called with the context passed to the function use the transaction, so their writes are committed only if the function returns no error.
+// If the context already has a transaction, the function joins it.
+// The functions registered by afterCommit within the transaction are called after it commits, and dropped if it rolls back.
+func (t *Transactor) RunInTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if _, ok := ctx.Value(txCtxKey{}).(*sqlx.Tx); ok {
+		return fn(ctx)
+	}
+	conn, err := t.ngy.ObtainConnection(ctx)
+	if err != nil {
+		return err
+	}
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("BeginTxx: %w", err)
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		if rbErr := tx.Rollback(); rbErr != nil {
+			err = errors.Join(err, fmt.Errorf("Rollback: %w", rbErr))
+		}
+	}()
+	var afterCommitFns []func()
+	txCtx := context.WithValue(context.WithValue(ctx, txCtxKey{}, tx), afterCommitCtxKey{}, &afterCommitFns)
+	if err := fn(txCtx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("Commit: %w", err)
+	}
+	for _, f := range afterCommitFns {
+		f()
+	}
+	return nil
+}
+
+// afterCommit calls the function after the transaction started by RunInTx commits, or immediately if the context has no transaction.
+func afterCommit(ctx context.Context, fn func()) {
+	if fns, ok := ctx.Value(afterCommitCtxKey{}).(*[]func()); ok {
+		*fns = append(*fns, fn)
+		return
+	}
+	fn()
+}
+
+// queryer is a subset of the methods shared by *sqlx.Conn and *sqlx.Tx.
+type queryer interface {
+	sqlx.ExecerContext
+	sqlx.QueryerContext
+}
+
+// obtainQueryer returns the transaction started by RunInTx if any, or the connection bound to the current tenant.
+func obtainQueryer(ctx context.Context, ngy *nagaya.Nagaya[*sqlx.DB, *sqlx.Conn]) (queryer, error) {
+	if tx, ok := ctx.Value(txCtxKey{}).(*sqlx.Tx); ok {
+		return tx, nil
+	}
+	return ngy.ObtainConnection(ctx)
+}
+
+// withRetry calls the function with adapters.Retry unless the context has a transaction started by RunInTx; a transient error within a transaction must be retried by the caller of RunInTx.
+func withRetry(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txCtxKey{}).(*sqlx.Tx); ok {
+		return fn(ctx)
+	}
+	return adapters.Retry(ctx, fn)
+}
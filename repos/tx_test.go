@@ -0,0 +1,59 @@
+package repos
+
+import (
+	"context"
+	"enjoymultitenancy/dbtest"
+	"errors"
+	"testing"
+)
+
+func TestAfterCommit_noTx(t *testing.T) {
+	called := false
+	afterCommit(context.Background(), func() { called = true })
+	if !called {
+		t.Error("the function is not called immediately without a transaction")
+	}
+}
+
+func TestUserRepo_writeHooksAfterCommit(t *testing.T) {
+	db := dbtest.StartMySQL(t)
+	tenant := db.NewTenant(t)
+	errRollback := errors.New("rollback")
+	testCases := []struct {
+		name       string
+		fnErr      error
+		wantFired  bool
+		wantExists bool
+	}{
+		{name: "committed", wantFired: true, wantExists: true},
+		{name: "rolled back", fnErr: errRollback, wantFired: false, wantExists: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fired := false
+			repo := NewUserRepo(WithNagaya(db.Nagaya), WithUserWriteHook(func(context.Context, string) { fired = true }))
+			name := "hook_" + tc.name[:1]
+			db.RunInTenant(t, tenant, func(ctx context.Context) {
+				err := NewTransactor(db.Nagaya).RunInTx(ctx, func(ctx context.Context) error {
+					if _, err := repo.RegisterUser(ctx, &UserToRegister{Name: name}); err != nil {
+						return err
+					}
+					if fired {
+						t.Error("the hook is fired before the commit")
+					}
+					return tc.fnErr
+				})
+				if !errors.Is(err, tc.fnErr) {
+					t.Fatalf("RunInTx: %v", err)
+				}
+				if fired != tc.wantFired {
+					t.Errorf("fired = %v, want %v", fired, tc.wantFired)
+				}
+				_, err = repo.FetchUserByName(ctx, name)
+				if exists := err == nil; exists != tc.wantExists {
+					t.Errorf("exists = %v (err=%v), want %v", exists, err, tc.wantExists)
+				}
+			})
+		})
+	}
+}
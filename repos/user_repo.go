@@ -5,29 +5,143 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/aereal/nagaya"
 	"github.com/doug-martin/goqu/v9"
 	_ "github.com/doug-martin/goqu/v9/dialect/mysql"
+	"github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 	"github.com/rs/xid"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/text/cases"
 )
 
 var (
-	ErrUserNameRequired = errors.New("user.name is required")
-	ErrNotFound         = errors.New("not found")
+	ErrUserNameRequired  error = &ValidationError{Resource: "user", Field: "name", Rule: "required"}
+	ErrNotFound                = errors.New("not found")
+	ErrNothingToUpdate         = errors.New("nothing to update")
+	ErrUserAlreadyExists       = errors.New("user already exists")
+
+	userFields = []string{"id", "name"}
 )
 
+const mysqlErrDuplicateEntry = 1062
+
+// isDuplicateEntry reports whether the error is the violation of the unique key.
+func isDuplicateEntry(err error) bool {
+	var myErr *mysql.MySQLError
+	return errors.As(err, &myErr) && myErr.Number == mysqlErrDuplicateEntry
+}
+
+// RowError is the error of a row in the bulk operation.
+type RowError struct {
+	Index int
+	Err   error
+}
+
+// UserImportError is an error type represents RegisterUsers rejected the rows; nothing is imported.
+type UserImportError struct {
+	Rows []*RowError
+}
+
+func (e *UserImportError) Error() string {
+	return fmt.Sprintf("%d rows are rejected", len(e.Rows))
+}
+
+// UnknownFieldError is an error type represents the requested field does not exist on the resource.
+type UnknownFieldError struct {
+	Field string
+}
+
+func (e *UnknownFieldError) Error() string {
+	return fmt.Sprintf("unknown field: %q", e.Field)
+}
+
+type fetchUserConfig struct {
+	fields []string
+}
+
+type FetchUserOption func(cfg *fetchUserConfig)
+
+// WithUserFields makes the repo fetch only the given fields of the user; other fields are left zero.
+func WithUserFields(fields ...string) FetchUserOption {
+	return func(cfg *fetchUserConfig) { cfg.fields = fields }
+}
+
+var (
+	defaultListUsersLimit = 50
+	maxListUsersLimit     = 100
+)
+
+type listUsersConfig struct {
+	after      string
+	limit      int
+	namePrefix string
+}
+
+type ListUsersOption func(cfg *listUsersConfig)
+
+// WithUsersAfter makes ListUsers return the users following the cursor returned as UserPage.NextCursor.
+func WithUsersAfter(cursor string) ListUsersOption {
+	return func(cfg *listUsersConfig) { cfg.after = cursor }
+}
+
+// WithUsersLimit configures the max number of the users in a page; it is capped at 100.
+func WithUsersLimit(limit int) ListUsersOption {
+	return func(cfg *listUsersConfig) { cfg.limit = limit }
+}
+
+// WithNamePrefix makes ListUsers return only the users whose name starts with the prefix, compared by the collation of the column.
+func WithNamePrefix(prefix string) ListUsersOption {
+	return func(cfg *listUsersConfig) { cfg.namePrefix = prefix }
+}
+
+// UserPage is a page of the users; NextCursor is empty on the last page.
+type UserPage struct {
+	Users      []*User
+	NextCursor string
+}
+
+// UserUpdate is the set of the changes UpdateUser applies; the nil fields are left unchanged.
+type UserUpdate struct {
+	Name *string `validate:"required,max=255,printable,excludes=/"`
+}
+
+// Validate checks the given fields against the same rules as UserToRegister.
+func (u *UserUpdate) Validate() error {
+	return validateStruct("user", u)
+}
+
+type UpdateUserOption func(u *UserUpdate)
+
+// WithNewUserName makes UpdateUser rename the user.
+func WithNewUserName(name string) UpdateUserOption {
+	return func(u *UserUpdate) { u.Name = &name }
+}
+
 type NewUserRepoOption func(r *UserRepo)
 
 func WithNagaya(ngy *nagaya.Nagaya[*sqlx.DB, *sqlx.Conn]) NewUserRepoOption {
 	return func(r *UserRepo) { r.ngy = ngy }
 }
 
+// WithOutbox makes the repo record the domain events such as EventUserRegistered to the outbox table in the same transaction as the writes.
+//
+// The events are delivered by OutboxRelay.
+func WithOutbox() NewUserRepoOption {
+	return func(r *UserRepo) { r.outbox = true }
+}
+
+// WithUserWriteHook registers the hook fired after the users are written, or after the transaction commits if the write is within RunInTx.
+func WithUserWriteHook(hook WriteHook) NewUserRepoOption {
+	return func(r *UserRepo) { r.writeHooks = append(r.writeHooks, hook) }
+}
+
 func NewUserRepo(optFns ...NewUserRepoOption) *UserRepo {
 	r := &UserRepo{
 		tracer: otel.GetTracerProvider().Tracer("repos.UserRepo"),
@@ -40,15 +154,36 @@ func NewUserRepo(optFns ...NewUserRepoOption) *UserRepo {
 }
 
 type UserRepo struct {
-	tracer trace.Tracer
-	ngy    *nagaya.Nagaya[*sqlx.DB, *sqlx.Conn]
-	tables struct {
+	tracer     trace.Tracer
+	ngy        *nagaya.Nagaya[*sqlx.DB, *sqlx.Conn]
+	writeHooks []WriteHook
+	outbox     bool
+	tables     struct {
 		users *goqu.SelectDataset
 	}
 }
 
+// fireWriteHooks fires the hooks after the transaction commits if the context has one, so that the readers do not cache the uncommitted writes.
+func (r *UserRepo) fireWriteHooks(ctx context.Context) {
+	if len(r.writeHooks) == 0 {
+		return
+	}
+	afterCommit(ctx, func() {
+		for _, hook := range r.writeHooks {
+			hook(ctx, resourceUsers)
+		}
+	})
+}
+
 type UserToRegister struct {
-	Name string `json:"name" db:"name"`
+	Name string `json:"name" db:"name" validate:"required,max=255,printable,excludes=/"`
+}
+
+// Validate checks the fields against the rules in their validate tags; the violation is reported as ValidationError.
+//
+// The name must not contain slashes because it is a path segment of the user's URL.
+func (u *UserToRegister) Validate() error {
+	return validateStruct("user", u)
 }
 
 type userToRegisterDTO struct {
@@ -61,47 +196,89 @@ type User struct {
 	Name string `db:"name"`
 }
 
-func (r *UserRepo) RegisterUser(ctx context.Context, user *UserToRegister) (err error) {
+// RegisterUser registers the user and returns it with the generated ID.
+func (r *UserRepo) RegisterUser(ctx context.Context, user *UserToRegister) (_ *User, err error) {
 	ctx, span := r.tracer.Start(ctx, "RegisterUser")
 	defer span.End()
 	defer func() {
 		if err != nil {
 			span.RecordError(err)
+			reportError(ctx, err)
 			span.SetStatus(codes.Error, err.Error())
 		} else {
 			span.SetStatus(codes.Ok, "")
 		}
 	}()
 
-	if user == nil || user.Name == "" {
-		return ErrUserNameRequired
+	if user == nil {
+		return nil, ErrUserNameRequired
+	}
+	if err := user.Validate(); err != nil {
+		return nil, err
 	}
 
+	dto := &userToRegisterDTO{UserToRegister: user, ID: xid.New().String()}
 	query, args, err := r.tables.users.Insert().
 		Prepared(true).
-		Rows(&userToRegisterDTO{UserToRegister: user, ID: xid.New().String()}).
+		Rows(dto).
 		ToSQL()
 	if err != nil {
-		return fmt.Errorf("failed to build query: %w", err)
+		return nil, fmt.Errorf("failed to build query: %w", err)
 	}
 
-	conn, err := r.ngy.ObtainConnection(ctx)
+	registered := &User{ID: dto.ID, Name: user.Name}
+	err = r.runInOutboxTx(ctx, func(ctx context.Context) error {
+		q, err := obtainQueryer(ctx, r.ngy)
+		if err != nil {
+			return err
+		}
+		err = withRetry(ctx, func(ctx context.Context) error {
+			res, err := q.ExecContext(ctx, query, args...)
+			if err != nil {
+				return err
+			}
+			_, err = recordRowsAffected(span, res)
+			return err
+		})
+		if isDuplicateEntry(err) {
+			return ErrUserAlreadyExists
+		}
+		if err != nil {
+			return fmt.Errorf("ExecContext: %w", err)
+		}
+		return r.recordEvents(ctx, q, EventUserRegistered, registered)
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if _, err := conn.ExecContext(ctx, query, args...); err != nil {
-		return fmt.Errorf("ExecContext: %w", err)
+	r.fireWriteHooks(ctx)
+
+	return registered, nil
+}
+
+// runInOutboxTx runs the function within a transaction if the outbox is enabled, so that the events are recorded atomically with the write.
+func (r *UserRepo) runInOutboxTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !r.outbox {
+		return fn(ctx)
 	}
+	return NewTransactor(r.ngy).RunInTx(ctx, fn)
+}
 
-	return nil
+// recordEvents appends the events to the outbox if it is enabled.
+func (r *UserRepo) recordEvents(ctx context.Context, q queryer, eventType string, payloads ...any) error {
+	if !r.outbox {
+		return nil
+	}
+	return appendOutbox(ctx, q, eventType, payloads...)
 }
 
-func (r *UserRepo) FetchUserByName(ctx context.Context, name string) (_ *User, err error) {
+func (r *UserRepo) FetchUserByName(ctx context.Context, name string, opts ...FetchUserOption) (_ *User, err error) {
 	ctx, span := r.tracer.Start(ctx, "FetchUserByName", trace.WithAttributes(attribute.String("user.name", name)))
 	defer span.End()
 	defer func() {
 		if err != nil {
 			span.RecordError(err)
+			reportError(ctx, err)
 			span.SetStatus(codes.Error, err.Error())
 		} else {
 			span.SetStatus(codes.Ok, "")
@@ -111,8 +288,23 @@ func (r *UserRepo) FetchUserByName(ctx context.Context, name string) (_ *User, e
 	if name == "" {
 		return nil, ErrUserNameRequired
 	}
+	cfg := new(fetchUserConfig)
+	for _, o := range opts {
+		o(cfg)
+	}
+	cols := make([]any, 0, len(cfg.fields))
+	for _, f := range cfg.fields {
+		if !slices.Contains(userFields, f) {
+			return nil, &UnknownFieldError{Field: f}
+		}
+		cols = append(cols, goqu.C(f))
+	}
+	ds := r.tables.users
+	if len(cols) > 0 {
+		ds = ds.Select(cols...)
+	}
 
-	query, args, err := r.tables.users.
+	query, args, err := ds.
 		Where(goqu.C("name").Eq(name)).
 		Limit(1).
 		ToSQL()
@@ -120,16 +312,339 @@ func (r *UserRepo) FetchUserByName(ctx context.Context, name string) (_ *User, e
 		return nil, fmt.Errorf("failed to build query: %w", err)
 	}
 
-	conn, err := r.ngy.ObtainConnection(ctx)
+	q, err := obtainQueryer(ctx, r.ngy)
 	if err != nil {
 		return nil, err
 	}
 	user := new(User)
-	if err := conn.GetContext(ctx, user, query, args...); err != nil {
+	err = withRetry(ctx, func(ctx context.Context) error {
+		return sqlx.GetContext(ctx, q, user, query, args...)
+	})
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
+			recordRowsReturned(span, 0)
 			return nil, ErrNotFound
 		}
 		return nil, err
 	}
+	recordRowsReturned(span, 1)
 	return user, nil
 }
+
+// UpdateUser updates the fields of the user given by the options and returns the updated user.
+func (r *UserRepo) UpdateUser(ctx context.Context, name string, opts ...UpdateUserOption) (_ *User, err error) {
+	ctx, span := r.tracer.Start(ctx, "UpdateUser", trace.WithAttributes(attribute.String("user.name", name)))
+	defer span.End()
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			reportError(ctx, err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetStatus(codes.Ok, "")
+		}
+	}()
+
+	if name == "" {
+		return nil, ErrUserNameRequired
+	}
+	update := new(UserUpdate)
+	for _, o := range opts {
+		o(update)
+	}
+	if err := update.Validate(); err != nil {
+		return nil, err
+	}
+	record := goqu.Record{}
+	newName := name
+	if update.Name != nil {
+		record["name"] = *update.Name
+		newName = *update.Name
+	}
+	if len(record) == 0 {
+		return nil, ErrNothingToUpdate
+	}
+
+	query, args, err := r.tables.users.Update().
+		Prepared(true).
+		Set(record).
+		Where(goqu.C("name").Eq(name)).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	q, err := obtainQueryer(ctx, r.ngy)
+	if err != nil {
+		return nil, err
+	}
+	var affected int64
+	err = withRetry(ctx, func(ctx context.Context) error {
+		res, err := q.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		affected, err = recordRowsAffected(span, res)
+		return err
+	})
+	if isDuplicateEntry(err) {
+		return nil, ErrUserAlreadyExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ExecContext: %w", err)
+	}
+	if affected == 0 {
+		// MySQL reports no affected rows if the values are unchanged, so tell it apart from the missing user.
+		if _, err := r.FetchUserByName(ctx, name); err != nil {
+			return nil, err
+		}
+	}
+	r.fireWriteHooks(ctx)
+
+	return r.FetchUserByName(ctx, newName)
+}
+
+// DeleteUser deletes the user.
+func (r *UserRepo) DeleteUser(ctx context.Context, name string) (err error) {
+	ctx, span := r.tracer.Start(ctx, "DeleteUser", trace.WithAttributes(attribute.String("user.name", name)))
+	defer span.End()
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			reportError(ctx, err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetStatus(codes.Ok, "")
+		}
+	}()
+
+	if name == "" {
+		return ErrUserNameRequired
+	}
+
+	query, args, err := r.tables.users.Delete().
+		Prepared(true).
+		Where(goqu.C("name").Eq(name)).
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("failed to build query: %w", err)
+	}
+
+	q, err := obtainQueryer(ctx, r.ngy)
+	if err != nil {
+		return err
+	}
+	var affected int64
+	err = withRetry(ctx, func(ctx context.Context) error {
+		res, err := q.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		affected, err = recordRowsAffected(span, res)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("ExecContext: %w", err)
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	r.fireWriteHooks(ctx)
+
+	return nil
+}
+
+// ListUsers returns the users in the order of their creation.
+//
+// The users are paginated by the keyset of the ID, which is ordered by the creation time since it is an xid.
+func (r *UserRepo) ListUsers(ctx context.Context, opts ...ListUsersOption) (_ *UserPage, err error) {
+	ctx, span := r.tracer.Start(ctx, "ListUsers")
+	defer span.End()
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			reportError(ctx, err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetStatus(codes.Ok, "")
+		}
+	}()
+
+	cfg := &listUsersConfig{limit: defaultListUsersLimit}
+	for _, o := range opts {
+		o(cfg)
+	}
+	if cfg.limit <= 0 {
+		cfg.limit = defaultListUsersLimit
+	}
+	cfg.limit = min(cfg.limit, maxListUsersLimit)
+
+	ds := r.tables.users.Order(goqu.C("id").Asc()).Limit(uint(cfg.limit + 1))
+	if cfg.after != "" {
+		ds = ds.Where(goqu.C("id").Gt(cfg.after))
+	}
+	if cfg.namePrefix != "" {
+		ds = ds.Where(goqu.C("name").ILike(escapeLike(cfg.namePrefix) + "%"))
+	}
+	query, args, err := ds.Prepared(true).ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	q, err := obtainQueryer(ctx, r.ngy)
+	if err != nil {
+		return nil, err
+	}
+	var users []*User
+	err = withRetry(ctx, func(ctx context.Context) error {
+		users = nil
+		return sqlx.SelectContext(ctx, q, &users, query, args...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	recordRowsReturned(span, len(users))
+	page := &UserPage{Users: users}
+	if len(users) > cfg.limit {
+		page.Users = users[:cfg.limit]
+		page.NextCursor = page.Users[cfg.limit-1].ID
+	}
+	return page, nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike escapes the wildcards of LIKE patterns in the string.
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+var userImportChunkSize = 500
+
+// userNameKey returns the key to compare the user names the way the collation of users.name (utf8mb4_unicode_ci) does, ignoring the case and the trailing spaces.
+func userNameKey(name string) string {
+	return cases.Fold().String(strings.TrimRight(name, " "))
+}
+
+// RegisterUsers registers the users within a transaction, inserting them in chunks.
+//
+// If any of the users has no name, or its name is duplicated in the input or already registered ignoring the case, it returns UserImportError that reports every such row,
+// and no user is registered.
+// The registered users are returned with the generated IDs in the order of the input.
+func (r *UserRepo) RegisterUsers(ctx context.Context, users []*UserToRegister) (_ []*User, err error) {
+	ctx, span := r.tracer.Start(ctx, "RegisterUsers", trace.WithAttributes(attribute.Int("users.count", len(users))))
+	defer span.End()
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			reportError(ctx, err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetStatus(codes.Ok, "")
+		}
+	}()
+
+	importErr := new(UserImportError)
+	indexByName := make(map[string]int, len(users))
+	for i, user := range users {
+		if user == nil {
+			importErr.Rows = append(importErr.Rows, &RowError{Index: i, Err: ErrUserNameRequired})
+			continue
+		}
+		if err := user.Validate(); err != nil {
+			importErr.Rows = append(importErr.Rows, &RowError{Index: i, Err: err})
+			continue
+		}
+		key := userNameKey(user.Name)
+		switch {
+		case indexByName[key] > 0:
+			importErr.Rows = append(importErr.Rows, &RowError{Index: i, Err: ErrUserAlreadyExists})
+		default:
+			indexByName[key] = i + 1
+		}
+	}
+
+	var imported int64
+	registered := make([]*User, 0, len(users))
+	err = NewTransactor(r.ngy).RunInTx(ctx, func(ctx context.Context) error {
+		q, err := obtainQueryer(ctx, r.ngy)
+		if err != nil {
+			return err
+		}
+		for _, chunk := range chunkSlice(users, userImportChunkSize) {
+			names := make([]string, 0, len(chunk))
+			for _, user := range chunk {
+				if user != nil && user.Name != "" {
+					names = append(names, user.Name)
+				}
+			}
+			if len(names) == 0 {
+				continue
+			}
+			query, args, err := r.tables.users.Select(goqu.C("name")).Prepared(true).Where(goqu.C("name").In(names)).ToSQL()
+			if err != nil {
+				return fmt.Errorf("failed to build query: %w", err)
+			}
+			var existing []string
+			if err := sqlx.SelectContext(ctx, q, &existing, query, args...); err != nil {
+				return err
+			}
+			for _, name := range existing {
+				// the names equal only by the collation beyond userNameKey, such as accents, are left to the unique key
+				if idx := indexByName[userNameKey(name)]; idx > 0 {
+					importErr.Rows = append(importErr.Rows, &RowError{Index: idx - 1, Err: ErrUserAlreadyExists})
+				}
+			}
+		}
+		if len(importErr.Rows) > 0 {
+			slices.SortFunc(importErr.Rows, func(a, b *RowError) int { return a.Index - b.Index })
+			return importErr
+		}
+		for _, chunk := range chunkSlice(users, userImportChunkSize) {
+			rows := make([]any, len(chunk))
+			events := make([]any, len(chunk))
+			for i, user := range chunk {
+				dto := &userToRegisterDTO{UserToRegister: user, ID: xid.New().String()}
+				rows[i] = dto
+				u := &User{ID: dto.ID, Name: user.Name}
+				events[i] = u
+				registered = append(registered, u)
+			}
+			query, args, err := r.tables.users.Insert().Prepared(true).Rows(rows...).ToSQL()
+			if err != nil {
+				return fmt.Errorf("failed to build query: %w", err)
+			}
+			res, err := q.ExecContext(ctx, query, args...)
+			if err != nil {
+				if isDuplicateEntry(err) {
+					return ErrUserAlreadyExists
+				}
+				return fmt.Errorf("ExecContext: %w", err)
+			}
+			if n, err := res.RowsAffected(); err == nil {
+				imported += n
+			}
+			if err := r.recordEvents(ctx, q, EventUserRegistered, events...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attrRowsAffected.Int64(imported))
+	r.fireWriteHooks(ctx)
+	return registered, nil
+}
+
+func chunkSlice[T any](s []T, size int) [][]T {
+	chunks := make([][]T, 0, (len(s)+size-1)/size)
+	for size < len(s) {
+		s, chunks = s[size:], append(chunks, s[:size:size])
+	}
+	if len(s) > 0 {
+		chunks = append(chunks, s)
+	}
+	return chunks
+}
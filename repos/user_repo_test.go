@@ -0,0 +1,160 @@
+package repos
+
+import (
+	"context"
+	"enjoymultitenancy/dbtest"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestEscapeLike(t *testing.T) {
+	testCases := []struct {
+		input string
+		want  string
+	}{
+		{input: "alice", want: "alice"},
+		{input: "", want: ""},
+		{input: "100%", want: `100\%`},
+		{input: "a_b", want: `a\_b`},
+		{input: `C:\tmp`, want: `C:\\tmp`},
+		{input: `%_\`, want: `\%\_\\`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			if got := escapeLike(tc.input); got != tc.want {
+				t.Errorf("escapeLike(%q) = %q, want %q", tc.input, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestUserRepo_ListUsers_cursor(t *testing.T) {
+	db := dbtest.StartMySQL(t)
+	tenant := db.NewTenant(t)
+	repo := NewUserRepo(WithNagaya(db.Nagaya))
+
+	var registered []*User
+	db.RunInTenant(t, tenant, func(ctx context.Context) {
+		users, err := repo.RegisterUsers(ctx, []*UserToRegister{{Name: "user_a"}, {Name: "user_b"}, {Name: "user_c"}, {Name: "user_d"}, {Name: "user_e"}})
+		if err != nil {
+			t.Fatalf("RegisterUsers: %s", err)
+		}
+		registered = users
+	})
+
+	testCases := []struct {
+		name      string
+		limit     int
+		wantPages [][]string
+	}{
+		{name: "limit divides", limit: 5, wantPages: [][]string{{"user_a", "user_b", "user_c", "user_d", "user_e"}}},
+		{name: "limit does not divide", limit: 2, wantPages: [][]string{{"user_a", "user_b"}, {"user_c", "user_d"}, {"user_e"}}},
+		{name: "limit exceeds", limit: 10, wantPages: [][]string{{"user_a", "user_b", "user_c", "user_d", "user_e"}}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db.RunInTenant(t, tenant, func(ctx context.Context) {
+				var gotPages [][]string
+				cursor := ""
+				for {
+					page, err := repo.ListUsers(ctx, WithUsersLimit(tc.limit), WithUsersAfter(cursor))
+					if err != nil {
+						t.Fatalf("ListUsers: %s", err)
+					}
+					names := make([]string, 0, len(page.Users))
+					for _, u := range page.Users {
+						names = append(names, u.Name)
+					}
+					gotPages = append(gotPages, names)
+					if page.NextCursor == "" {
+						break
+					}
+					if len(gotPages) > len(registered) {
+						t.Fatalf("ListUsers does not terminate: %v", gotPages)
+					}
+					cursor = page.NextCursor
+				}
+				if !slices.EqualFunc(gotPages, tc.wantPages, slices.Equal[[]string]) {
+					t.Errorf("pages = %v, want %v", gotPages, tc.wantPages)
+				}
+			})
+		})
+	}
+}
+
+func TestUserNameKey(t *testing.T) {
+	testCases := []struct {
+		a, b string
+		want bool
+	}{
+		{a: "alice", b: "alice", want: true},
+		{a: "alice", b: "Alice", want: true},
+		{a: "ALICE", b: "alice  ", want: true},
+		{a: "straße", b: "STRASSE", want: true},
+		{a: "alice", b: "alicia", want: false},
+		{a: "alice", b: " alice", want: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.a+"/"+tc.b, func(t *testing.T) {
+			if got := userNameKey(tc.a) == userNameKey(tc.b); got != tc.want {
+				t.Errorf("userNameKey(%q) == userNameKey(%q): got %v, want %v", tc.a, tc.b, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestUserRepo_RegisterUsers_duplicates(t *testing.T) {
+	db := dbtest.StartMySQL(t)
+	repo := NewUserRepo(WithNagaya(db.Nagaya))
+	testCases := []struct {
+		name     string
+		existing []string
+		input    []string
+		wantRows []int
+	}{
+		{name: "ok", input: []string{"alice", "bob"}},
+		{name: "duplicated in input", input: []string{"alice", "bob", "alice"}, wantRows: []int{2}},
+		{name: "duplicated in input ignoring case", input: []string{"alice", "Bob", "bob", "ALICE"}, wantRows: []int{2, 3}},
+		{name: "already registered", existing: []string{"alice"}, input: []string{"bob", "alice"}, wantRows: []int{1}},
+		{name: "already registered ignoring case", existing: []string{"alice"}, input: []string{"bob", "Alice"}, wantRows: []int{1}},
+		{name: "both", existing: []string{"carol"}, input: []string{"Carol", "bob", "BOB"}, wantRows: []int{0, 2}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tenant := db.NewTenant(t)
+			db.RunInTenant(t, tenant, func(ctx context.Context) {
+				for _, name := range tc.existing {
+					if _, err := repo.RegisterUser(ctx, &UserToRegister{Name: name}); err != nil {
+						t.Fatalf("RegisterUser: %s", err)
+					}
+				}
+				input := make([]*UserToRegister, len(tc.input))
+				for i, name := range tc.input {
+					input[i] = &UserToRegister{Name: name}
+				}
+				_, err := repo.RegisterUsers(ctx, input)
+				if len(tc.wantRows) == 0 {
+					if err != nil {
+						t.Fatalf("RegisterUsers: %s", err)
+					}
+					return
+				}
+				var importErr *UserImportError
+				if !errors.As(err, &importErr) {
+					t.Fatalf("error = %v, want UserImportError", err)
+				}
+				gotRows := make([]int, len(importErr.Rows))
+				for i, row := range importErr.Rows {
+					gotRows[i] = row.Index
+					if !errors.Is(row.Err, ErrUserAlreadyExists) {
+						t.Errorf("row %d: error = %v, want %v", row.Index, row.Err, ErrUserAlreadyExists)
+					}
+				}
+				if !slices.Equal(gotRows, tc.wantRows) {
+					t.Errorf("rows = %v, want %v", gotRows, tc.wantRows)
+				}
+			})
+		})
+	}
+}
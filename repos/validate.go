@@ -0,0 +1,119 @@
+package repos
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ValidationError is an error type represents the field of the input violates the rule declared by its validate tag.
+//
+// The errors compare equal with errors.Is if they have the same resource, field, rule and parameter, so the sentinels such as ErrUserNameRequired match the errors reported by the validation.
+type ValidationError struct {
+	Resource string
+	Field    string
+	Rule     string
+	Param    string
+}
+
+func (e *ValidationError) Error() string {
+	field := fmt.Sprintf("%s.%s", e.Resource, e.Field)
+	switch e.Rule {
+	case "required":
+		return fmt.Sprintf("%s is required", field)
+	case "min":
+		return fmt.Sprintf("%s must be at least %s characters", field, e.Param)
+	case "max":
+		return fmt.Sprintf("%s must be at most %s characters", field, e.Param)
+	case "printable":
+		return fmt.Sprintf("%s must consist of printable characters", field)
+	case "excludes":
+		return fmt.Sprintf("%s must not contain any of %q", field, e.Param)
+	case "url":
+		return fmt.Sprintf("%s must be an absolute http or https URL", field)
+	default:
+		return fmt.Sprintf("%s violates %s", field, e.Rule)
+	}
+}
+
+func (e *ValidationError) Is(target error) bool {
+	t, ok := target.(*ValidationError)
+	return ok && *t == *e
+}
+
+// validateStruct checks the string fields of the struct against the rules in their validate tags and returns the first violation as ValidationError.
+//
+// The rules are separated by commas:
+//
+//   - required: the value is not empty
+//   - min=N, max=N: the number of the characters is in the range
+//   - printable: the value has no control or other non-printable characters
+//   - excludes=CHARS: the value contains none of the characters
+//   - url: the value is an absolute http or https URL
+//
+// The fields are named after their json tags. The nil pointer fields are skipped, so the optional fields such as the ones of UserUpdate are checked only if given.
+func validateStruct(resource string, v any) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		tag, ok := sf.Tag.Lookup("validate")
+		if !ok {
+			continue
+		}
+		fv := rv.Field(i)
+		if fv.Kind() == reflect.Pointer {
+			if fv.IsNil() {
+				continue
+			}
+			fv = fv.Elem()
+		}
+		if fv.Kind() != reflect.String {
+			continue
+		}
+		field := fieldName(sf)
+		for _, rule := range strings.Split(tag, ",") {
+			name, param, _ := strings.Cut(rule, "=")
+			if !checkRule(name, param, fv.String()) {
+				return &ValidationError{Resource: resource, Field: field, Rule: name, Param: param}
+			}
+		}
+	}
+	return nil
+}
+
+func fieldName(sf reflect.StructField) string {
+	if name, _, _ := strings.Cut(sf.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	return strings.ToLower(sf.Name)
+}
+
+func checkRule(name, param, value string) bool {
+	switch name {
+	case "required":
+		return value != ""
+	case "min":
+		n, _ := strconv.Atoi(param)
+		return utf8.RuneCountInString(value) >= n
+	case "max":
+		n, _ := strconv.Atoi(param)
+		return utf8.RuneCountInString(value) <= n
+	case "printable":
+		return strings.IndexFunc(value, func(r rune) bool { return !unicode.IsPrint(r) }) < 0
+	case "excludes":
+		return !strings.ContainsAny(value, param)
+	case "url":
+		if value == "" {
+			return true
+		}
+		u, err := url.Parse(value)
+		return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+	default:
+		panic(fmt.Sprintf("unknown validation rule: %q", name))
+	}
+}
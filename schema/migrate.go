@@ -0,0 +1,158 @@
+package schema
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// migrationLockTimeout is how long MigrateTenant waits for the other runner migrating the same tenant.
+const migrationLockTimeout = time.Second * 30
+
+//go:embed migrations/tenant/*.sql
+var tenantMigrationFS embed.FS
+
+var tenantMigrations = mustLoadMigrations(tenantMigrationFS, "migrations/tenant")
+
+// Migration is a change of the schema of the tenant databases, loaded from migrations/tenant/<version>_<name>.sql.
+type Migration struct {
+	Version    int
+	Name       string
+	statements []string
+}
+
+// Migrations returns the migrations of the tenant databases in the order they are applied.
+func Migrations() []Migration {
+	return slices.Clone(tenantMigrations)
+}
+
+// LatestVersion returns the version of the last migration of the tenant databases.
+func LatestVersion() int {
+	return tenantMigrations[len(tenantMigrations)-1].Version
+}
+
+func mustLoadMigrations(fsys fs.FS, dir string) []Migration {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		panic(fmt.Sprintf("schema: fs.ReadDir: %s", err))
+	}
+	migrations := make([]Migration, 0, len(entries))
+	for _, entry := range entries {
+		versionPart, name, ok := strings.Cut(strings.TrimSuffix(entry.Name(), ".sql"), "_")
+		version, err := strconv.Atoi(versionPart)
+		if !ok || err != nil || version <= 0 {
+			panic(fmt.Sprintf("schema: the migration must be named <version>_<name>.sql: %s", entry.Name()))
+		}
+		body, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			panic(fmt.Sprintf("schema: fs.ReadFile: %s", err))
+		}
+		if len(migrations) > 0 && migrations[len(migrations)-1].Version == version {
+			panic(fmt.Sprintf("schema: the version of the migration is duplicated: %s", entry.Name()))
+		}
+		migrations = append(migrations, Migration{Version: version, Name: name, statements: statements(string(body))})
+	}
+	if len(migrations) == 0 {
+		panic("schema: no migration is found in " + dir)
+	}
+	// fs.ReadDir sorts the entries by the name, which may differ from the order of the versions unless they are zero-padded
+	slices.SortFunc(migrations, func(a, b Migration) int { return a.Version - b.Version })
+	return migrations
+}
+
+// MigrationResult is the versions of the database of a tenant before and after the migration.
+type MigrationResult struct {
+	Tenant      string `json:"tenant"`
+	FromVersion int    `json:"from_version"`
+	ToVersion   int    `json:"to_version"`
+}
+
+// MigrateTenant creates the database of the tenant and applies the migrations newer than its version in order.
+//
+// The versions applied are recorded in schema_migrations of the database of the tenant, and the runners migrating the same tenant are serialized by the named lock of MySQL.
+// MySQL commits the DDL implicitly, so a migration failed halfway is applied again from the start by the next run; the statements are expected to be idempotent such as create table if not exists.
+// The tenant is expected to be validated by repos.ValidateTenantID since it is used as the name of the database.
+func MigrateTenant(ctx context.Context, db *sqlx.DB, tenant string) (*MigrationResult, error) {
+	if _, err := db.ExecContext(ctx, "create database if not exists "+quoteIdentifier(tenant)); err != nil {
+		return nil, fmt.Errorf("ExecContext: %w", err)
+	}
+	result := &MigrationResult{Tenant: tenant}
+	err := withTenantConn(ctx, db, tenant, func(conn *sqlx.Conn) error {
+		var locked bool
+		if err := conn.GetContext(ctx, &locked, "select get_lock(?, ?)", migrationLockName(tenant), int(migrationLockTimeout.Seconds())); err != nil {
+			return fmt.Errorf("GetContext: %w", err)
+		}
+		if !locked {
+			return fmt.Errorf("timed out waiting for the other migration of the tenant %s", tenant)
+		}
+		defer func() {
+			_, _ = conn.ExecContext(context.WithoutCancel(ctx), "select release_lock(?)", migrationLockName(tenant))
+		}()
+		if _, err := conn.ExecContext(ctx, createMigrationsTable); err != nil {
+			return fmt.Errorf("ExecContext: %w", err)
+		}
+		version, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		result.FromVersion, result.ToVersion = version, version
+		for _, m := range tenantMigrations {
+			if m.Version <= version {
+				continue
+			}
+			for _, stmt := range m.statements {
+				if _, err := conn.ExecContext(ctx, stmt); err != nil {
+					return fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+				}
+			}
+			if _, err := conn.ExecContext(ctx, "insert into schema_migrations (version, name) values (?, ?)", m.Version, m.Name); err != nil {
+				return fmt.Errorf("ExecContext: %w", err)
+			}
+			result.ToVersion = m.Version
+		}
+		return nil
+	})
+	return result, err
+}
+
+// TenantVersion returns the version of the last migration applied to the database of the tenant, or 0 if nothing is applied.
+func TenantVersion(ctx context.Context, db *sqlx.DB, tenant string) (int, error) {
+	var exists bool
+	if err := db.GetContext(ctx, &exists, "select count(*) > 0 from information_schema.tables where table_schema = ? and table_name = 'schema_migrations'", tenant); err != nil {
+		return 0, fmt.Errorf("GetContext: %w", err)
+	}
+	if !exists {
+		return 0, nil
+	}
+	var version int
+	if err := db.GetContext(ctx, &version, "select coalesce(max(version), 0) from "+quoteIdentifier(tenant)+".schema_migrations"); err != nil {
+		return 0, fmt.Errorf("GetContext: %w", err)
+	}
+	return version, nil
+}
+
+const createMigrationsTable = `create table if not exists schema_migrations (
+  version int unsigned primary key,
+  name varchar(255) not null,
+  applied_at datetime(6) not null default current_timestamp(6)
+) ENGINE=INNODB DEFAULT CHARSET=utf8mb4 collate=utf8mb4_unicode_ci`
+
+func currentVersion(ctx context.Context, conn *sqlx.Conn) (int, error) {
+	var version int
+	if err := conn.GetContext(ctx, &version, "select coalesce(max(version), 0) from schema_migrations"); err != nil {
+		return 0, fmt.Errorf("GetContext: %w", err)
+	}
+	return version, nil
+}
+
+func migrationLockName(tenant string) string {
+	return "schema_migrations:" + tenant
+}
@@ -0,0 +1,32 @@
+create table if not exists users (
+  id char(20) character set ascii primary key,
+  name varchar(255) not null unique
+) ENGINE=INNODB DEFAULT CHARSET=utf8mb4 collate=utf8mb4_unicode_ci;
+
+create table if not exists memberships (
+  subject varchar(255) character set ascii primary key,
+  role enum('owner', 'editor', 'viewer') not null
+) ENGINE=INNODB DEFAULT CHARSET=utf8mb4 collate=utf8mb4_unicode_ci;
+
+create table if not exists outbox (
+  id char(20) character set ascii primary key,
+  event_type varchar(255) character set ascii not null,
+  payload json not null,
+  traceparent varchar(55) character set ascii not null default '',
+  created_at datetime(6) not null default current_timestamp(6),
+  published_at datetime(6) null,
+  key published_at_id (published_at, id)
+) ENGINE=INNODB DEFAULT CHARSET=utf8mb4 collate=utf8mb4_unicode_ci;
+
+create table if not exists audit_logs (
+  id char(20) character set ascii primary key,
+  principal varchar(255) not null,
+  action varchar(64) character set ascii not null,
+  resource varchar(255) not null,
+  before_state json null,
+  after_state json null,
+  request_id varchar(64) character set ascii not null default '',
+  created_at datetime(6) not null default current_timestamp(6),
+  key principal_id (principal, id),
+  key resource_id (resource, id)
+) ENGINE=INNODB DEFAULT CHARSET=utf8mb4 collate=utf8mb4_unicode_ci;
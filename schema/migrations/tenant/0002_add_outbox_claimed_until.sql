@@ -0,0 +1,12 @@
+-- MySQL has no "add column if not exists", so the column is added only if information_schema lacks it to keep the migration idempotent
+set @stmt = if(
+  (select count(*) from information_schema.columns where table_schema = database() and table_name = 'outbox' and column_name = 'claimed_until') = 0,
+  'alter table outbox add column claimed_until datetime(6) null after published_at',
+  'do 0'
+);
+
+prepare add_claimed_until from @stmt;
+
+execute add_claimed_until;
+
+deallocate prepare add_claimed_until;
@@ -0,0 +1,227 @@
+package schema
+
+import (
+	"context"
+	"enjoymultitenancy/repos"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrRunInProgress is returned by Runner.Start if the previous run has not finished yet.
+var ErrRunInProgress = errors.New("migration is in progress")
+
+// upToDateCacheTTL is how long CheckUpToDate trusts the last check that found every tenant up to date, since the check queries every tenant.
+var upToDateCacheTTL = time.Minute
+
+// maxListedTenantsBehind bounds the tenants named in the error of CheckUpToDate.
+const maxListedTenantsBehind = 10
+
+// NewRunner returns a Runner that migrates the shared database and the databases of the tenants registered to the registry.
+func NewRunner(db *sqlx.DB, tenants repos.TenantLister) *Runner {
+	return &Runner{db: db, tenants: tenants}
+}
+
+// Runner applies the migrations to the tenants one by one and keeps the progress of the last run.
+type Runner struct {
+	db      *sqlx.DB
+	tenants repos.TenantLister
+
+	mu         sync.Mutex
+	running    bool
+	last       *Run
+	upToDateAt time.Time
+}
+
+// Run is the progress of a migration over the tenants.
+type Run struct {
+	StartedAt  time.Time          `json:"started_at"`
+	FinishedAt *time.Time         `json:"finished_at,omitempty"`
+	Results    []*MigrationResult `json:"results"`
+	Failures   []*RunFailure      `json:"failures,omitempty"`
+	Error      string             `json:"error,omitempty"`
+}
+
+// RunFailure is the tenant that failed to be migrated.
+type RunFailure struct {
+	Tenant string `json:"tenant"`
+	Error  string `json:"error"`
+}
+
+// TenantStatus is the version of the database of a tenant.
+type TenantStatus struct {
+	Tenant  string `json:"tenant"`
+	Version int    `json:"version"`
+	// Pending is the number of the migrations not applied yet.
+	Pending int `json:"pending"`
+}
+
+// Run migrates the shared database and the tenants, or all the registered tenants if no tenant is given, and waits for it to finish.
+//
+// The failure of a tenant does not stop the migrations of the rest of the tenants; the failures are joined into the returned error.
+func (r *Runner) Run(ctx context.Context, tenants ...string) (*Run, error) {
+	r.mu.Lock()
+	if r.running {
+		r.mu.Unlock()
+		return nil, ErrRunInProgress
+	}
+	run := r.begin()
+	r.mu.Unlock()
+	err := r.run(ctx, run, tenants)
+	return r.LastRun(), err
+}
+
+// Start starts migrating the tenants in the background as Run does, and returns the run just started.
+func (r *Runner) Start(ctx context.Context, tenants ...string) (*Run, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.running {
+		return nil, ErrRunInProgress
+	}
+	run := r.begin()
+	go func() { _ = r.run(context.WithoutCancel(ctx), run, tenants) }()
+	return run.clone(), nil
+}
+
+// LastRun returns the snapshot of the last run, which may be in progress; it returns nil if nothing has run yet.
+func (r *Runner) LastRun() *Run {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.last == nil {
+		return nil
+	}
+	return r.last.clone()
+}
+
+// Status returns the versions of the databases of the registered tenants.
+func (r *Runner) Status(ctx context.Context) ([]*TenantStatus, error) {
+	tenants, err := r.tenants.ListTenants(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ListTenants: %w", err)
+	}
+	latest := LatestVersion()
+	statuses := make([]*TenantStatus, 0, len(tenants))
+	for _, tenant := range tenants {
+		version, err := TenantVersion(ctx, r.db, tenant.ID)
+		if err != nil {
+			return nil, fmt.Errorf("TenantVersion(%s): %w", tenant.ID, err)
+		}
+		status := &TenantStatus{Tenant: tenant.ID, Version: version}
+		for _, m := range tenantMigrations {
+			if m.Version > version {
+				status.Pending++
+			}
+		}
+		if version > latest {
+			slog.WarnContext(ctx, "tenant is migrated beyond the latest version of the binary", slog.String("tenant", tenant.ID), slog.Int("version", version))
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// CheckUpToDate returns an error if any of the registered tenants is behind LatestVersion. It is intended to be registered as a readiness check.
+//
+// The result is cached for a while once every tenant is found up to date, while the tenants behind are checked again on every call.
+func (r *Runner) CheckUpToDate(ctx context.Context) error {
+	now := time.Now()
+	r.mu.Lock()
+	cached := !r.upToDateAt.IsZero() && now.Sub(r.upToDateAt) < upToDateCacheTTL
+	r.mu.Unlock()
+	if cached {
+		return nil
+	}
+	statuses, err := r.Status(ctx)
+	if err != nil {
+		return err
+	}
+	var behind []string
+	for _, status := range statuses {
+		if status.Version < LatestVersion() {
+			behind = append(behind, status.Tenant)
+		}
+	}
+	if len(behind) > 0 {
+		listed := behind[:min(len(behind), maxListedTenantsBehind)]
+		return fmt.Errorf("%d tenants are behind the schema version %d: %s", len(behind), LatestVersion(), strings.Join(listed, ", "))
+	}
+	r.mu.Lock()
+	r.upToDateAt = now
+	r.mu.Unlock()
+	return nil
+}
+
+// begin must be called with r.mu held.
+func (r *Runner) begin() *Run {
+	r.running = true
+	r.last = &Run{StartedAt: time.Now(), Results: []*MigrationResult{}}
+	return r.last
+}
+
+func (r *Runner) run(ctx context.Context, run *Run, tenants []string) (err error) {
+	defer func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		finishedAt := time.Now()
+		run.FinishedAt = &finishedAt
+		if err != nil {
+			run.Error = err.Error()
+		}
+		r.running = false
+	}()
+	if err := ApplyShared(ctx, r.db); err != nil {
+		return fmt.Errorf("failed to migrate the shared database: %w", err)
+	}
+	if len(tenants) == 0 {
+		registered, err := r.tenants.ListTenants(ctx)
+		if err != nil {
+			return fmt.Errorf("ListTenants: %w", err)
+		}
+		for _, tenant := range registered {
+			tenants = append(tenants, tenant.ID)
+		}
+	}
+	var errs []error
+	for _, tenant := range tenants {
+		result, err := r.migrateTenant(ctx, tenant)
+		r.mu.Lock()
+		if result != nil {
+			run.Results = append(run.Results, result)
+		}
+		if err != nil {
+			run.Failures = append(run.Failures, &RunFailure{Tenant: tenant, Error: err.Error()})
+		}
+		r.mu.Unlock()
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to migrate tenant", slog.String("tenant", tenant), slog.String("error", err.Error()))
+			errs = append(errs, fmt.Errorf("tenant %s: %w", tenant, err))
+			continue
+		}
+		slog.InfoContext(ctx, "migrated tenant", slog.String("tenant", tenant), slog.Int("from_version", result.FromVersion), slog.Int("to_version", result.ToVersion))
+	}
+	return errors.Join(errs...)
+}
+
+func (r *Runner) migrateTenant(ctx context.Context, tenant string) (*MigrationResult, error) {
+	if err := repos.ValidateTenantID(tenant); err != nil {
+		return nil, err
+	}
+	return MigrateTenant(ctx, r.db, tenant)
+}
+
+// clone must be called with the lock of the runner held.
+func (run *Run) clone() *Run {
+	c := *run
+	c.Results = make([]*MigrationResult, len(run.Results))
+	for i, result := range run.Results {
+		copied := *result
+		c.Results[i] = &copied
+	}
+	c.Failures = append([]*RunFailure(nil), run.Failures...)
+	return &c
+}
@@ -0,0 +1,50 @@
+package schema
+
+import (
+	"context"
+	"enjoymultitenancy/dbtest"
+	"enjoymultitenancy/repos"
+	"strings"
+	"testing"
+)
+
+type stubTenantLister []string
+
+func (l stubTenantLister) ListTenants(context.Context) ([]*repos.Tenant, error) {
+	tenants := make([]*repos.Tenant, len(l))
+	for i, id := range l {
+		tenants[i] = &repos.Tenant{ID: id}
+	}
+	return tenants, nil
+}
+
+func TestRunner_CheckUpToDate(t *testing.T) {
+	db := dbtest.StartMySQL(t)
+	migrated := string(db.NewTenant(t))
+	behind := string(db.NewTenant(t))
+	ctx := context.Background()
+	if _, err := NewRunner(db.DB, stubTenantLister{migrated}).Run(ctx); err != nil {
+		t.Fatalf("Run: %s", err)
+	}
+
+	testCases := []struct {
+		name    string
+		tenants []string
+		wantErr string
+	}{
+		{name: "up to date", tenants: []string{migrated}},
+		{name: "no tenants", tenants: []string{}},
+		{name: "behind", tenants: []string{migrated, behind}, wantErr: "1 tenants are behind the schema version"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := NewRunner(db.DB, stubTenantLister(tc.tenants)).CheckUpToDate(ctx)
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %s", err)
+			case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr) || !strings.Contains(err.Error(), behind)):
+				t.Errorf("error = %v, want %q naming %s", err, tc.wantErr, behind)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+// Package schema provides the tables of the shared database and the migrations of the databases of the tenants.
+//
+// The tables are kept in sync with etc/ddl.sql, which provisions the databases of the local environment.
+package schema
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+//go:embed shared.sql
+var sharedDDL string
+
+// ApplyShared creates the tables of the shared database that are missing, such as the tenant registry.
+func ApplyShared(ctx context.Context, db *sqlx.DB) error {
+	for _, stmt := range statements(sharedDDL) {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("ExecContext: %w", err)
+		}
+	}
+	return nil
+}
+
+// withTenantConn calls fn with a connection switched to the database of the tenant.
+func withTenantConn(ctx context.Context, db *sqlx.DB, tenant string, fn func(conn *sqlx.Conn) error) error {
+	conn, err := db.Connx(ctx)
+	if err != nil {
+		return fmt.Errorf("Connx: %w", err)
+	}
+	defer conn.Close()
+	var current sql.NullString
+	if err := conn.GetContext(ctx, &current, "select database()"); err != nil {
+		return fmt.Errorf("GetContext: %w", err)
+	}
+	if _, err := conn.ExecContext(ctx, "use "+quoteIdentifier(tenant)); err != nil {
+		return fmt.Errorf("ExecContext: %w", err)
+	}
+	// the connection goes back to the pool, so it must not be left on the database of the tenant
+	defer func() {
+		if current.Valid {
+			_, _ = conn.ExecContext(context.WithoutCancel(ctx), "use "+quoteIdentifier(current.String))
+		}
+	}()
+	return fn(conn)
+}
+
+func statements(ddl string) []string {
+	var stmts []string
+	for _, stmt := range strings.Split(ddl, ";") {
+		if stmt = strings.TrimSpace(stmt); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+	return stmts
+}
+
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
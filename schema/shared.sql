@@ -0,0 +1,11 @@
+create table if not exists tenants (
+  id varchar(64) character set ascii primary key,
+  created_at datetime not null default current_timestamp,
+  suspended_at datetime null
+) ENGINE=INNODB DEFAULT CHARSET=utf8mb4 collate=utf8mb4_unicode_ci;
+
+create table if not exists tenant_domains (
+  domain varchar(253) character set ascii primary key,
+  tenant_id varchar(64) character set ascii not null,
+  foreign key (tenant_id) references tenants (id) on delete cascade
+) ENGINE=INNODB DEFAULT CHARSET=utf8mb4 collate=utf8mb4_unicode_ci;
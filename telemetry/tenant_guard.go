@@ -0,0 +1,80 @@
+// Package telemetry provides the helpers shared by the instrumentations of the HTTP server and the DB handle.
+package telemetry
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aereal/nagaya"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// TenantKey is the attribute key of the tenant on the metrics.
+const TenantKey = attribute.Key("tenant")
+
+// OtherTenant is the bucket that TenantGuard puts the tenants beyond its limit into.
+const OtherTenant = "other"
+
+type NewTenantGuardOption func(g *TenantGuard)
+
+// WithTenants reserves the slots for the tenants, such as the largest ones, so that they are kept as they are regardless of the order they are seen.
+func WithTenants(tenants ...string) NewTenantGuardOption {
+	return func(g *TenantGuard) {
+		for _, tenant := range tenants {
+			g.admitted[tenant] = struct{}{}
+		}
+	}
+}
+
+// NewTenantGuard returns a TenantGuard that keeps up to limit distinct tenants as the values of the tenant attribute.
+func NewTenantGuard(limit int, optFns ...NewTenantGuardOption) *TenantGuard {
+	g := &TenantGuard{limit: limit, admitted: map[string]struct{}{}}
+	for _, f := range optFns {
+		f(g)
+	}
+	return g
+}
+
+// TenantGuard bounds the cardinality of the tenant attribute of the metrics.
+//
+// The tenants given by WithTenants take the slots first, and the rest of the slots are taken by the tenants in the order they are seen.
+// Once the slots are exhausted, the other tenants are put into the OtherTenant bucket, so that a huge number of the tenants cannot blow up the number of the series.
+type TenantGuard struct {
+	limit int
+
+	mu       sync.RWMutex
+	admitted map[string]struct{}
+}
+
+// Bucket returns the value of the tenant attribute for the tenant: the tenant itself if it has a slot, otherwise OtherTenant.
+func (g *TenantGuard) Bucket(tenant string) string {
+	if tenant == "" {
+		return ""
+	}
+	g.mu.RLock()
+	_, ok := g.admitted[tenant]
+	full := len(g.admitted) >= g.limit
+	g.mu.RUnlock()
+	if ok {
+		return tenant
+	}
+	if full {
+		return OtherTenant
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.admitted[tenant]; ok {
+		return tenant
+	}
+	if len(g.admitted) >= g.limit {
+		return OtherTenant
+	}
+	g.admitted[tenant] = struct{}{}
+	return tenant
+}
+
+// Attribute returns the tenant attribute for the tenant carried by the context, bucketed by Bucket.
+func (g *TenantGuard) Attribute(ctx context.Context) attribute.KeyValue {
+	tenant, _ := nagaya.TenantFromContext(ctx)
+	return TenantKey.String(g.Bucket(string(tenant)))
+}
@@ -0,0 +1,321 @@
+package web
+
+import (
+	"encoding/json"
+	"enjoymultitenancy/repos"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/aereal/nagaya"
+	"github.com/dimfeld/httptreemux/v5"
+	"github.com/jmoiron/sqlx"
+)
+
+// WithAdminMiddleware configures the middleware that authenticates the requests to the admin routes.
+//
+// The admin routes under /admin are mounted only if the middleware is given, and never go through the tenant-facing auth and apartment middlewares.
+func WithAdminMiddleware(mw func(http.Handler) http.Handler) NewServerOption {
+	return func(s *Server) { s.adminMiddleware = mw }
+}
+
+// WithTenantRepo configures the repo used by the tenant management endpoints.
+func WithTenantRepo(tr TenantRepository) NewServerOption {
+	return func(s *Server) { s.tenantRepo = tr }
+}
+
+// WithTenantResolver configures the resolver that rejects the requests to the unregistered or suspended tenants,
+// and whose cache is invalidated on the changes of the domain mappings and the suspension of the tenants.
+func WithTenantResolver(tr *TenantResolver) NewServerOption {
+	return func(s *Server) { s.tenantResolver = tr }
+}
+
+// WithDB configures the database handle shared by the tenants to report its statistics.
+func WithDB(db *sqlx.DB) NewServerOption {
+	return func(s *Server) { s.db = db }
+}
+
+// WithLogLevel configures the level var that the admin endpoint reads and updates, which lets the operators enable the debug logs without restarting the server.
+func WithLogLevel(level *slog.LevelVar) NewServerOption {
+	return func(s *Server) { s.logLevel = level }
+}
+
+func (s *Server) mountAdminRoutes(g *httptreemux.ContextGroup) {
+	g.UseHandler(s.adminMiddleware)
+	g.Handler(http.MethodGet, "/tenants", s.handleGetAdminTenants())
+	g.Handler(http.MethodPost, "/tenants/:tenant/suspend", s.handlePostAdminSuspendTenant())
+	g.Handler(http.MethodGet, "/stats", s.handleGetAdminStats())
+	g.Handler(http.MethodGet, "/config", s.handleGetAdminConfig())
+	g.Handler(http.MethodGet, "/log-level", s.handleGetAdminLogLevel())
+	g.Handler(http.MethodPut, "/log-level", s.handlePutAdminLogLevel())
+	g.Handler(http.MethodGet, "/domains", s.handleGetAdminDomains())
+	g.Handler(http.MethodPut, "/domains/:domain", s.handlePutAdminDomain())
+	g.Handler(http.MethodDelete, "/domains/:domain", s.handleDeleteAdminDomain())
+	g.Handler(http.MethodGet, "/maintenance", s.handleGetAdminMaintenance())
+	g.Handler(http.MethodPut, "/maintenance", s.handlePutAdminMaintenance())
+	g.Handler(http.MethodGet, "/metrics", s.handleGetAdminMetrics())
+	g.Handler(http.MethodGet, "/migrations", s.handleGetAdminMigrations())
+	g.Handler(http.MethodPost, "/migrations", s.handlePostAdminMigrations())
+	if s.pprof {
+		mountPprofRoutes(g)
+	}
+}
+
+type adminTenantsResponse struct {
+	Tenants []*repos.Tenant `json:"tenants"`
+}
+
+func (s *Server) handleGetAdminTenants() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		w.Header().Set("content-type", mediaTypeJSON)
+		if s.tenantRepo == nil {
+			w.WriteHeader(http.StatusNotImplemented)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "tenant registry is not configured"})
+			return
+		}
+		tenants, err := s.tenantRepo.ListTenants(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to list tenants", slog.String("error", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "failed to list tenants"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(adminTenantsResponse{Tenants: tenants})
+	})
+}
+
+type adminStatsResponse struct {
+	MaxOpenConnections int    `json:"max_open_connections"`
+	OpenConnections    int    `json:"open_connections"`
+	InUse              int    `json:"in_use"`
+	Idle               int    `json:"idle"`
+	WaitCount          int64  `json:"wait_count"`
+	WaitDuration       string `json:"wait_duration"`
+}
+
+func (s *Server) handleGetAdminStats() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", mediaTypeJSON)
+		if s.db == nil {
+			w.WriteHeader(http.StatusNotImplemented)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "database is not configured"})
+			return
+		}
+		stats := s.db.Stats()
+		_ = json.NewEncoder(w).Encode(adminStatsResponse{
+			MaxOpenConnections: stats.MaxOpenConnections,
+			OpenConnections:    stats.OpenConnections,
+			InUse:              stats.InUse,
+			Idle:               stats.Idle,
+			WaitCount:          stats.WaitCount,
+			WaitDuration:       stats.WaitDuration.String(),
+		})
+	})
+}
+
+type adminConfigResponse struct {
+	Port               string         `json:"port"`
+	ShutdownGrace      string         `json:"shutdown_grace"`
+	TLS                bool           `json:"tls"`
+	H2C                bool           `json:"h2c"`
+	StrictJSONDecoding bool           `json:"strict_json_decoding"`
+	Authentication     bool           `json:"authentication"`
+	Authorization      bool           `json:"authorization"`
+	Runtime            *RuntimeConfig `json:"runtime,omitempty"`
+}
+
+func (s *Server) handleGetAdminConfig() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", mediaTypeJSON)
+		_ = json.NewEncoder(w).Encode(adminConfigResponse{
+			Port:               s.port,
+			ShutdownGrace:      s.shutdownGrace.String(),
+			TLS:                s.tlsEnabled(),
+			H2C:                s.h2c,
+			StrictJSONDecoding: s.strictJSONDecoding,
+			Authentication:     s.authMiddleware != nil,
+			Authorization:      s.membershipRepo != nil,
+			Runtime:            s.runtimeConfig.Load(),
+		})
+	})
+}
+
+type adminLogLevel struct {
+	Level slog.Level `json:"level"`
+}
+
+func (s *Server) handleGetAdminLogLevel() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", mediaTypeJSON)
+		if s.logLevel == nil {
+			w.WriteHeader(http.StatusNotImplemented)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "log level is not configurable"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(adminLogLevel{Level: s.logLevel.Level()})
+	})
+}
+
+func (s *Server) handlePutAdminLogLevel() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		w.Header().Set("content-type", mediaTypeJSON)
+		if s.logLevel == nil {
+			w.WriteHeader(http.StatusNotImplemented)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "log level is not configurable"})
+			return
+		}
+		defer r.Body.Close()
+		req := new(adminLogLevel)
+		if err := s.decodeJSON(r.Body, req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: fmt.Sprintf("failed to decode request body: %s", err)})
+			return
+		}
+		prev := s.logLevel.Level()
+		s.logLevel.Set(req.Level)
+		slog.WarnContext(ctx, "log level changed", slog.String("from", prev.String()), slog.String("to", req.Level.String()))
+		_ = json.NewEncoder(w).Encode(adminLogLevel{Level: req.Level})
+	})
+}
+
+func (s *Server) handlePostAdminSuspendTenant() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		w.Header().Set("content-type", mediaTypeJSON)
+		if s.tenantRepo == nil {
+			w.WriteHeader(http.StatusNotImplemented)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "tenant registry is not configured"})
+			return
+		}
+		// the suspension is enforced by the resolver; without it the tenant would keep being served
+		if s.tenantResolver == nil {
+			w.WriteHeader(http.StatusNotImplemented)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "tenant resolver is not configured"})
+			return
+		}
+		tenant := httptreemux.ContextParams(ctx)["tenant"]
+		err := s.tenantRepo.SuspendTenant(ctx, tenant)
+		switch {
+		case errors.Is(err, repos.ErrNotFound):
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "not found"})
+			return
+		case err != nil:
+			slog.ErrorContext(ctx, "failed to suspend tenant", slog.String("error", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "failed to suspend tenant"})
+			return
+		}
+		s.tenantResolver.ForgetTenant(nagaya.Tenant(tenant))
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+type adminDomainsResponse struct {
+	Domains []*repos.TenantDomain `json:"domains"`
+}
+
+func (s *Server) handleGetAdminDomains() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		w.Header().Set("content-type", mediaTypeJSON)
+		if s.tenantRepo == nil {
+			w.WriteHeader(http.StatusNotImplemented)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "tenant registry is not configured"})
+			return
+		}
+		domains, err := s.tenantRepo.ListDomains(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to list domains", slog.String("error", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "failed to list domains"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(adminDomainsResponse{Domains: domains})
+	})
+}
+
+type putDomainRequest struct {
+	TenantID string `json:"tenant_id"`
+}
+
+func (s *Server) handlePutAdminDomain() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		w.Header().Set("content-type", mediaTypeJSON)
+		if s.tenantRepo == nil {
+			w.WriteHeader(http.StatusNotImplemented)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "tenant registry is not configured"})
+			return
+		}
+		defer r.Body.Close()
+		req := new(putDomainRequest)
+		if err := s.decodeJSON(r.Body, req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: fmt.Sprintf("failed to decode request body: %s", err)})
+			return
+		}
+		domain := httptreemux.ContextParams(ctx)["domain"]
+		td, result, err := s.tenantRepo.PutDomain(ctx, domain, req.TenantID)
+		switch {
+		case errors.Is(err, repos.ErrInvalidDomain):
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: err.Error()})
+			return
+		case errors.Is(err, repos.ErrNotFound):
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "tenant not found"})
+			return
+		case err != nil:
+			slog.ErrorContext(ctx, "failed to put domain", slog.String("error", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "failed to put domain"})
+			return
+		}
+		if s.tenantResolver != nil {
+			s.tenantResolver.Forget(td.Domain)
+		}
+		if result == repos.PutCreated {
+			w.WriteHeader(http.StatusCreated)
+		}
+		_ = json.NewEncoder(w).Encode(td)
+	})
+}
+
+func (s *Server) handleDeleteAdminDomain() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		w.Header().Set("content-type", mediaTypeJSON)
+		if s.tenantRepo == nil {
+			w.WriteHeader(http.StatusNotImplemented)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "tenant registry is not configured"})
+			return
+		}
+		domain := httptreemux.ContextParams(ctx)["domain"]
+		err := s.tenantRepo.DeleteDomain(ctx, domain)
+		switch {
+		case errors.Is(err, repos.ErrInvalidDomain):
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: err.Error()})
+			return
+		case errors.Is(err, repos.ErrNotFound):
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "not found"})
+			return
+		case err != nil:
+			slog.ErrorContext(ctx, "failed to delete domain", slog.String("error", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "failed to delete domain"})
+			return
+		}
+		if s.tenantResolver != nil {
+			if normalized, err := repos.NormalizeDomain(domain); err == nil {
+				s.tenantResolver.Forget(normalized)
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
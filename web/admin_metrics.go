@@ -0,0 +1,41 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// MetricsCollector collects the current values of the metrics, such as sdkmetric.ManualReader registered to the MeterProvider.
+type MetricsCollector interface {
+	Collect(ctx context.Context, rm *metricdata.ResourceMetrics) error
+}
+
+// WithMetricsCollector makes the admin routes serve the snapshot of the metrics collected by the collector at /admin/metrics,
+// which is handy to inspect the instance without the metrics backend.
+func WithMetricsCollector(c MetricsCollector) NewServerOption {
+	return func(s *Server) { s.metricsCollector = c }
+}
+
+func (s *Server) handleGetAdminMetrics() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		w.Header().Set("content-type", mediaTypeJSON)
+		if s.metricsCollector == nil {
+			w.WriteHeader(http.StatusNotImplemented)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "metrics collector is not configured"})
+			return
+		}
+		rm := new(metricdata.ResourceMetrics)
+		if err := s.metricsCollector.Collect(ctx, rm); err != nil {
+			slog.ErrorContext(ctx, "failed to collect metrics", slog.String("error", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "failed to collect metrics"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(rm)
+	})
+}
@@ -0,0 +1,90 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"enjoymultitenancy/schema"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+// SchemaMigrator applies the migrations to the databases of the tenants, such as schema.Runner.
+type SchemaMigrator interface {
+	Start(ctx context.Context, tenants ...string) (*schema.Run, error)
+	LastRun() *schema.Run
+	Status(ctx context.Context) ([]*schema.TenantStatus, error)
+}
+
+// WithSchemaMigrator makes the admin routes serve the versions of the tenants at GET /admin/migrations and start a migration at POST /admin/migrations.
+func WithSchemaMigrator(m SchemaMigrator) NewServerOption {
+	return func(s *Server) { s.schemaMigrator = m }
+}
+
+type adminMigrationsResponse struct {
+	LatestVersion int                    `json:"latest_version"`
+	Tenants       []*schema.TenantStatus `json:"tenants"`
+	LastRun       *schema.Run            `json:"last_run"`
+}
+
+func (s *Server) handleGetAdminMigrations() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		w.Header().Set("content-type", mediaTypeJSON)
+		if s.schemaMigrator == nil {
+			w.WriteHeader(http.StatusNotImplemented)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "schema migrator is not configured"})
+			return
+		}
+		statuses, err := s.schemaMigrator.Status(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to get the versions of the tenants", slog.String("error", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "failed to get the versions of the tenants"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(adminMigrationsResponse{LatestVersion: schema.LatestVersion(), Tenants: statuses, LastRun: s.schemaMigrator.LastRun()})
+	})
+}
+
+type adminStartMigrationRequest struct {
+	// Tenants is the tenants to migrate; all the registered tenants are migrated if it is empty.
+	Tenants []string `json:"tenants"`
+}
+
+func (s *Server) handlePostAdminMigrations() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		w.Header().Set("content-type", mediaTypeJSON)
+		if s.schemaMigrator == nil {
+			w.WriteHeader(http.StatusNotImplemented)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "schema migrator is not configured"})
+			return
+		}
+		var req adminStartMigrationRequest
+		if r.ContentLength != 0 {
+			defer r.Body.Close()
+			if err := s.decodeJSON(r.Body, &req); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(errorResponse{Error: fmt.Sprintf("failed to decode request body: %s", err)})
+				return
+			}
+		}
+		run, err := s.schemaMigrator.Start(ctx, req.Tenants...)
+		switch {
+		case errors.Is(err, schema.ErrRunInProgress):
+			w.WriteHeader(http.StatusConflict)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: err.Error()})
+			return
+		case err != nil:
+			slog.ErrorContext(ctx, "failed to start migration", slog.String("error", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "failed to start migration"})
+			return
+		}
+		slog.WarnContext(ctx, "migration started", slog.Any("tenants", req.Tenants))
+		w.WriteHeader(http.StatusAccepted)
+		_ = json.NewEncoder(w).Encode(run)
+	})
+}
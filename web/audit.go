@@ -0,0 +1,179 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"enjoymultitenancy/auth"
+	"enjoymultitenancy/logging"
+	"enjoymultitenancy/repos"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// WithAuditRepo makes the server record an audit entry for every change made through the user repo, and serves the entries on GET /audit-logs.
+//
+// The entries are written within the same transaction as the changes if the transactor is configured, so that a failure to audit rolls back the change.
+func WithAuditRepo(ar AuditRepository) NewServerOption {
+	return func(s *Server) { s.auditRepo = ar }
+}
+
+// auditedUserRepo is a decorator of UserRepository that records the changes to the audit log.
+//
+// Decorating the repo instead of the handlers covers the REST, batch and Connect endpoints alike.
+type auditedUserRepo struct {
+	UserRepository
+	audit      AuditRepository
+	transactor Transactor
+}
+
+func (r *auditedUserRepo) RegisterUser(ctx context.Context, user *repos.UserToRegister) (*repos.User, error) {
+	var registered *repos.User
+	err := r.runAudited(ctx, func(ctx context.Context) (*repos.AuditEntry, error) {
+		var err error
+		registered, err = r.UserRepository.RegisterUser(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		return &repos.AuditEntry{Action: "user.register", Resource: userResource(registered.Name), After: auditSnapshot(registered)}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return registered, nil
+}
+
+func (r *auditedUserRepo) RegisterUsers(ctx context.Context, users []*repos.UserToRegister) ([]*repos.User, error) {
+	var registered []*repos.User
+	err := r.runAudited(ctx, func(ctx context.Context) (*repos.AuditEntry, error) {
+		var err error
+		registered, err = r.UserRepository.RegisterUsers(ctx, users)
+		if err != nil {
+			return nil, err
+		}
+		return &repos.AuditEntry{Action: "user.import", Resource: "users", After: auditSnapshot(registered)}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return registered, nil
+}
+
+func (r *auditedUserRepo) UpdateUser(ctx context.Context, name string, opts ...repos.UpdateUserOption) (*repos.User, error) {
+	var updated *repos.User
+	err := r.runAudited(ctx, func(ctx context.Context) (*repos.AuditEntry, error) {
+		before, err := r.UserRepository.FetchUserByName(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		updated, err = r.UserRepository.UpdateUser(ctx, name, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return &repos.AuditEntry{Action: "user.update", Resource: userResource(name), Before: auditSnapshot(before), After: auditSnapshot(updated)}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (r *auditedUserRepo) DeleteUser(ctx context.Context, name string) error {
+	return r.runAudited(ctx, func(ctx context.Context) (*repos.AuditEntry, error) {
+		before, err := r.UserRepository.FetchUserByName(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if err := r.UserRepository.DeleteUser(ctx, name); err != nil {
+			return nil, err
+		}
+		return &repos.AuditEntry{Action: "user.delete", Resource: userResource(name), Before: auditSnapshot(before)}, nil
+	})
+}
+
+// runAudited runs the change and records the entry it returns, within a transaction if the transactor is configured.
+func (r *auditedUserRepo) runAudited(ctx context.Context, change func(ctx context.Context) (*repos.AuditEntry, error)) error {
+	run := func(ctx context.Context) error {
+		entry, err := change(ctx)
+		if err != nil {
+			return err
+		}
+		if p, ok := auth.PrincipalFromContext(ctx); ok {
+			entry.Principal = p.Subject
+		}
+		entry.RequestID, _ = logging.RequestIDFromContext(ctx)
+		return r.audit.RecordAudit(ctx, entry)
+	}
+	if r.transactor == nil {
+		return run(ctx)
+	}
+	return r.transactor.RunInTx(ctx, run)
+}
+
+func userResource(name string) string {
+	return fmt.Sprintf("users/%s", name)
+}
+
+// auditSnapshot encodes the resource; the error is ignored since the snapshots are plain structs that always encode.
+func auditSnapshot(v any) json.RawMessage {
+	b, _ := json.Marshal(v)
+	return b
+}
+
+type listAuditLogsResponse struct {
+	Entries    []*repos.AuditEntry `json:"entries"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+func (s *Server) handleListAuditLogs() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		w.Header().Set("content-type", mediaTypeJSON)
+		if s.auditRepo == nil {
+			w.WriteHeader(http.StatusNotImplemented)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "audit log is not configured"})
+			return
+		}
+		query := r.URL.Query()
+		opts := []repos.ListAuditEntriesOption{
+			repos.WithAuditEntriesAfter(query.Get("after")),
+			repos.WithAuditPrincipal(query.Get("principal")),
+			repos.WithAuditAction(query.Get("action")),
+			repos.WithAuditResource(query.Get("resource")),
+		}
+		if v := query.Get("limit"); v != "" {
+			limit, err := strconv.Atoi(v)
+			if err != nil || limit <= 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(errorResponse{Error: fmt.Sprintf("invalid limit: %q", v)})
+				return
+			}
+			opts = append(opts, repos.WithAuditEntriesLimit(limit))
+		}
+		var period [2]time.Time
+		for i, param := range []string{"since", "until"} {
+			v := query.Get(param)
+			if v == "" {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(errorResponse{Error: fmt.Sprintf("invalid %s: %q", param, v)})
+				return
+			}
+			period[i] = t
+		}
+		opts = append(opts, repos.WithAuditPeriod(period[0], period[1]))
+		page, err := s.auditRepo.ListAuditEntries(ctx, opts...)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to list audit entries", slog.String("error", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "failed to list the audit entries"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(listAuditLogsResponse{Entries: page.Entries, NextCursor: page.NextCursor})
+	})
+}
@@ -0,0 +1,57 @@
+package web
+
+import (
+	"encoding/json"
+	"enjoymultitenancy/auth"
+	"enjoymultitenancy/repos"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+// WithMembershipRepo enables the role-based authorization of the routes using the memberships of the tenant.
+//
+// The authorization requires the authenticated principal, so the auth middleware must be configured together.
+func WithMembershipRepo(mr MembershipRepository) NewServerOption {
+	return func(s *Server) { s.membershipRepo = mr }
+}
+
+// requireRole returns a middleware function that permits only the principals that have the role satisfies the required role within the current tenant.
+func (s *Server) requireRole(required auth.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if s.membershipRepo == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			principal, ok := auth.PrincipalFromContext(ctx)
+			if !ok {
+				w.Header().Set("content-type", mediaTypeJSON)
+				w.WriteHeader(http.StatusUnauthorized)
+				_ = json.NewEncoder(w).Encode(errorResponse{Error: "authentication required"})
+				return
+			}
+			membership, err := s.membershipRepo.FetchMembership(ctx, principal.Subject)
+			switch {
+			case errors.Is(err, repos.ErrNotFound):
+				w.Header().Set("content-type", mediaTypeJSON)
+				w.WriteHeader(http.StatusForbidden)
+				_ = json.NewEncoder(w).Encode(errorResponse{Error: "not a member of the tenant"})
+				return
+			case err != nil:
+				slog.ErrorContext(ctx, "failed to fetch membership", slog.String("error", err.Error()))
+				w.Header().Set("content-type", mediaTypeJSON)
+				w.WriteHeader(http.StatusInternalServerError)
+				_ = json.NewEncoder(w).Encode(errorResponse{Error: "failed to authorize the request"})
+				return
+			}
+			if !membership.Role.Satisfies(required) {
+				w.Header().Set("content-type", mediaTypeJSON)
+				w.WriteHeader(http.StatusForbidden)
+				_ = json.NewEncoder(w).Encode(errorResponse{Error: "insufficient role"})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
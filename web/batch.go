@@ -0,0 +1,127 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"enjoymultitenancy/repos"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+)
+
+var (
+	errBatchAborted = errors.New("batch aborted")
+
+	defaultMaxBatchOperations = 100
+)
+
+// WithTransactor configures the transactor used to run the atomic batch operations.
+func WithTransactor(t Transactor) NewServerOption {
+	return func(s *Server) { s.transactor = t }
+}
+
+type batchRequest struct {
+	// Atomic makes all operations committed only if all of them succeed.
+	Atomic     bool              `json:"atomic"`
+	Operations []*batchOperation `json:"operations"`
+}
+
+type batchOperation struct {
+	Op   string          `json:"op"`
+	Body json.RawMessage `json:"body"`
+}
+
+type batchResponse struct {
+	Results []*batchResult `json:"results"`
+}
+
+type batchResult struct {
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+func (s *Server) handlePostBatch() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		w.Header().Set("content-type", mediaTypeJSON)
+		if mt, _, _ := mime.ParseMediaType(r.Header.Get("content-type")); mt != mediaTypeJSON {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: fmt.Sprintf("invalid request content type: %s", mt)})
+			return
+		}
+		defer r.Body.Close()
+		req := new(batchRequest)
+		if err := s.decodeJSON(r.Body, req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: fmt.Sprintf("failed to decode request body: %s", err)})
+			return
+		}
+		if len(req.Operations) == 0 || len(req.Operations) > defaultMaxBatchOperations {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: fmt.Sprintf("the number of operations must be between 1 and %d", defaultMaxBatchOperations)})
+			return
+		}
+		if req.Atomic && s.transactor == nil {
+			w.WriteHeader(http.StatusNotImplemented)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "atomic batch is not supported"})
+			return
+		}
+
+		results := make([]*batchResult, len(req.Operations))
+		if !req.Atomic {
+			for i, op := range req.Operations {
+				results[i] = s.runBatchOperation(ctx, op)
+			}
+			_ = json.NewEncoder(w).Encode(batchResponse{Results: results})
+			return
+		}
+		err := s.transactor.RunInTx(ctx, func(ctx context.Context) error {
+			for i, op := range req.Operations {
+				results[i] = s.runBatchOperation(ctx, op)
+				if results[i].Error != "" {
+					return errBatchAborted
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			if !errors.Is(err, errBatchAborted) {
+				slog.ErrorContext(ctx, "failed to run batch in transaction", slog.String("error", err.Error()))
+			}
+			for i, result := range results {
+				switch {
+				case result == nil:
+					results[i] = &batchResult{Status: http.StatusFailedDependency, Error: "not executed because the batch was aborted"}
+				case result.Error == "":
+					results[i] = &batchResult{Status: http.StatusFailedDependency, Error: "rolled back because the batch was aborted"}
+				}
+			}
+		}
+		_ = json.NewEncoder(w).Encode(batchResponse{Results: results})
+	})
+}
+
+func (s *Server) runBatchOperation(ctx context.Context, op *batchOperation) *batchResult {
+	switch op.Op {
+	case "create_user":
+		userToRegister := new(repos.UserToRegister)
+		if err := json.Unmarshal(op.Body, userToRegister); err != nil {
+			return &batchResult{Status: http.StatusBadRequest, Error: fmt.Sprintf("failed to decode operation body: %s", err)}
+		}
+		_, err := s.userRepo.RegisterUser(ctx, userToRegister)
+		var validationErr *repos.ValidationError
+		switch {
+		case errors.As(err, &validationErr):
+			return &batchResult{Status: http.StatusBadRequest, Error: err.Error()}
+		case errors.Is(err, repos.ErrUserAlreadyExists):
+			return &batchResult{Status: http.StatusConflict, Error: err.Error()}
+		case err != nil:
+			return &batchResult{Status: http.StatusInternalServerError, Error: fmt.Sprintf("failed to register user: %s", err)}
+		}
+		return &batchResult{Status: http.StatusCreated}
+	default:
+		return &batchResult{Status: http.StatusBadRequest, Error: fmt.Sprintf("unsupported operation: %q", op.Op)}
+	}
+}
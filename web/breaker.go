@@ -0,0 +1,79 @@
+package web
+
+import (
+	"encoding/json"
+	"enjoymultitenancy/adapters"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// WithCircuitBreaker guards the tenant-facing routes with the circuit breaker of the database they depend on.
+//
+// The server errors are counted as failures of the dependency, and the requests are rejected with 503 while the circuit is open.
+func WithCircuitBreaker(b *adapters.CircuitBreaker) NewServerOption {
+	return func(s *Server) { s.circuitBreakers = append(s.circuitBreakers, b) }
+}
+
+type circuitOpenResponse struct {
+	Error      string `json:"error"`
+	Dependency string `json:"dependency"`
+}
+
+func circuitBreakerMiddleware(b *adapters.CircuitBreaker) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := b.Allow(); err != nil {
+				var openErr *adapters.CircuitOpenError
+				if errors.As(err, &openErr) {
+					w.Header().Set("retry-after", strconv.Itoa(int(math.Ceil(openErr.RetryAfter.Seconds()))))
+				}
+				w.Header().Set("content-type", mediaTypeJSON)
+				w.WriteHeader(http.StatusServiceUnavailable)
+				_ = json.NewEncoder(w).Encode(circuitOpenResponse{Error: err.Error(), Dependency: b.Dependency()})
+				return
+			}
+			sw := &statusRecordingResponseWriter{ResponseWriter: w, status: http.StatusOK}
+			// the outcome must be reported even if the handler panics, otherwise the half-open circuit never permits another call
+			defer func() {
+				if rv := recover(); rv != nil {
+					b.Failure()
+					panic(rv)
+				}
+				if sw.status >= http.StatusInternalServerError {
+					b.Failure()
+				} else {
+					b.Success()
+				}
+			}()
+			next.ServeHTTP(sw, r)
+		})
+	}
+}
+
+type statusRecordingResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusRecordingResponseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecordingResponseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusRecordingResponseWriter) Flush() {
+	w.wroteHeader = true
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *statusRecordingResponseWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
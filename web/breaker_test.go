@@ -0,0 +1,99 @@
+package web
+
+import (
+	"enjoymultitenancy/adapters"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerMiddleware_halfOpenTrial(t *testing.T) {
+	const openDuration = time.Millisecond * 10
+	testCases := []struct {
+		name            string
+		trial           http.HandlerFunc
+		wantPanic       bool
+		wantNextStatus  int
+		wantAfterReopen int
+	}{
+		{
+			name:            "succeeded",
+			trial:           func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) },
+			wantNextStatus:  http.StatusOK,
+			wantAfterReopen: http.StatusOK,
+		},
+		{
+			name:            "failed",
+			trial:           func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusInternalServerError) },
+			wantNextStatus:  http.StatusServiceUnavailable,
+			wantAfterReopen: http.StatusOK,
+		},
+		{
+			name:            "panicked",
+			trial:           func(http.ResponseWriter, *http.Request) { panic("oops") },
+			wantPanic:       true,
+			wantNextStatus:  http.StatusServiceUnavailable,
+			wantAfterReopen: http.StatusOK,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := adapters.NewCircuitBreaker("mysql", adapters.WithOpenDuration(openDuration))
+			b.Trip()
+			time.Sleep(openDuration)
+
+			mw := circuitBreakerMiddleware(b)
+			func() {
+				defer func() {
+					if rv := recover(); (rv != nil) != tc.wantPanic {
+						t.Errorf("recovered = %v, wantPanic = %v", rv, tc.wantPanic)
+					}
+				}()
+				mw(tc.trial).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+			}()
+
+			ok := mw(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) }))
+			rec := httptest.NewRecorder()
+			ok.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			if rec.Code != tc.wantNextStatus {
+				t.Errorf("status of the next request = %d, want %d", rec.Code, tc.wantNextStatus)
+			}
+
+			time.Sleep(openDuration)
+			rec = httptest.NewRecorder()
+			ok.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			if rec.Code != tc.wantAfterReopen {
+				t.Errorf("status after the open duration = %d, want %d", rec.Code, tc.wantAfterReopen)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerMiddleware_open(t *testing.T) {
+	b := adapters.NewCircuitBreaker("mysql", adapters.WithFailureThreshold(2), adapters.WithOpenDuration(time.Minute))
+	called := 0
+	h := circuitBreakerMiddleware(b)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		called++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	wantStatuses := []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusServiceUnavailable}
+	for i, want := range wantStatuses {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != want {
+			t.Errorf("#%d: status = %d, want %d", i, rec.Code, want)
+		}
+	}
+	if called != 2 {
+		t.Errorf("handler called %d times, want 2", called)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got := rec.Header().Get("retry-after"); got != "60" {
+		t.Errorf("retry-after = %q, want %q", got, "60")
+	}
+	if got, want := rec.Body.String(), `{"error":"circuit breaker for mysql is open","dependency":"mysql"}`+"\n"; got != want {
+		t.Errorf("body = %q, want %q", got, want)
+	}
+}
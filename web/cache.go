@@ -0,0 +1,158 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/aereal/nagaya"
+)
+
+var defaultResponseCacheMaxEntries = 10000
+
+type NewResponseCacheOption func(c *ResponseCache)
+
+// WithResponseCacheMaxEntries configures the maximum number of the cached responses.
+func WithResponseCacheMaxEntries(n int) NewResponseCacheOption {
+	return func(c *ResponseCache) { c.maxEntries = n }
+}
+
+// NewResponseCache returns a ResponseCache that keeps the responses for the TTL.
+func NewResponseCache(ttl time.Duration, optFns ...NewResponseCacheOption) *ResponseCache {
+	c := &ResponseCache{ttl: ttl, entries: map[string]*cacheEntry{}}
+	for _, f := range optFns {
+		f(c)
+	}
+	if c.maxEntries == 0 {
+		c.maxEntries = defaultResponseCacheMaxEntries
+	}
+	return c
+}
+
+// ResponseCache caches the successful responses of the idempotent routes per tenant.
+//
+// The entries are tagged with the resource the route reads, and InvalidateResource drops the entries of the resource within the tenant.
+type ResponseCache struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mux     sync.RWMutex
+	entries map[string]*cacheEntry
+}
+
+type cacheEntry struct {
+	tenant    nagaya.Tenant
+	resource  string
+	expiresAt time.Time
+	header    http.Header
+	body      []byte
+}
+
+// InvalidateResource drops the cached responses of the resource within the tenant bound to the context.
+//
+// Its signature matches repos.WriteHook so that it can be fired by the repos on writes.
+func (c *ResponseCache) InvalidateResource(ctx context.Context, resource string) {
+	tenant, ok := nagaya.TenantFromContext(ctx)
+	if !ok {
+		return
+	}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	for key, entry := range c.entries {
+		if entry.tenant == tenant && entry.resource == resource {
+			delete(c.entries, key)
+		}
+	}
+}
+
+func (c *ResponseCache) get(key string, now time.Time) (*cacheEntry, bool) {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	entry, ok := c.entries[key]
+	if !ok || now.After(entry.expiresAt) {
+		return nil, false
+	}
+	return entry, true
+}
+
+func (c *ResponseCache) set(key string, entry *cacheEntry, now time.Time) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if len(c.entries) >= c.maxEntries {
+		for k, e := range c.entries {
+			if now.After(e.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= c.maxEntries {
+			return
+		}
+	}
+	c.entries[key] = entry
+}
+
+// middleware returns a middleware function that serves the cached responses of the route reading the resource.
+func (c *ResponseCache) middleware(resource string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenant, ok := nagaya.TenantFromContext(r.Context())
+			if !ok || r.Method != http.MethodGet {
+				next.ServeHTTP(w, r)
+				return
+			}
+			key := string(tenant) + " " + r.URL.RequestURI()
+			now := time.Now()
+			if entry, ok := c.get(key, now); ok {
+				for k, vs := range entry.header {
+					w.Header()[k] = vs
+				}
+				w.Header().Set("x-cache", "hit")
+				w.WriteHeader(http.StatusOK)
+				_, _ = w.Write(entry.body)
+				return
+			}
+			rec := &recordingResponseWriter{ResponseWriter: w, status: http.StatusOK}
+			w.Header().Set("x-cache", "miss")
+			outer := w.Header().Clone()
+			next.ServeHTTP(rec, r)
+			if rec.status != http.StatusOK {
+				return
+			}
+			header := headerSetByHandler(outer, w.Header())
+			c.set(key, &cacheEntry{tenant: tenant, resource: resource, expiresAt: now.Add(c.ttl), header: header, body: rec.body.Bytes()}, now)
+		})
+	}
+}
+
+// headerSetByHandler returns the header fields added or changed since the snapshot taken before calling the handler.
+//
+// The fields set by the outer middlewares such as x-request-id are specific to the request, so they must not be replayed on the cache hits.
+func headerSetByHandler(before, after http.Header) http.Header {
+	header := http.Header{}
+	for k, vs := range after {
+		if prev, ok := before[k]; ok && slices.Equal(prev, vs) {
+			continue
+		}
+		header[k] = slices.Clone(vs)
+	}
+	return header
+}
+
+type recordingResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recordingResponseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingResponseWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,96 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aereal/nagaya"
+)
+
+func TestResponseCache_middleware(t *testing.T) {
+	cache := NewResponseCache(time.Minute)
+	calls := 0
+	reqSeq := 0
+	h := cache.middleware("users")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("content-type", mediaTypeJSON)
+		if r.URL.Query().Get("fail") != "" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, `{"calls":%d}`, calls)
+	}))
+	// the outer middleware sets the header specific to the request
+	outer := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqSeq++
+		w.Header().Set("x-request-id", fmt.Sprintf("req_%d", reqSeq))
+		h.ServeHTTP(w, r)
+	})
+	do := func(tenant nagaya.Tenant, method, target string) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest(method, target, nil)
+		r = r.WithContext(nagaya.WithTenant(r.Context(), tenant))
+		rec := httptest.NewRecorder()
+		outer.ServeHTTP(rec, r)
+		return rec
+	}
+
+	type want struct {
+		cache     string
+		body      string
+		requestID string
+	}
+	steps := []struct {
+		name   string
+		tenant nagaya.Tenant
+		method string
+		target string
+		want   want
+	}{
+		{name: "miss", tenant: "tenant_1", method: http.MethodGet, target: "/users/alice", want: want{cache: "miss", body: `{"calls":1}`, requestID: "req_1"}},
+		{name: "hit", tenant: "tenant_1", method: http.MethodGet, target: "/users/alice", want: want{cache: "hit", body: `{"calls":1}`, requestID: "req_2"}},
+		{name: "other tenant", tenant: "tenant_2", method: http.MethodGet, target: "/users/alice", want: want{cache: "miss", body: `{"calls":2}`, requestID: "req_3"}},
+		{name: "other query", tenant: "tenant_1", method: http.MethodGet, target: "/users/alice?fields=id", want: want{cache: "miss", body: `{"calls":3}`, requestID: "req_4"}},
+		{name: "not GET", tenant: "tenant_1", method: http.MethodPost, target: "/users/alice", want: want{body: `{"calls":4}`, requestID: "req_5"}},
+		{name: "error is not cached", tenant: "tenant_1", method: http.MethodGet, target: "/users/alice?fail=1", want: want{cache: "miss", requestID: "req_6"}},
+		{name: "error is not cached/again", tenant: "tenant_1", method: http.MethodGet, target: "/users/alice?fail=1", want: want{cache: "miss", requestID: "req_7"}},
+	}
+	for _, step := range steps {
+		rec := do(step.tenant, step.method, step.target)
+		if got := rec.Header().Get("x-cache"); got != step.want.cache {
+			t.Errorf("%s: x-cache = %q, want %q", step.name, got, step.want.cache)
+		}
+		if got := rec.Body.String(); got != step.want.body {
+			t.Errorf("%s: body = %q, want %q", step.name, got, step.want.body)
+		}
+		if got := rec.Header().Values("x-request-id"); len(got) != 1 || got[0] != step.want.requestID {
+			t.Errorf("%s: x-request-id = %q, want %q", step.name, got, step.want.requestID)
+		}
+		if got := rec.Header().Get("content-type"); got != mediaTypeJSON {
+			t.Errorf("%s: content-type = %q, want %q", step.name, got, mediaTypeJSON)
+		}
+	}
+
+	cache.InvalidateResource(nagaya.WithTenant(context.Background(), "tenant_2"), "users")
+	if rec := do("tenant_1", http.MethodGet, "/users/alice"); rec.Header().Get("x-cache") != "hit" {
+		t.Errorf("the entry of the other tenant is invalidated")
+	}
+	cache.InvalidateResource(nagaya.WithTenant(context.Background(), "tenant_1"), "users")
+	if rec := do("tenant_1", http.MethodGet, "/users/alice"); rec.Header().Get("x-cache") != "miss" {
+		t.Errorf("the entry is not invalidated")
+	}
+}
+
+func TestHeaderSetByHandler(t *testing.T) {
+	before := http.Header{"X-Request-Id": {"req_1"}, "Vary": {"accept"}}
+	after := http.Header{"X-Request-Id": {"req_1"}, "Vary": {"accept", "accept-encoding"}, "Content-Type": {"application/json"}}
+	got := headerSetByHandler(before, after)
+	want := http.Header{"Vary": {"accept", "accept-encoding"}, "Content-Type": {"application/json"}}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("headerSetByHandler() = %v, want %v", got, want)
+	}
+}
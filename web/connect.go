@@ -0,0 +1,75 @@
+package web
+
+import (
+	"context"
+	"enjoymultitenancy/auth"
+	usersv1 "enjoymultitenancy/gen/users/v1"
+	"enjoymultitenancy/gen/users/v1/usersv1connect"
+	"enjoymultitenancy/repos"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"connectrpc.com/connect"
+)
+
+// connectRoutes returns the routes serving the Connect, gRPC and gRPC-Web protocols.
+//
+// The procedures are mounted on the same group as the JSON API, so they go through the same auth and apartment middlewares.
+func (s *Server) connectRoutes() []route {
+	_, h := usersv1connect.NewUserServiceHandler(&userServiceHandler{userRepo: s.userRepo}, connect.WithInterceptors(connectLoggingInterceptor()))
+	return []route{
+		{method: http.MethodPost, path: usersv1connect.UserServiceRegisterUserProcedure, role: auth.RoleEditor, handler: h},
+		{method: http.MethodPost, path: usersv1connect.UserServiceGetUserProcedure, role: auth.RoleViewer, handler: h},
+	}
+}
+
+func connectLoggingInterceptor() connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			slog.InfoContext(ctx, "handle RPC", slog.String("procedure", req.Spec().Procedure), slog.String("protocol", req.Peer().Protocol))
+			return next(ctx, req)
+		}
+	}
+}
+
+type userServiceHandler struct {
+	usersv1connect.UnimplementedUserServiceHandler
+	userRepo UserRepository
+}
+
+var _ usersv1connect.UserServiceHandler = (*userServiceHandler)(nil)
+
+func (h *userServiceHandler) RegisterUser(ctx context.Context, req *connect.Request[usersv1.RegisterUserRequest]) (*connect.Response[usersv1.RegisterUserResponse], error) {
+	user, err := h.userRepo.RegisterUser(ctx, &repos.UserToRegister{Name: req.Msg.GetName()})
+	if err != nil {
+		return nil, toConnectError(ctx, req.Spec().Procedure, err)
+	}
+	return connect.NewResponse(&usersv1.RegisterUserResponse{User: &usersv1.User{Id: user.ID, Name: user.Name}}), nil
+}
+
+func (h *userServiceHandler) GetUser(ctx context.Context, req *connect.Request[usersv1.GetUserRequest]) (*connect.Response[usersv1.GetUserResponse], error) {
+	user, err := h.userRepo.FetchUserByName(ctx, req.Msg.GetName())
+	if err != nil {
+		return nil, toConnectError(ctx, req.Spec().Procedure, err)
+	}
+	return connect.NewResponse(&usersv1.GetUserResponse{User: &usersv1.User{Id: user.ID, Name: user.Name}}), nil
+}
+
+var errConnectInternal = errors.New("internal error")
+
+// toConnectError maps the error of the repos to the Connect error; the unexpected errors are logged and hidden behind a generic message, since they may contain SQL or the messages of the driver.
+func toConnectError(ctx context.Context, procedure string, err error) error {
+	var validationErr *repos.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		return connect.NewError(connect.CodeInvalidArgument, err)
+	case errors.Is(err, repos.ErrNotFound):
+		return connect.NewError(connect.CodeNotFound, err)
+	case errors.Is(err, repos.ErrUserAlreadyExists):
+		return connect.NewError(connect.CodeAlreadyExists, err)
+	default:
+		slog.ErrorContext(ctx, "RPC failed", slog.String("procedure", procedure), slog.String("error", err.Error()))
+		return connect.NewError(connect.CodeInternal, errConnectInternal)
+	}
+}
@@ -0,0 +1,44 @@
+package web
+
+import (
+	"context"
+	"enjoymultitenancy/repos"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+)
+
+func TestToConnectError(t *testing.T) {
+	testCases := []struct {
+		name        string
+		err         error
+		wantCode    connect.Code
+		wantMessage string
+	}{
+		{name: "validation", err: repos.ErrUserNameRequired, wantCode: connect.CodeInvalidArgument, wantMessage: repos.ErrUserNameRequired.Error()},
+		{name: "not found", err: fmt.Errorf("FetchUserByName: %w", repos.ErrNotFound), wantCode: connect.CodeNotFound, wantMessage: "FetchUserByName: not found"},
+		{name: "already exists", err: repos.ErrUserAlreadyExists, wantCode: connect.CodeAlreadyExists, wantMessage: repos.ErrUserAlreadyExists.Error()},
+		{name: "internal", err: errors.New("Error 1146 (42S02): Table 'tenant_1.users' doesn't exist"), wantCode: connect.CodeInternal, wantMessage: "internal error"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := toConnectError(context.Background(), "/users.v1.UserService/GetUser", tc.err)
+			var connectErr *connect.Error
+			if !errors.As(err, &connectErr) {
+				t.Fatalf("error = %v, want *connect.Error", err)
+			}
+			if connectErr.Code() != tc.wantCode {
+				t.Errorf("code = %v, want %v", connectErr.Code(), tc.wantCode)
+			}
+			if connectErr.Message() != tc.wantMessage {
+				t.Errorf("message = %q, want %q", connectErr.Message(), tc.wantMessage)
+			}
+			if tc.wantCode == connect.CodeInternal && strings.Contains(err.Error(), "tenant_1") {
+				t.Errorf("the internal error is exposed: %s", err)
+			}
+		})
+	}
+}
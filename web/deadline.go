@@ -0,0 +1,72 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var defaultMaxRequestTimeout = time.Second * 30
+
+// WithMaxRequestTimeout configures the upper bound of the deadline requested by the clients.
+func WithMaxRequestTimeout(d time.Duration) NewServerOption {
+	return func(s *Server) { s.maxRequestTimeout = d }
+}
+
+var grpcTimeoutUnits = map[byte]time.Duration{
+	'H': time.Hour,
+	'M': time.Minute,
+	'S': time.Second,
+	'm': time.Millisecond,
+	'u': time.Microsecond,
+	'n': time.Nanosecond,
+}
+
+// requestTimeout returns the timeout requested by the client.
+//
+// X-Request-Timeout accepts a duration string such as 1.5s or a number of seconds, and grpc-timeout follows the gRPC over HTTP/2 spec.
+func requestTimeout(r *http.Request) (time.Duration, bool, error) {
+	if v := r.Header.Get("x-request-timeout"); v != "" {
+		if secs, err := strconv.ParseFloat(v, 64); err == nil {
+			return time.Duration(secs * float64(time.Second)), true, nil
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return 0, false, fmt.Errorf("invalid x-request-timeout: %q", v)
+		}
+		return d, true, nil
+	}
+	if v := r.Header.Get("grpc-timeout"); len(v) >= 2 {
+		unit, ok := grpcTimeoutUnits[v[len(v)-1]]
+		n, err := strconv.ParseInt(v[:len(v)-1], 10, 64)
+		if !ok || err != nil {
+			return 0, false, fmt.Errorf("invalid grpc-timeout: %q", v)
+		}
+		return time.Duration(n) * unit, true, nil
+	}
+	return 0, false, nil
+}
+
+// withRequestDeadline derives the deadline of the request context from the timeout requested by the client, bounded by the server maximum.
+//
+// The slow queries are canceled along with the context when the client gives up, instead of being left running.
+func (s *Server) withRequestDeadline(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout, ok, err := requestTimeout(r)
+		if err != nil {
+			w.Header().Set("content-type", mediaTypeJSON)
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: err.Error()})
+			return
+		}
+		if !ok || timeout <= 0 || timeout > s.maxRequestTimeout {
+			timeout = s.maxRequestTimeout
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
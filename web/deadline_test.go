@@ -0,0 +1,84 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	testCases := []struct {
+		name    string
+		header  map[string]string
+		want    time.Duration
+		wantOK  bool
+		wantErr bool
+	}{
+		{name: "none"},
+		{name: "seconds", header: map[string]string{"x-request-timeout": "1.5"}, want: time.Millisecond * 1500, wantOK: true},
+		{name: "duration", header: map[string]string{"x-request-timeout": "250ms"}, want: time.Millisecond * 250, wantOK: true},
+		{name: "invalid x-request-timeout", header: map[string]string{"x-request-timeout": "soon"}, wantErr: true},
+		{name: "grpc-timeout", header: map[string]string{"grpc-timeout": "100m"}, want: time.Millisecond * 100, wantOK: true},
+		{name: "grpc-timeout in hours", header: map[string]string{"grpc-timeout": "1H"}, want: time.Hour, wantOK: true},
+		{name: "invalid grpc-timeout unit", header: map[string]string{"grpc-timeout": "100x"}, wantErr: true},
+		{name: "invalid grpc-timeout value", header: map[string]string{"grpc-timeout": "1.5S"}, wantErr: true},
+		{name: "x-request-timeout takes precedence", header: map[string]string{"x-request-timeout": "2s", "grpc-timeout": "1S"}, want: time.Second * 2, wantOK: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range tc.header {
+				r.Header.Set(k, v)
+			}
+			got, ok, err := requestTimeout(r)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("error = %v, wantErr = %v", err, tc.wantErr)
+			}
+			if got != tc.want || ok != tc.wantOK {
+				t.Errorf("requestTimeout() = (%s, %v), want (%s, %v)", got, ok, tc.want, tc.wantOK)
+			}
+		})
+	}
+}
+
+func TestServer_withRequestDeadline(t *testing.T) {
+	const maxTimeout = time.Second * 10
+	testCases := []struct {
+		name         string
+		timeout      string
+		wantStatus   int
+		wantDeadline time.Duration
+	}{
+		{name: "requested", timeout: "2s", wantStatus: http.StatusOK, wantDeadline: time.Second * 2},
+		{name: "not requested", wantStatus: http.StatusOK, wantDeadline: maxTimeout},
+		{name: "exceeds the max", timeout: "1m", wantStatus: http.StatusOK, wantDeadline: maxTimeout},
+		{name: "non-positive", timeout: "-1s", wantStatus: http.StatusOK, wantDeadline: maxTimeout},
+		{name: "invalid", timeout: "soon", wantStatus: http.StatusBadRequest},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &Server{maxRequestTimeout: maxTimeout}
+			var remaining time.Duration
+			h := s.withRequestDeadline(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				deadline, ok := r.Context().Deadline()
+				if !ok {
+					t.Error("the context has no deadline")
+				}
+				remaining = time.Until(deadline)
+			}))
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.timeout != "" {
+				r.Header.Set("x-request-timeout", tc.timeout)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, r)
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tc.wantStatus)
+			}
+			if tc.wantDeadline > 0 && (remaining > tc.wantDeadline || remaining < tc.wantDeadline-time.Second) {
+				t.Errorf("remaining = %s, want about %s", remaining, tc.wantDeadline)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package web
+
+import (
+	"enjoymultitenancy/repos"
+	"net/http"
+	"strings"
+)
+
+// parseFields returns the field names requested by the fields query parameter.
+//
+// The nil is returned if the parameter is absent, which means all fields are requested.
+func parseFields(r *http.Request) []string {
+	v := r.URL.Query().Get("fields")
+	if v == "" {
+		return nil
+	}
+	var fields []string
+	for _, f := range strings.Split(v, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// sparseUser returns the requested fields of the user keyed the same as the full response, which encodes repos.User as is.
+func sparseUser(user *repos.User, fields []string) map[string]any {
+	m := make(map[string]any, len(fields))
+	for _, f := range fields {
+		switch f {
+		case "id":
+			m["ID"] = user.ID
+		case "name":
+			m["Name"] = user.Name
+		}
+	}
+	return m
+}
@@ -0,0 +1,69 @@
+package web
+
+import (
+	"encoding/json"
+	"enjoymultitenancy/repos"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestParseFields(t *testing.T) {
+	testCases := []struct {
+		target string
+		want   []string
+	}{
+		{target: "/users/alice", want: nil},
+		{target: "/users/alice?fields=", want: nil},
+		{target: "/users/alice?fields=id", want: []string{"id"}},
+		{target: "/users/alice?fields=id,%20name,,", want: []string{"id", "name"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.target, func(t *testing.T) {
+			got := parseFields(httptest.NewRequest(http.MethodGet, tc.target, nil))
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("parseFields() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSparseUser(t *testing.T) {
+	user := &repos.User{ID: "user_1", Name: "alice"}
+	b, err := json.Marshal(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var full map[string]any
+	if err := json.Unmarshal(b, &full); err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		fields   []string
+		wantKeys []string
+	}{
+		{fields: []string{"id"}, wantKeys: []string{"ID"}},
+		{fields: []string{"name"}, wantKeys: []string{"Name"}},
+		{fields: []string{"id", "name"}, wantKeys: []string{"ID", "Name"}},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprint(tc.fields), func(t *testing.T) {
+			got := sparseUser(user, tc.fields)
+			keys := make([]string, 0, len(got))
+			for k := range got {
+				keys = append(keys, k)
+			}
+			slices.Sort(keys)
+			if !slices.Equal(keys, tc.wantKeys) {
+				t.Errorf("keys = %q, want %q", keys, tc.wantKeys)
+			}
+			for k, v := range got {
+				if full[k] != v {
+					t.Errorf("%s = %v, want %v as the full response", k, v, full[k])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// WithH2C makes the server accept HTTP/2 over cleartext (h2c) connections in addition to HTTP/1.1.
+//
+// It is intended to be used behind a trusted load balancer that terminates TLS.
+// The server always speaks HTTP/2 when TLS is enabled, so this option is ignored in that case.
+func WithH2C() NewServerOption {
+	return func(s *Server) { s.h2c = true }
+}
+
+// configureHTTP2 enables h2c on the server if requested.
+//
+// The HTTP/2 server is registered to the http.Server so that Shutdown sends GOAWAY frames to the active HTTP/2 connections.
+func (s *Server) configureHTTP2(hs *http.Server) error {
+	if !s.h2c || s.tlsEnabled() {
+		return nil
+	}
+	h2s := &http2.Server{}
+	if err := http2.ConfigureServer(hs, h2s); err != nil {
+		return fmt.Errorf("http2.ConfigureServer: %w", err)
+	}
+	hs.Handler = h2c.NewHandler(hs.Handler, h2s)
+	return nil
+}
@@ -0,0 +1,85 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"enjoymultitenancy/buildinfo"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var defaultReadinessCheckTimeout = time.Second * 2
+
+// ReadinessCheck reports whether the dependency is ready to serve the traffic.
+type ReadinessCheck func(ctx context.Context) error
+
+// WithReadinessCheck registers the check that must pass before /readyz reports the server is ready.
+func WithReadinessCheck(name string, check ReadinessCheck) NewServerOption {
+	return func(s *Server) {
+		if s.readinessChecks == nil {
+			s.readinessChecks = map[string]ReadinessCheck{}
+		}
+		s.readinessChecks[name] = check
+	}
+}
+
+type readinessResponse struct {
+	Ready  bool              `json:"ready"`
+	Checks map[string]string `json:"checks"`
+}
+
+func (s *Server) handleGetHealthz() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", mediaTypeJSON)
+		_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	})
+}
+
+func (s *Server) handleGetVersion() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", mediaTypeJSON)
+		_ = json.NewEncoder(w).Encode(buildinfo.Get())
+	})
+}
+
+func (s *Server) handleGetReadyz() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.draining.Load() {
+			w.Header().Set("content-type", mediaTypeJSON)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_ = json.NewEncoder(w).Encode(readinessResponse{Ready: false, Checks: map[string]string{"server": "draining"}})
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), defaultReadinessCheckTimeout)
+		defer cancel()
+		resp := readinessResponse{Ready: true, Checks: make(map[string]string, len(s.readinessChecks))}
+		var (
+			wg  sync.WaitGroup
+			mux sync.Mutex
+		)
+		for name, check := range s.readinessChecks {
+			wg.Add(1)
+			go func(name string, check ReadinessCheck) {
+				defer wg.Done()
+				result := "ok"
+				err := check(ctx)
+				if err != nil {
+					result = err.Error()
+				}
+				mux.Lock()
+				defer mux.Unlock()
+				resp.Checks[name] = result
+				if err != nil {
+					resp.Ready = false
+				}
+			}(name, check)
+		}
+		wg.Wait()
+		w.Header().Set("content-type", mediaTypeJSON)
+		if !resp.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+}
@@ -0,0 +1,67 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+)
+
+// WithConcurrencyLimit limits the number of the tenant-facing requests processed concurrently.
+//
+// The requests exceeding maxInFlight wait for a slot, and the requests exceeding queueDepth while waiting are shed with 503.
+func WithConcurrencyLimit(maxInFlight, queueDepth int) NewServerOption {
+	return func(s *Server) { s.concurrencyLimiter.Store(newConcurrencyLimiter(maxInFlight, queueDepth)) }
+}
+
+// limitConcurrency applies the current concurrency limiter, which ApplyRuntimeConfig may replace while the server is running.
+func (s *Server) limitConcurrency(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l := s.concurrencyLimiter.Load()
+		if l == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		l.middleware(next).ServeHTTP(w, r)
+	})
+}
+
+func newConcurrencyLimiter(maxInFlight, queueDepth int) *concurrencyLimiter {
+	return &concurrencyLimiter{slots: make(chan struct{}, maxInFlight), queueDepth: int64(queueDepth)}
+}
+
+type concurrencyLimiter struct {
+	slots      chan struct{}
+	queueDepth int64
+	queued     atomic.Int64
+}
+
+func (l *concurrencyLimiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case l.slots <- struct{}{}:
+		default:
+			if l.queued.Add(1) > l.queueDepth {
+				l.queued.Add(-1)
+				shed(w, "too many requests in flight")
+				return
+			}
+			select {
+			case l.slots <- struct{}{}:
+				l.queued.Add(-1)
+			case <-r.Context().Done():
+				l.queued.Add(-1)
+				shed(w, "timed out waiting for the request to be processed")
+				return
+			}
+		}
+		defer func() { <-l.slots }()
+		next.ServeHTTP(w, r)
+	})
+}
+
+func shed(w http.ResponseWriter, msg string) {
+	w.Header().Set("content-type", mediaTypeJSON)
+	w.Header().Set("retry-after", "1")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	_ = json.NewEncoder(w).Encode(errorResponse{Error: msg})
+}
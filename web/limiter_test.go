@@ -0,0 +1,78 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	testCases := []struct {
+		name       string
+		queueDepth int
+		// releaseWhileQueued releases the slot once the second request is queued, otherwise after it is responded
+		releaseWhileQueued bool
+		// waiterTimeout bounds how long the second request waits for the slot
+		waiterTimeout time.Duration
+		wantStatus    int
+	}{
+		{name: "queued and processed", queueDepth: 1, releaseWhileQueued: true, wantStatus: http.StatusOK},
+		{name: "no room in the queue", queueDepth: 0, wantStatus: http.StatusServiceUnavailable},
+		{name: "timed out in the queue", queueDepth: 1, waiterTimeout: time.Millisecond * 10, wantStatus: http.StatusServiceUnavailable},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := newConcurrencyLimiter(1, tc.queueDepth)
+			entered := make(chan struct{})
+			release := make(chan struct{})
+			h := l.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/blocking" {
+					close(entered)
+					<-release
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/blocking", nil))
+			}()
+			<-entered
+
+			ctx := context.Background()
+			if tc.waiterTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.waiterTimeout)
+				defer cancel()
+			}
+			if tc.releaseWhileQueued {
+				go func() {
+					for l.queued.Load() == 0 {
+						time.Sleep(time.Millisecond)
+					}
+					close(release)
+				}()
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+			if rec.Code != tc.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tc.wantStatus)
+			}
+			if rec.Code == http.StatusServiceUnavailable && rec.Header().Get("retry-after") == "" {
+				t.Error("retry-after is missing")
+			}
+			if !tc.releaseWhileQueued {
+				close(release)
+			}
+			<-done
+			if got := l.queued.Load(); got != 0 {
+				t.Errorf("queued = %d, want 0", got)
+			}
+			if got := len(l.slots); got != 0 {
+				t.Errorf("slots in use = %d, want 0", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+const defaultMaintenanceMessage = "the service is under maintenance"
+
+// maintenanceMode is the state of the maintenance mode toggled by the admin endpoint.
+type maintenanceMode struct {
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message,omitempty"`
+}
+
+// withMaintenance sheds the tenant-facing requests with 503 while the maintenance mode is enabled.
+//
+// The health checks and the admin routes are not affected so that the operators can turn the mode off.
+func (s *Server) withMaintenance(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m := s.maintenance.Load(); m != nil && m.Enabled {
+			msg := m.Message
+			if msg == "" {
+				msg = defaultMaintenanceMessage
+			}
+			shed(w, msg)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleGetAdminMaintenance() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("content-type", mediaTypeJSON)
+		m := s.maintenance.Load()
+		if m == nil {
+			m = &maintenanceMode{}
+		}
+		_ = json.NewEncoder(w).Encode(m)
+	})
+}
+
+func (s *Server) handlePutAdminMaintenance() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		w.Header().Set("content-type", mediaTypeJSON)
+		defer r.Body.Close()
+		req := new(maintenanceMode)
+		if err := s.decodeJSON(r.Body, req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: fmt.Sprintf("failed to decode request body: %s", err)})
+			return
+		}
+		s.maintenance.Store(req)
+		slog.WarnContext(ctx, "maintenance mode changed", slog.Bool("enabled", req.Enabled), slog.String("message", req.Message))
+		_ = json.NewEncoder(w).Encode(req)
+	})
+}
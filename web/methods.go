@@ -0,0 +1,37 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/dimfeld/httptreemux/v5"
+)
+
+// handleMethodNotAllowed answers OPTIONS with the methods the route supports, and rejects other methods with the Allow header.
+//
+// HEAD is listed for the routes having GET, since the mux serves HEAD with the GET handlers.
+func handleMethodNotAllowed(w http.ResponseWriter, r *http.Request, methods map[string]httptreemux.HandlerFunc) {
+	allowed := make([]string, 0, len(methods)+2)
+	for m := range methods {
+		allowed = append(allowed, m)
+	}
+	if _, ok := methods[http.MethodGet]; ok {
+		if _, ok := methods[http.MethodHead]; !ok {
+			allowed = append(allowed, http.MethodHead)
+		}
+	}
+	if _, ok := methods[http.MethodOptions]; !ok {
+		allowed = append(allowed, http.MethodOptions)
+	}
+	slices.Sort(allowed)
+	w.Header().Set("allow", strings.Join(allowed, ", "))
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("content-type", mediaTypeJSON)
+	w.WriteHeader(http.StatusMethodNotAllowed)
+	_ = json.NewEncoder(w).Encode(errorResponse{Error: "method not allowed"})
+}
@@ -0,0 +1,82 @@
+package web
+
+import (
+	"enjoymultitenancy/telemetry"
+	"net/http"
+	"time"
+
+	"github.com/aereal/nagaya"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WithMeterProvider configures the MeterProvider that the server reports its metrics to, such as the durations of the requests.
+//
+// The global MeterProvider is used by default.
+func WithMeterProvider(mp metric.MeterProvider) NewServerOption {
+	return func(s *Server) { s.meterProvider = mp }
+}
+
+// WithTenantGuard makes the server break its metrics down by the tenant, bucketed by the guard to bound their cardinality.
+//
+// Without the guard, the tenant is added as it is, and is expected to be dropped by the views of the MeterProvider.
+func WithTenantGuard(g *telemetry.TenantGuard) NewServerOption {
+	return func(s *Server) { s.tenantGuard = g }
+}
+
+// serverMetrics is the set of the instruments the server records to.
+type serverMetrics struct {
+	apartmentDuration metric.Float64Histogram
+}
+
+func newServerMetrics(meter metric.Meter) *serverMetrics {
+	m := &serverMetrics{}
+	var err error
+	m.apartmentDuration, err = meter.Float64Histogram("apartment.duration",
+		metric.WithDescription("The time taken by the apartment middleware to resolve the tenant and obtain its connection."),
+		metric.WithUnit("s"))
+	if err != nil {
+		otel.Handle(err)
+		m.apartmentDuration = noop.Float64Histogram{}
+	}
+	return m
+}
+
+// instrumentApartment measures the time between the apartment middleware receives the request and passes it to the next handler.
+//
+// The requests the middleware rejects, such as the ones with an unknown tenant, are recorded with apartment.outcome=rejected.
+// The tenant is also added to the attributes of the HTTP server metrics and to the server span as tenant.id.
+func (s *Server) instrumentApartment(mw func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			passed := false
+			inner := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				passed = true
+				ctx := r.Context()
+				tenant, _ := nagaya.TenantFromContext(ctx)
+				trace.SpanFromContext(ctx).SetAttributes(attribute.String("tenant.id", string(tenant)))
+				tenantAttr := telemetry.TenantKey.String(string(tenant))
+				if s.tenantGuard != nil {
+					tenantAttr = s.tenantGuard.Attribute(ctx)
+				}
+				if labeler, ok := otelhttp.LabelerFromContext(ctx); ok {
+					labeler.Add(tenantAttr)
+				}
+				s.metrics.apartmentDuration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(
+					attribute.String("apartment.outcome", "obtained"),
+					tenantAttr))
+				next.ServeHTTP(w, r)
+			}))
+			inner.ServeHTTP(w, r)
+			if !passed {
+				s.metrics.apartmentDuration.Record(r.Context(), time.Since(start).Seconds(), metric.WithAttributes(
+					attribute.String("apartment.outcome", "rejected")))
+			}
+		})
+	}
+}
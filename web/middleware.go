@@ -0,0 +1,45 @@
+package web
+
+import "net/http"
+
+// MiddlewarePosition is a position in the middleware chain where the application middlewares are inserted.
+type MiddlewarePosition int
+
+const (
+	// PositionGlobal is the position right after the instrumentation middlewares.
+	// The middlewares at this position apply to all routes including the admin routes.
+	PositionGlobal MiddlewarePosition = iota
+	// PositionBeforeAuth is the position before the authentication of the tenant-facing routes.
+	PositionBeforeAuth
+	// PositionBeforeApartment is the position after the authentication and before the tenant is determined.
+	PositionBeforeApartment
+	// PositionAfterApartment is the position after the tenant is determined and right before the handlers.
+	PositionAfterApartment
+)
+
+// WithMiddlewares inserts the middlewares at the position.
+//
+// The middlewares at the same position are applied in the order they are given.
+func WithMiddlewares(pos MiddlewarePosition, mws ...func(http.Handler) http.Handler) NewServerOption {
+	return func(s *Server) { s.Use(pos, mws...) }
+}
+
+// Use inserts the middlewares at the position.
+//
+// It must be called before the server starts.
+func (s *Server) Use(pos MiddlewarePosition, mws ...func(http.Handler) http.Handler) {
+	if s.middlewares == nil {
+		s.middlewares = map[MiddlewarePosition][]func(http.Handler) http.Handler{}
+	}
+	s.middlewares[pos] = append(s.middlewares[pos], mws...)
+}
+
+type middlewareUser interface {
+	UseHandler(middleware func(http.Handler) http.Handler)
+}
+
+func (s *Server) useMiddlewaresAt(g middlewareUser, pos MiddlewarePosition) {
+	for _, mw := range s.middlewares[pos] {
+		g.UseHandler(mw)
+	}
+}
@@ -0,0 +1,118 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	defaultMirrorMaxBodySize    = int64(1 << 20)
+	defaultMirrorMaxConcurrency = 100
+	defaultMirrorTimeout        = time.Second * 10
+)
+
+// WithMirror asynchronously mirrors the sampled fraction of the tenant-facing requests to the target.
+//
+// The responses of the target are discarded and never affect the responses to the clients.
+// The requests whose body exceeds 1MiB are not mirrored, and the mirrored requests are dropped while too many of them are in flight.
+// Each mirrored request is sent within its own trace linked to the span of the original request, since it may outlive the original one.
+func WithMirror(target http.Handler, fraction float64) NewServerOption {
+	return func(s *Server) {
+		s.mirror = &mirror{
+			tracer:   otel.GetTracerProvider().Tracer("web.mirror"),
+			target:   target,
+			fraction: fraction,
+			slots:    make(chan struct{}, defaultMirrorMaxConcurrency),
+		}
+	}
+}
+
+// NewUpstreamMirror returns a handler that forwards the mirrored requests to the upstream.
+func NewUpstreamMirror(upstream *url.URL) http.Handler {
+	proxy := httputil.NewSingleHostReverseProxy(upstream)
+	proxy.Transport = otelhttp.NewTransport(http.DefaultTransport)
+	proxy.ErrorHandler = func(_ http.ResponseWriter, r *http.Request, err error) {
+		slog.DebugContext(r.Context(), "failed to mirror request", slog.String("error", err.Error()))
+	}
+	return proxy
+}
+
+type mirror struct {
+	tracer   trace.Tracer
+	target   http.Handler
+	fraction float64
+	slots    chan struct{}
+}
+
+func (m *mirror) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m.fraction <= 0 || rand.Float64() >= m.fraction {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, defaultMirrorMaxBodySize+1))
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if int64(len(body)) > defaultMirrorMaxBodySize {
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+			next.ServeHTTP(w, r)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		select {
+		case m.slots <- struct{}{}:
+			// the request is cloned before the handler runs, since the handler may modify it concurrently with the mirror
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), defaultMirrorTimeout)
+			mirrored := r.Clone(ctx)
+			mirrored.Body = io.NopCloser(bytes.NewReader(body))
+			go m.send(mirrored, trace.LinkFromContext(r.Context()), cancel)
+		default:
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// send sends the cloned request to the target and recovers its panics, since nothing recovers the panics of the goroutine.
+func (m *mirror) send(mirrored *http.Request, origin trace.Link, cancel context.CancelFunc) {
+	defer func() { <-m.slots }()
+	defer cancel()
+	ctx, span := m.tracer.Start(mirrored.Context(), "mirror",
+		trace.WithNewRoot(),
+		trace.WithLinks(origin))
+	defer span.End()
+	defer func() {
+		if rec := recover(); rec != nil {
+			err := fmt.Errorf("panic: %v", rec)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			slog.WarnContext(ctx, "recovered from panic while mirroring request", slog.String("error", err.Error()))
+		}
+	}()
+	m.target.ServeHTTP(&discardResponseWriter{header: http.Header{}}, mirrored.WithContext(ctx))
+}
+
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w *discardResponseWriter) Header() http.Header         { return w.header }
+func (w *discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardResponseWriter) WriteHeader(int)             {}
@@ -0,0 +1,88 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+)
+
+type mirroredRequest struct {
+	header string
+	body   string
+}
+
+func newTestMirror(target http.Handler, fraction float64) *mirror {
+	return &mirror{
+		tracer:   otel.GetTracerProvider().Tracer("web.mirror"),
+		target:   target,
+		fraction: fraction,
+		slots:    make(chan struct{}, 1),
+	}
+}
+
+func TestMirror_middleware(t *testing.T) {
+	testCases := []struct {
+		name         string
+		fraction     float64
+		body         string
+		targetPanics bool
+		wantMirrored bool
+	}{
+		{name: "mirrored", fraction: 1, body: `{"name":"alice"}`, wantMirrored: true},
+		{name: "not sampled", fraction: 0, body: `{"name":"alice"}`},
+		{name: "too large body", fraction: 1, body: strings.Repeat("a", int(defaultMirrorMaxBodySize)+1)},
+		{name: "target panicked", fraction: 1, body: `{"name":"alice"}`, targetPanics: true, wantMirrored: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			received := make(chan mirroredRequest, 1)
+			m := newTestMirror(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				received <- mirroredRequest{header: r.Header.Get("x-origin"), body: string(b)}
+				if tc.targetPanics {
+					panic("oops")
+				}
+			}), tc.fraction)
+			h := m.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// the handler modifies the request while the mirror may be running
+				r.Header.Set("x-origin", "modified")
+				b, _ := io.ReadAll(r.Body)
+				_, _ = w.Write(b)
+			}))
+			r := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(tc.body))
+			r.Header.Set("x-origin", "original")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, r)
+			if got := rec.Body.String(); got != tc.body {
+				t.Errorf("the handler read the body of %d bytes, want %d bytes", len(got), len(tc.body))
+			}
+
+			select {
+			case got := <-received:
+				if !tc.wantMirrored {
+					t.Fatal("the request is mirrored")
+				}
+				want := mirroredRequest{header: "original", body: tc.body}
+				if got != want {
+					t.Errorf("mirrored = %+v, want %+v", got, want)
+				}
+			case <-time.After(time.Millisecond * 100):
+				if tc.wantMirrored {
+					t.Fatal("the request is not mirrored")
+				}
+				return
+			}
+			// the slot is released even if the target panicked
+			select {
+			case m.slots <- struct{}{}:
+			case <-time.After(time.Second):
+				t.Error("the slot of the mirror is not released")
+			}
+		})
+	}
+}
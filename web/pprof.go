@@ -0,0 +1,27 @@
+package web
+
+import (
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/dimfeld/httptreemux/v5"
+)
+
+// WithPprof serves the profiles of net/http/pprof under /admin/pprof/, behind the admin middleware.
+//
+// /admin/pprof/profile captures the CPU profile and /admin/pprof/trace captures the execution trace for the seconds given by the query; the duration is bounded by the max request timeout.
+func WithPprof() NewServerOption {
+	return func(s *Server) { s.pprof = true }
+}
+
+func mountPprofRoutes(g *httptreemux.ContextGroup) {
+	g.Handler(http.MethodGet, "/pprof/", http.HandlerFunc(pprof.Index))
+	g.Handler(http.MethodGet, "/pprof/cmdline", http.HandlerFunc(pprof.Cmdline))
+	g.Handler(http.MethodGet, "/pprof/profile", http.HandlerFunc(pprof.Profile))
+	g.Handler(http.MethodGet, "/pprof/symbol", http.HandlerFunc(pprof.Symbol))
+	g.Handler(http.MethodPost, "/pprof/symbol", http.HandlerFunc(pprof.Symbol))
+	g.Handler(http.MethodGet, "/pprof/trace", http.HandlerFunc(pprof.Trace))
+	g.Handler(http.MethodGet, "/pprof/:name", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pprof.Handler(httptreemux.ContextParams(r.Context())["name"]).ServeHTTP(w, r)
+	}))
+}
@@ -0,0 +1,40 @@
+package web
+
+import (
+	"enjoymultitenancy/adapters"
+	"log/slog"
+	"net/http"
+
+	"github.com/dimfeld/httptreemux/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WithQueryBudget makes the server count the statements run while serving each request, and warn if a request runs more than the budget.
+//
+// It is meant to catch accidental N+1 queries, so the request is served as usual; the count is set on the server span and the overrun is logged and recorded as a span event.
+// The DB handle must be opened with adapters.WithQueryCounting.
+func WithQueryBudget(budget int) NewServerOption {
+	return func(s *Server) { s.queryBudget = budget }
+}
+
+func (s *Server) withQueryBudget(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := adapters.WithQueryCounter(r.Context())
+		next.ServeHTTP(w, r.WithContext(ctx))
+		count, _ := adapters.QueryCount(ctx)
+		span := trace.SpanFromContext(ctx)
+		span.SetAttributes(attribute.Int64("db.statement_count", count))
+		if count <= int64(s.queryBudget) {
+			return
+		}
+		route := httptreemux.ContextRoute(ctx)
+		slog.WarnContext(ctx, "query budget exceeded",
+			slog.String("http.route", route),
+			slog.Int64("db.statement_count", count),
+			slog.Int("budget", s.queryBudget))
+		span.AddEvent("query budget exceeded", trace.WithAttributes(
+			attribute.Int64("db.statement_count", count),
+			attribute.Int("budget", s.queryBudget)))
+	})
+}
@@ -0,0 +1,54 @@
+package web
+
+import (
+	"encoding/json"
+	"enjoymultitenancy/reporting"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/dimfeld/httptreemux/v5"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// withRecovery recovers the panics of the handlers and reports them along with the server errors through the reporting package.
+//
+// The panic is responded as 500 unless the handler has already written the response.
+// A server error is reported only if no error has been reported while serving the request, such as by the repos; 503 is not reported since it is responded on purpose while the server sheds the load.
+func withRecovery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := reporting.WithScope(r.Context())
+		sw := &statusRecordingResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+			err, ok := rec.(error)
+			if !ok {
+				err = fmt.Errorf("%v", rec)
+			}
+			err = fmt.Errorf("panic: %w", err)
+			span := trace.SpanFromContext(ctx)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			slog.ErrorContext(ctx, "recovered from panic", slog.String("error", err.Error()))
+			reporting.ReportPanic(ctx, err, debug.Stack())
+			if sw.wroteHeader {
+				return
+			}
+			w.Header().Set("content-type", mediaTypeJSON)
+			w.WriteHeader(http.StatusInternalServerError)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "internal server error"})
+		}()
+		next.ServeHTTP(sw, r.WithContext(ctx))
+		if sw.status >= http.StatusInternalServerError && sw.status != http.StatusServiceUnavailable && !reporting.Reported(ctx) {
+			reporting.Report(ctx, fmt.Errorf("%s %s responded with status %d", r.Method, httptreemux.ContextRoute(ctx), sw.status))
+		}
+	})
+}
@@ -0,0 +1,52 @@
+package web
+
+import (
+	"context"
+	"enjoymultitenancy/repos"
+)
+
+// UserRepository is the set of operations on the users the server depends on.
+type UserRepository interface {
+	RegisterUser(ctx context.Context, user *repos.UserToRegister) (*repos.User, error)
+	RegisterUsers(ctx context.Context, users []*repos.UserToRegister) ([]*repos.User, error)
+	FetchUserByName(ctx context.Context, name string, opts ...repos.FetchUserOption) (*repos.User, error)
+	UpdateUser(ctx context.Context, name string, opts ...repos.UpdateUserOption) (*repos.User, error)
+	DeleteUser(ctx context.Context, name string) error
+	ListUsers(ctx context.Context, opts ...repos.ListUsersOption) (*repos.UserPage, error)
+}
+
+// MembershipRepository is the set of operations on the memberships the server depends on.
+type MembershipRepository interface {
+	FetchMembership(ctx context.Context, subject string) (*repos.Membership, error)
+}
+
+// TenantRepository is the set of operations on the tenant registry the server depends on.
+type TenantRepository interface {
+	ListTenants(ctx context.Context) ([]*repos.Tenant, error)
+	FindTenant(ctx context.Context, id string) (*repos.Tenant, error)
+	FindTenantByDomain(ctx context.Context, domain string) (*repos.Tenant, error)
+	ListDomains(ctx context.Context) ([]*repos.TenantDomain, error)
+	PutDomain(ctx context.Context, domain string, tenantID string) (*repos.TenantDomain, repos.PutResult, error)
+	DeleteDomain(ctx context.Context, domain string) error
+	SuspendTenant(ctx context.Context, id string) error
+}
+
+// AuditRepository is the set of operations on the audit log the server depends on.
+type AuditRepository interface {
+	RecordAudit(ctx context.Context, entry *repos.AuditEntry) error
+	ListAuditEntries(ctx context.Context, opts ...repos.ListAuditEntriesOption) (*repos.AuditPage, error)
+}
+
+// Transactor runs functions within transactions that span the calls to the repositories.
+type Transactor interface {
+	RunInTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+var (
+	_ UserRepository       = (*repos.UserRepo)(nil)
+	_ UserRepository       = (*repos.CachedUserRepo)(nil)
+	_ MembershipRepository = (*repos.MembershipRepo)(nil)
+	_ TenantRepository     = (*repos.TenantRepo)(nil)
+	_ AuditRepository      = (*repos.AuditRepo)(nil)
+	_ Transactor           = (*repos.Transactor)(nil)
+)
@@ -0,0 +1,37 @@
+package web
+
+import (
+	"context"
+	"enjoymultitenancy/logging"
+	"net/http"
+
+	"github.com/aereal/nagaya"
+	"github.com/rs/xid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// withRequestID assigns an ID to the request and responds it in X-Request-Id.
+//
+// The ID is always generated by the server: nagaya keys the connections of the requests by the ID, so the IDs given by the clients must not be trusted.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := xid.New().String()
+		ctx := logging.ContextWithRequestID(r.Context(), id)
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("request.id", id))
+		w.Header().Set("x-request-id", id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequestIDGenerator returns the generator that makes nagaya reuse the ID assigned by the server, so the apartment shares the ID with the logs and the audit entries.
+//
+// It is intended to be passed to nagaya.WithRequestIDGenerator.
+func RequestIDGenerator() nagaya.RequestIDGenerator {
+	return nagaya.RequestIDGeneratorFunc(func(ctx context.Context, _ *http.Request) (string, error) {
+		if id, ok := logging.RequestIDFromContext(ctx); ok {
+			return id, nil
+		}
+		return xid.New().String(), nil
+	})
+}
@@ -0,0 +1,105 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"enjoymultitenancy/repos"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/aereal/nagaya"
+)
+
+// RuntimeConfig is the set of the settings that are safe to change while the server is running.
+type RuntimeConfig struct {
+	// LogLevel is the minimum level of the logs such as "debug"; the current level is kept if it is empty.
+	LogLevel string `json:"log_level,omitempty"`
+	// MaxInFlightRequests limits the number of the tenant-facing requests processed concurrently; no limit is applied if it is zero.
+	MaxInFlightRequests int `json:"max_in_flight_requests,omitempty"`
+	// RequestQueueDepth is the number of the requests allowed to wait for a slot while MaxInFlightRequests are processed.
+	RequestQueueDepth int `json:"request_queue_depth,omitempty"`
+	// TenantAllowlist is the tenants allowed to access the tenant-facing routes; every tenant is allowed if it is empty.
+	TenantAllowlist []string `json:"tenant_allowlist,omitempty"`
+}
+
+// Validate reports the invalid settings all at once.
+func (c *RuntimeConfig) Validate() error {
+	var errs []error
+	if c.LogLevel != "" {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+			errs = append(errs, fmt.Errorf("log_level: %w", err))
+		}
+	}
+	if c.MaxInFlightRequests < 0 {
+		errs = append(errs, errors.New("max_in_flight_requests must not be negative"))
+	}
+	if c.RequestQueueDepth < 0 {
+		errs = append(errs, errors.New("request_queue_depth must not be negative"))
+	}
+	for _, tenant := range c.TenantAllowlist {
+		if err := repos.ValidateTenantID(tenant); err != nil {
+			errs = append(errs, fmt.Errorf("tenant_allowlist: %w: %q", err, tenant))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ApplyRuntimeConfig validates the config and applies it to the running server.
+//
+// Nothing is changed if the config is invalid, so the server keeps running with the last valid config.
+// The requests in flight are not affected; the new concurrency limit and the allowlist apply to the requests arriving after the call.
+func (s *Server) ApplyRuntimeConfig(ctx context.Context, cfg *RuntimeConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if cfg.LogLevel != "" && s.logLevel == nil {
+		return errors.New("log_level: log level is not configurable")
+	}
+	if cfg.LogLevel != "" {
+		var level slog.Level
+		_ = level.UnmarshalText([]byte(cfg.LogLevel))
+		s.logLevel.Set(level)
+	}
+	var limiter *concurrencyLimiter
+	if cfg.MaxInFlightRequests > 0 {
+		limiter = newConcurrencyLimiter(cfg.MaxInFlightRequests, cfg.RequestQueueDepth)
+	}
+	s.concurrencyLimiter.Store(limiter)
+	var allowlist map[string]struct{}
+	if len(cfg.TenantAllowlist) > 0 {
+		allowlist = make(map[string]struct{}, len(cfg.TenantAllowlist))
+		for _, tenant := range cfg.TenantAllowlist {
+			allowlist[tenant] = struct{}{}
+		}
+	}
+	s.tenantAllowlist.Store(&allowlist)
+	s.runtimeConfig.Store(cfg)
+	slog.InfoContext(ctx, "runtime config applied",
+		slog.String("log_level", cfg.LogLevel),
+		slog.Int("max_in_flight_requests", cfg.MaxInFlightRequests),
+		slog.Int("request_queue_depth", cfg.RequestQueueDepth),
+		slog.Int("tenant_allowlist", len(cfg.TenantAllowlist)))
+	return nil
+}
+
+// withTenantAllowlist rejects the tenants absent from the allowlist of the runtime config with 403.
+func (s *Server) withTenantAllowlist(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allowlist := s.tenantAllowlist.Load()
+		if allowlist == nil || len(*allowlist) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		tenant, _ := nagaya.TenantFromContext(r.Context())
+		if _, ok := (*allowlist)[string(tenant)]; !ok {
+			w.Header().Set("content-type", mediaTypeJSON)
+			w.WriteHeader(http.StatusForbidden)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "tenant is not allowed"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
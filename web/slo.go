@@ -0,0 +1,184 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dimfeld/httptreemux/v5"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+)
+
+const (
+	defaultSLOWindow = time.Minute * 5
+	sloWindowSlots   = 10
+)
+
+// SLOTarget is the service level objective of a route.
+type SLOTarget struct {
+	// Latency is the threshold the responses must be returned within to be good; the latency is not considered if it is zero.
+	Latency time.Duration
+	// Objective is the ratio of the good responses to be achieved, such as 0.999; the rest is the error budget.
+	// The burn rate is not reported unless the objective is less than 1.
+	Objective float64
+}
+
+// WithSLO tracks the responses of the route against the target; the route is the method and the pattern such as "GET /users/:name".
+//
+// Each response is classified as bad if it is a server error or slower than the latency threshold, and counted by slo.requests with slo.outcome.
+// slo.burn_rate reports how fast the error budget is consumed within the window given by WithSLOWindow: 1 means the budget is consumed exactly at the sustainable rate.
+// The metrics carry the tenant added by the apartment middleware, which is bucketed if WithTenantGuard is given.
+func WithSLO(route string, target SLOTarget) NewServerOption {
+	return func(s *Server) {
+		if s.sloTargets == nil {
+			s.sloTargets = map[string]SLOTarget{}
+		}
+		s.sloTargets[route] = target
+	}
+}
+
+// WithSLOWindow configures the window slo.burn_rate is calculated over, 5 minutes by default.
+func WithSLOWindow(window time.Duration) NewServerOption {
+	return func(s *Server) { s.sloWindow = window }
+}
+
+func newSLOTracker(meter metric.Meter, targets map[string]SLOTarget, window time.Duration) *sloTracker {
+	t := &sloTracker{targets: targets, windows: make(map[string]*sloWindow, len(targets))}
+	for route := range targets {
+		t.windows[route] = newSLOWindow(window)
+	}
+	var err error
+	t.requests, err = meter.Int64Counter("slo.requests",
+		metric.WithDescription("The number of the responses of the routes with the SLO, classified by slo.outcome."),
+		metric.WithUnit("{request}"))
+	if err != nil {
+		otel.Handle(err)
+		return nil
+	}
+	burnRate, err := meter.Float64ObservableGauge("slo.burn_rate",
+		metric.WithDescription("The ratio of the bad responses within the window to the error budget of the route."),
+		metric.WithUnit("1"))
+	if err != nil {
+		otel.Handle(err)
+		return nil
+	}
+	if _, err := meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		now := time.Now()
+		for route, w := range t.windows {
+			rate, ok := w.burnRate(now, t.targets[route].Objective)
+			if !ok {
+				continue
+			}
+			o.ObserveFloat64(burnRate, rate, metric.WithAttributes(sloRouteAttributes(route)...))
+		}
+		return nil
+	}, burnRate); err != nil {
+		otel.Handle(err)
+		return nil
+	}
+	return t
+}
+
+type sloTracker struct {
+	targets  map[string]SLOTarget
+	windows  map[string]*sloWindow
+	requests metric.Int64Counter
+}
+
+func (t *sloTracker) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := sloRoute(r.Method, httptreemux.ContextRoute(r.Context()))
+		target, ok := t.targets[route]
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		startedAt := time.Now()
+		sw := &statusRecordingResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			elapsed := time.Since(startedAt)
+			good := sw.status < http.StatusInternalServerError && (target.Latency <= 0 || elapsed <= target.Latency)
+			t.windows[route].observe(startedAt, good)
+			outcome := "good"
+			if !good {
+				outcome = "bad"
+			}
+			attrs := append(sloRouteAttributes(route), attribute.String("slo.outcome", outcome))
+			if labeler, ok := otelhttp.LabelerFromContext(r.Context()); ok {
+				attrs = append(attrs, labeler.Get()...)
+			}
+			t.requests.Add(r.Context(), 1, metric.WithAttributes(attrs...))
+		}()
+		next.ServeHTTP(sw, r)
+	})
+}
+
+func sloRoute(method, pattern string) string {
+	return method + " " + pattern
+}
+
+func sloRouteAttributes(route string) []attribute.KeyValue {
+	method, pattern, _ := strings.Cut(route, " ")
+	return []attribute.KeyValue{semconv.HTTPMethod(method), semconv.HTTPRoute(pattern)}
+}
+
+func newSLOWindow(window time.Duration) *sloWindow {
+	return &sloWindow{slotWidth: window / sloWindowSlots, slots: make([]sloSlot, sloWindowSlots)}
+}
+
+// sloWindow counts the responses in the slots of the fixed width, which are reused once they fall out of the window.
+type sloWindow struct {
+	slotWidth time.Duration
+
+	mu    sync.Mutex
+	slots []sloSlot
+}
+
+type sloSlot struct {
+	start     time.Time
+	good, bad int64
+}
+
+func (w *sloWindow) observe(at time.Time, good bool) {
+	start := at.Truncate(w.slotWidth)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	slot := &w.slots[int(start.UnixNano()/int64(w.slotWidth))%len(w.slots)]
+	if !slot.start.Equal(start) {
+		*slot = sloSlot{start: start}
+	}
+	if good {
+		slot.good++
+	} else {
+		slot.bad++
+	}
+}
+
+// burnRate returns the ratio of the bad responses within the window to the error budget; the second return value is false if there is no response.
+func (w *sloWindow) burnRate(now time.Time, objective float64) (float64, bool) {
+	since := now.Add(-w.slotWidth * time.Duration(len(w.slots)))
+	var good, bad int64
+	w.mu.Lock()
+	for _, slot := range w.slots {
+		if slot.start.After(since) {
+			good += slot.good
+			bad += slot.bad
+		}
+	}
+	w.mu.Unlock()
+	total := good + bad
+	if total == 0 {
+		return 0, false
+	}
+	budget := 1 - objective
+	if budget <= 0 {
+		return 0, false
+	}
+	return float64(bad) / float64(total) / budget, true
+}
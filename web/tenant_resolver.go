@@ -0,0 +1,201 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"enjoymultitenancy/repos"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aereal/nagaya"
+)
+
+var defaultTenantResolverTTL = time.Minute
+
+const (
+	// maxCachedTenants bounds the registry lookups cached by the resolver, since the tenants given in the header are arbitrary.
+	maxCachedTenants = 10000
+	// maxCachedDomains bounds the domain mappings cached by the resolver.
+	maxCachedDomains = 10000
+)
+
+type NewTenantResolverOption func(tr *TenantResolver)
+
+// WithTenantResolverTTL configures the duration the resolved domain mappings and the states of the tenants are cached.
+func WithTenantResolverTTL(ttl time.Duration) NewTenantResolverOption {
+	return func(tr *TenantResolver) { tr.ttl = ttl }
+}
+
+// NewTenantResolver returns a TenantResolver that reads the tenant from the header or the custom domain mappings.
+func NewTenantResolver(headerName string, tenantRepo TenantRepository, optFns ...NewTenantResolverOption) *TenantResolver {
+	tr := &TenantResolver{headerName: headerName, tenantRepo: tenantRepo, cache: map[string]*resolvedDomain{}, tenants: map[nagaya.Tenant]*registeredTenant{}}
+	for _, f := range optFns {
+		f(tr)
+	}
+	if tr.ttl == 0 {
+		tr.ttl = defaultTenantResolverTTL
+	}
+	return tr
+}
+
+// TenantResolver determines the tenant of the request.
+//
+// The tenant given in the header takes precedence; otherwise the Host of the request is looked up in the custom domain mappings.
+// The middleware returned by RequireActiveTenant rejects the tenants absent from the registry or suspended.
+type TenantResolver struct {
+	headerName string
+	tenantRepo TenantRepository
+	ttl        time.Duration
+
+	mux     sync.RWMutex
+	cache   map[string]*resolvedDomain
+	tenants map[nagaya.Tenant]*registeredTenant
+}
+
+type registeredTenant struct {
+	found     bool
+	suspended bool
+	expiresAt time.Time
+}
+
+type resolvedDomain struct {
+	tenant    nagaya.Tenant
+	expiresAt time.Time
+}
+
+// GetTenant returns the tenant of the request. It is intended to be passed to nagaya.WithGetTenantFn.
+//
+// Only the domains mapped to the tenants are cached, since the Host is given by the client and caching the unknown ones lets anyone grow the cache.
+func (tr *TenantResolver) GetTenant(r *http.Request) (nagaya.Tenant, bool) {
+	if v := r.Header.Get(tr.headerName); v != "" {
+		return nagaya.Tenant(v), true
+	}
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	domain, err := repos.NormalizeDomain(host)
+	if err != nil {
+		return "", false
+	}
+	now := time.Now()
+	tr.mux.RLock()
+	cached, ok := tr.cache[domain]
+	tr.mux.RUnlock()
+	if ok && now.Before(cached.expiresAt) {
+		return cached.tenant, true
+	}
+	tenant, err := tr.tenantRepo.FindTenantByDomain(r.Context(), domain)
+	switch {
+	case errors.Is(err, repos.ErrNotFound):
+		return "", false
+	case err != nil:
+		slog.WarnContext(r.Context(), "failed to resolve tenant by domain", slog.String("domain", domain), slog.String("error", err.Error()))
+		return "", false
+	}
+	resolved := &resolvedDomain{tenant: nagaya.Tenant(tenant.ID), expiresAt: now.Add(tr.ttl)}
+	tr.mux.Lock()
+	defer tr.mux.Unlock()
+	if len(tr.cache) >= maxCachedDomains {
+		for cachedDomain, cached := range tr.cache {
+			if !now.Before(cached.expiresAt) {
+				delete(tr.cache, cachedDomain)
+			}
+		}
+	}
+	if len(tr.cache) < maxCachedDomains {
+		tr.cache[domain] = resolved
+	}
+	return resolved.tenant, true
+}
+
+// Forget drops the cached mapping of the domain so that the change of the mapping takes effect immediately.
+func (tr *TenantResolver) Forget(domain string) {
+	tr.mux.Lock()
+	defer tr.mux.Unlock()
+	delete(tr.cache, domain)
+}
+
+// ForgetTenant drops the cached state of the tenant and the mappings resolved to it, such as after the tenant is suspended.
+//
+// The other processes keep the cached state until it expires, so the suspension takes effect on them within the TTL.
+func (tr *TenantResolver) ForgetTenant(tenant nagaya.Tenant) {
+	tr.mux.Lock()
+	defer tr.mux.Unlock()
+	delete(tr.tenants, tenant)
+	for domain, resolved := range tr.cache {
+		if resolved.tenant == tenant {
+			delete(tr.cache, domain)
+		}
+	}
+}
+
+// RequireActiveTenant rejects the requests to the tenants absent from the registry with 404 and to the suspended tenants with 403.
+//
+// It is expected to be mounted before the apartment middleware so that the database of the rejected tenant is never bound;
+// the requests without the tenant are passed through to be handled by the apartment middleware.
+func (tr *TenantResolver) RequireActiveTenant(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant, ok := tr.GetTenant(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		registered, err := tr.lookupTenant(r.Context(), tenant)
+		switch {
+		case err != nil:
+			slog.WarnContext(r.Context(), "failed to look up tenant", slog.String("tenant", string(tenant)), slog.String("error", err.Error()))
+			shed(w, "failed to look up tenant")
+		case !registered.found:
+			w.Header().Set("content-type", mediaTypeJSON)
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "tenant not found"})
+		case registered.suspended:
+			w.Header().Set("content-type", mediaTypeJSON)
+			w.WriteHeader(http.StatusForbidden)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "tenant is suspended"})
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+func (tr *TenantResolver) lookupTenant(ctx context.Context, tenant nagaya.Tenant) (*registeredTenant, error) {
+	if repos.ValidateTenantID(string(tenant)) != nil {
+		return &registeredTenant{}, nil
+	}
+	now := time.Now()
+	tr.mux.RLock()
+	cached, ok := tr.tenants[tenant]
+	tr.mux.RUnlock()
+	if ok && now.Before(cached.expiresAt) {
+		return cached, nil
+	}
+	registered := &registeredTenant{expiresAt: now.Add(tr.ttl)}
+	t, err := tr.tenantRepo.FindTenant(ctx, string(tenant))
+	switch {
+	case errors.Is(err, repos.ErrNotFound):
+	case err != nil:
+		return nil, err
+	default:
+		registered.found = true
+		registered.suspended = t.SuspendedAt != nil
+	}
+	tr.mux.Lock()
+	defer tr.mux.Unlock()
+	if len(tr.tenants) >= maxCachedTenants {
+		for cachedTenant, cached := range tr.tenants {
+			if !now.Before(cached.expiresAt) {
+				delete(tr.tenants, cachedTenant)
+			}
+		}
+	}
+	if len(tr.tenants) < maxCachedTenants {
+		tr.tenants[tenant] = registered
+	}
+	return registered, nil
+}
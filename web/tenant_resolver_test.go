@@ -0,0 +1,157 @@
+package web
+
+import (
+	"context"
+	"enjoymultitenancy/repos"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aereal/nagaya"
+)
+
+type stubTenantRepo struct {
+	TenantRepository
+	tenants       map[string]*repos.Tenant
+	domains       map[string]string
+	err           error
+	findCalls     int
+	byDomainCalls int
+}
+
+func (r *stubTenantRepo) FindTenant(_ context.Context, id string) (*repos.Tenant, error) {
+	r.findCalls++
+	if r.err != nil {
+		return nil, r.err
+	}
+	if t, ok := r.tenants[id]; ok {
+		return t, nil
+	}
+	return nil, repos.ErrNotFound
+}
+
+func (r *stubTenantRepo) FindTenantByDomain(_ context.Context, domain string) (*repos.Tenant, error) {
+	r.byDomainCalls++
+	if r.err != nil {
+		return nil, r.err
+	}
+	if id, ok := r.domains[domain]; ok {
+		return r.FindTenant(context.Background(), id)
+	}
+	return nil, repos.ErrNotFound
+}
+
+func newStubTenantRepo() *stubTenantRepo {
+	suspendedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	return &stubTenantRepo{
+		tenants: map[string]*repos.Tenant{
+			"tenant_1": {ID: "tenant_1"},
+			"tenant_2": {ID: "tenant_2", SuspendedAt: &suspendedAt},
+		},
+		domains: map[string]string{"blog.example.com": "tenant_1"},
+	}
+}
+
+func TestTenantResolver_GetTenant(t *testing.T) {
+	testCases := []struct {
+		name              string
+		header            string
+		host              string
+		repoErr           error
+		wantTenant        nagaya.Tenant
+		wantOK            bool
+		wantByDomainCalls int
+	}{
+		{name: "header", header: "tenant_2", host: "blog.example.com", wantTenant: "tenant_2", wantOK: true},
+		{name: "domain", host: "blog.example.com", wantTenant: "tenant_1", wantOK: true, wantByDomainCalls: 1},
+		{name: "domain with port and upper case", host: "Blog.Example.COM:8080", wantTenant: "tenant_1", wantOK: true, wantByDomainCalls: 1},
+		{name: "unknown domain", host: "unknown.example.com", wantByDomainCalls: 2},
+		{name: "invalid domain", host: "localhost"},
+		{name: "repo error", host: "blog.example.com", repoErr: errors.New("oops"), wantByDomainCalls: 2},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := newStubTenantRepo()
+			repo.err = tc.repoErr
+			tr := NewTenantResolver("x-tenant-id", repo)
+			// the second call is served by the cache if the domain is resolved
+			for i := 0; i < 2; i++ {
+				r := httptest.NewRequest(http.MethodGet, "/", nil)
+				r.Host = tc.host
+				if tc.header != "" {
+					r.Header.Set("x-tenant-id", tc.header)
+				}
+				tenant, ok := tr.GetTenant(r)
+				if tenant != tc.wantTenant || ok != tc.wantOK {
+					t.Errorf("#%d: GetTenant() = (%q, %v), want (%q, %v)", i, tenant, ok, tc.wantTenant, tc.wantOK)
+				}
+			}
+			if repo.byDomainCalls != tc.wantByDomainCalls {
+				t.Errorf("FindTenantByDomain called %d times, want %d", repo.byDomainCalls, tc.wantByDomainCalls)
+			}
+			if len(tr.cache) > 1 || (len(tr.cache) == 1) != (tc.wantOK && tc.header == "") {
+				t.Errorf("cached domains = %v", tr.cache)
+			}
+		})
+	}
+}
+
+func TestTenantResolver_RequireActiveTenant(t *testing.T) {
+	testCases := []struct {
+		name       string
+		tenant     string
+		repoErr    error
+		wantStatus int
+	}{
+		{name: "active", tenant: "tenant_1", wantStatus: http.StatusOK},
+		{name: "suspended", tenant: "tenant_2", wantStatus: http.StatusForbidden},
+		{name: "unregistered", tenant: "tenant_3", wantStatus: http.StatusNotFound},
+		{name: "invalid", tenant: "../etc", wantStatus: http.StatusNotFound},
+		{name: "no tenant", wantStatus: http.StatusOK},
+		{name: "repo error", tenant: "tenant_1", repoErr: errors.New("oops"), wantStatus: http.StatusServiceUnavailable},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := newStubTenantRepo()
+			repo.err = tc.repoErr
+			tr := NewTenantResolver("x-tenant-id", repo)
+			h := tr.RequireActiveTenant(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) }))
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.tenant != "" {
+				r.Header.Set("x-tenant-id", tc.tenant)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, r)
+			if rec.Code != tc.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tc.wantStatus)
+			}
+		})
+	}
+}
+
+func TestTenantResolver_ForgetTenant(t *testing.T) {
+	repo := newStubTenantRepo()
+	tr := NewTenantResolver("x-tenant-id", repo)
+	h := tr.RequireActiveTenant(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) }))
+	do := func() int {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("x-tenant-id", "tenant_1")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec.Code
+	}
+	if got := do(); got != http.StatusOK {
+		t.Fatalf("status = %d, want %d", got, http.StatusOK)
+	}
+	suspendedAt := time.Now()
+	repo.tenants["tenant_1"].SuspendedAt = &suspendedAt
+	if got := do(); got != http.StatusOK {
+		t.Errorf("status before ForgetTenant = %d, want the cached %d", got, http.StatusOK)
+	}
+	tr.ForgetTenant("tenant_1")
+	if got := do(); got != http.StatusForbidden {
+		t.Errorf("status after ForgetTenant = %d, want %d", got, http.StatusForbidden)
+	}
+}
@@ -0,0 +1,124 @@
+package web
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var defaultTLSReloadInterval = time.Minute
+
+// WithTLS makes the server serve HTTPS using the certificate and the private key stored in given paths.
+//
+// The certificate is reloaded when the server receives SIGHUP or the files are modified, so the rotated certificate is served without restarting.
+func WithTLS(certFile, keyFile string) NewServerOption {
+	return func(s *Server) {
+		s.certFile = certFile
+		s.keyFile = keyFile
+	}
+}
+
+// WithTLSReloadInterval configures the interval the server checks modifications of the certificate files.
+func WithTLSReloadInterval(interval time.Duration) NewServerOption {
+	return func(s *Server) { s.tlsReloadInterval = interval }
+}
+
+func (s *Server) tlsEnabled() bool {
+	return s.certFile != "" && s.keyFile != ""
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	cr := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := cr.reload(); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mux     sync.RWMutex
+	cert    *tls.Certificate
+	certMod time.Time
+	keyMod  time.Time
+}
+
+func (cr *certReloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mux.RLock()
+	defer cr.mux.RUnlock()
+	return cr.cert, nil
+}
+
+func (cr *certReloader) reload() error {
+	certMod, keyMod, err := cr.modTimes()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return fmt.Errorf("tls.LoadX509KeyPair: %w", err)
+	}
+	cr.mux.Lock()
+	defer cr.mux.Unlock()
+	cr.cert = &cert
+	cr.certMod = certMod
+	cr.keyMod = keyMod
+	return nil
+}
+
+func (cr *certReloader) modTimes() (certMod time.Time, keyMod time.Time, err error) {
+	certStat, err := os.Stat(cr.certFile)
+	if err != nil {
+		return certMod, keyMod, fmt.Errorf("os.Stat: %w", err)
+	}
+	keyStat, err := os.Stat(cr.keyFile)
+	if err != nil {
+		return certMod, keyMod, fmt.Errorf("os.Stat: %w", err)
+	}
+	return certStat.ModTime(), keyStat.ModTime(), nil
+}
+
+func (cr *certReloader) modified() bool {
+	certMod, keyMod, err := cr.modTimes()
+	if err != nil {
+		return false
+	}
+	cr.mux.RLock()
+	defer cr.mux.RUnlock()
+	return !certMod.Equal(cr.certMod) || !keyMod.Equal(cr.keyMod)
+}
+
+// watch reloads the certificate on SIGHUP or the modification of the files until the context is canceled.
+//
+// The failure of reloading is only logged and the previous certificate is kept to be served.
+func (cr *certReloader) watch(ctx context.Context, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		case <-ticker.C:
+			if !cr.modified() {
+				continue
+			}
+		}
+		if err := cr.reload(); err != nil {
+			slog.WarnContext(ctx, "failed to reload TLS certificate", slog.String("error", err.Error()))
+			continue
+		}
+		slog.InfoContext(ctx, "TLS certificate reloaded", slog.String("cert_file", cr.certFile))
+	}
+}
@@ -0,0 +1,67 @@
+package web
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// upgradeParentEnv passes the PID of the old process to the new process started by the graceful upgrade.
+const upgradeParentEnv = "SERVER_UPGRADE_PARENT_PID"
+
+// WithGracefulUpgrade makes the server bind its ports with SO_REUSEPORT and replace itself with a new process of its executable on SIGUSR2.
+//
+// The new process is started with the same arguments and env vars and binds the same ports alongside the old one.
+// Once it has bound them, it sends SIGTERM to the old process, which drains through the usual shutdown path:
+// it reports not ready, waits for the pre-stop delay and finishes the in-flight requests within the shutdown grace.
+// The deployment tools may also start the new binary by themselves and send SIGTERM to the old process once the new one is ready.
+//
+// The option is not supported on the platforms without SO_REUSEPORT, where Start fails.
+func WithGracefulUpgrade() NewServerOption {
+	return func(s *Server) { s.gracefulUpgrade = true }
+}
+
+func (s *Server) listen(ctx context.Context, addr string) (net.Listener, error) {
+	if !s.gracefulUpgrade {
+		return net.Listen("tcp", addr)
+	}
+	lc := &net.ListenConfig{Control: reusePortControl}
+	return lc.Listen(ctx, "tcp", addr)
+}
+
+// startUpgrade starts the new process that takes over the ports.
+func startUpgrade() (*os.Process, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Env = append(os.Environ(), upgradeParentEnv+"="+strconv.Itoa(os.Getpid()))
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return cmd.Process, nil
+}
+
+// notifyUpgradeParent tells the old process to drain if this process is started by its graceful upgrade.
+func notifyUpgradeParent(ctx context.Context) {
+	pid, err := strconv.Atoi(os.Getenv(upgradeParentEnv))
+	if err != nil {
+		return
+	}
+	// the env var is inherited by the next upgrade, which sets its own
+	_ = os.Unsetenv(upgradeParentEnv)
+	if pid != os.Getppid() {
+		return
+	}
+	slog.InfoContext(ctx, "taking over from the old process", slog.Int("pid", pid))
+	if err := terminate(pid); err != nil {
+		slog.WarnContext(ctx, "failed to terminate the old process", slog.Int("pid", pid), slog.String("error", err.Error()))
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package web
+
+import (
+	"context"
+	"errors"
+	"syscall"
+)
+
+var errGracefulUpgradeUnsupported = errors.New("graceful upgrade is not supported on this platform")
+
+func reusePortControl(_, _ string, _ syscall.RawConn) error {
+	return errGracefulUpgradeUnsupported
+}
+
+func watchUpgradeSignal(context.Context) {}
+
+func terminate(int) error {
+	return errGracefulUpgradeUnsupported
+}
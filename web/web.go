@@ -2,24 +2,35 @@ package web
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"enjoymultitenancy/adapters"
+	"enjoymultitenancy/auth"
 	"enjoymultitenancy/repos"
+	"enjoymultitenancy/telemetry"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"mime"
 	"net"
 	"net/http"
 	"net/http/httptrace"
+	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/dimfeld/httptreemux/v5"
+	"github.com/jmoiron/sqlx"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/httptrace/otelhttptrace"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
 	"go.opentelemetry.io/otel/trace"
 )
@@ -41,6 +52,25 @@ func NewServer(optFns ...NewServerOption) *Server {
 	if s.shutdownGrace == 0 {
 		s.shutdownGrace = defaultShutdownGrace
 	}
+	if s.tlsReloadInterval == 0 {
+		s.tlsReloadInterval = defaultTLSReloadInterval
+	}
+	if s.maxRequestTimeout == 0 {
+		s.maxRequestTimeout = defaultMaxRequestTimeout
+	}
+	if s.meterProvider == nil {
+		s.meterProvider = otel.GetMeterProvider()
+	}
+	s.metrics = newServerMetrics(s.meterProvider.Meter("web"))
+	if len(s.sloTargets) > 0 {
+		if s.sloWindow <= 0 {
+			s.sloWindow = defaultSLOWindow
+		}
+		s.slo = newSLOTracker(s.meterProvider.Meter("web"), s.sloTargets, s.sloWindow)
+	}
+	if s.auditRepo != nil && s.userRepo != nil {
+		s.userRepo = &auditedUserRepo{UserRepository: s.userRepo, audit: s.auditRepo, transactor: s.transactor}
+	}
 	return s
 }
 
@@ -54,7 +84,21 @@ func WithShutdownGrace(grace time.Duration) NewServerOption {
 	return func(s *Server) { s.shutdownGrace = grace }
 }
 
-func WithUserRepo(ur *repos.UserRepo) NewServerOption {
+// WithAdminPort makes the server serve the health checks and the admin routes on the separate port instead of the public one.
+//
+// The admin listener always speaks plaintext HTTP/1.1 since it is intended to be reachable only from the internal network.
+func WithAdminPort(port string) NewServerOption {
+	return func(s *Server) { s.adminPort = port }
+}
+
+// WithPreStopDelay configures the duration the server keeps serving after /readyz starts failing on shutdown.
+//
+// It gives the load balancers time to deregister the server before the listener is closed.
+func WithPreStopDelay(delay time.Duration) NewServerOption {
+	return func(s *Server) { s.preStopDelay = delay }
+}
+
+func WithUserRepo(ur UserRepository) NewServerOption {
 	return func(s *Server) { s.userRepo = ur }
 }
 
@@ -62,17 +106,89 @@ func WithApartmentMiddleware(mw func(http.Handler) http.Handler) NewServerOption
 	return func(s *Server) { s.apartmentMiddleware = mw }
 }
 
+// WithAuthMiddleware configures the middleware that authenticates requests before the tenant is determined.
+func WithAuthMiddleware(mw func(http.Handler) http.Handler) NewServerOption {
+	return func(s *Server) { s.authMiddleware = mw }
+}
+
+// WithResponseCache enables the response cache on the routes reading cacheable resources.
+func WithResponseCache(c *ResponseCache) NewServerOption {
+	return func(s *Server) { s.responseCache = c }
+}
+
+// WithStrictJSONDecoding makes the server reject request bodies that contain unknown fields or trailing data after the JSON value.
+func WithStrictJSONDecoding() NewServerOption {
+	return func(s *Server) { s.strictJSONDecoding = true }
+}
+
 type Server struct {
 	shutdownGrace       time.Duration
+	preStopDelay        time.Duration
+	draining            atomic.Bool
+	maintenance         atomic.Pointer[maintenanceMode]
 	port                string
-	userRepo            *repos.UserRepo
+	adminPort           string
+	userRepo            UserRepository
 	apartmentMiddleware func(http.Handler) http.Handler
+	authMiddleware      func(http.Handler) http.Handler
+	membershipRepo      MembershipRepository
+	adminMiddleware     func(http.Handler) http.Handler
+	tenantRepo          TenantRepository
+	db                  *sqlx.DB
+	strictJSONDecoding  bool
+	certFile            string
+	keyFile             string
+	tlsReloadInterval   time.Duration
+	h2c                 bool
+	gracefulUpgrade     bool
+	metricsCollector    MetricsCollector
+	schemaMigrator      SchemaMigrator
+	responseCache       *ResponseCache
+	middlewares         map[MiddlewarePosition][]func(http.Handler) http.Handler
+	transactor          Transactor
+	readinessChecks     map[string]ReadinessCheck
+	circuitBreakers     []*adapters.CircuitBreaker
+	maxRequestTimeout   time.Duration
+	concurrencyLimiter  atomic.Pointer[concurrencyLimiter]
+	tenantAllowlist     atomic.Pointer[map[string]struct{}]
+	runtimeConfig       atomic.Pointer[RuntimeConfig]
+	tenantResolver      *TenantResolver
+	webhooks            map[string]*webhookSource
+	mirror              *mirror
+	auditRepo           AuditRepository
+	queryBudget         int
+	meterProvider       metric.MeterProvider
+	logLevel            *slog.LevelVar
+	pprof               bool
+	tenantGuard         *telemetry.TenantGuard
+	sloTargets          map[string]SLOTarget
+	sloWindow           time.Duration
+	slo                 *sloTracker
+	metrics             *serverMetrics
 }
 
 type errorResponse struct {
 	Error string `json:"error"`
 }
 
+var errTrailingData = errors.New("unexpected data after JSON value")
+
+func (s *Server) decodeJSON(r io.Reader, v any) error {
+	dec := json.NewDecoder(r)
+	if s.strictJSONDecoding {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if s.strictJSONDecoding {
+		if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+			return errTrailingData
+		}
+	}
+	return nil
+}
+
 func (s *Server) handlePostUsers() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
@@ -85,25 +201,46 @@ func (s *Server) handlePostUsers() http.Handler {
 		}
 		defer r.Body.Close()
 		userToRegister := new(repos.UserToRegister)
-		if err := json.NewDecoder(r.Body).Decode(userToRegister); err != nil {
+		if err := s.decodeJSON(r.Body, userToRegister); err != nil {
 			w.WriteHeader(http.StatusBadRequest)
 			_ = json.NewEncoder(w).Encode(errorResponse{Error: fmt.Sprintf("failed to decode request body: %s", err)})
 			return
 		}
-		if err := s.userRepo.RegisterUser(ctx, userToRegister); err != nil {
+		user, err := s.userRepo.RegisterUser(ctx, userToRegister)
+		var validationErr *repos.ValidationError
+		if errors.As(err, &validationErr) {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: validationErr.Error()})
+			return
+		}
+		if errors.Is(err, repos.ErrUserAlreadyExists) {
+			w.WriteHeader(http.StatusConflict)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: err.Error()})
+			return
+		}
+		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			_ = json.NewEncoder(w).Encode(errorResponse{Error: fmt.Sprintf("failed to register user: %s", err)})
 			return
 		}
+		w.Header().Set("location", "/users/"+url.PathEscape(user.Name))
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(user)
 	})
 }
 
 func (s *Server) handleGetUser() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		params := httptreemux.ContextParams(r.Context())
-		user, err := s.userRepo.FetchUserByName(r.Context(), params["name"])
+		fields := parseFields(r)
+		user, err := s.userRepo.FetchUserByName(r.Context(), params["name"], repos.WithUserFields(fields...))
 		w.Header().Set("content-type", "application/json")
+		var unknownFieldErr *repos.UnknownFieldError
 		switch {
+		case errors.As(err, &unknownFieldErr):
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: unknownFieldErr.Error()})
+			return
 		case errors.Is(err, repos.ErrUserNameRequired):
 			w.WriteHeader(http.StatusBadRequest)
 			fmt.Fprintln(w, `{"error":"user name required"}`)
@@ -117,23 +254,306 @@ func (s *Server) handleGetUser() http.Handler {
 			fmt.Fprintln(w, `{"error":"failed to fetch the user"}`)
 			return
 		}
+		if len(fields) > 0 {
+			_ = json.NewEncoder(w).Encode(sparseUser(user, fields))
+			return
+		}
 		_ = json.NewEncoder(w).Encode(user)
 	})
 }
 
-func (s *Server) handler() http.Handler {
+var defaultMaxImportUsers = 10000
+
+type importUsersRequest struct {
+	Users []*repos.UserToRegister `json:"users"`
+}
+
+type importUsersResponse struct {
+	Imported int           `json:"imported"`
+	Users    []*repos.User `json:"users"`
+}
+
+type importRowError struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+type importUsersErrorResponse struct {
+	Error string            `json:"error"`
+	Rows  []*importRowError `json:"rows"`
+}
+
+func (s *Server) handleImportUsers() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		w.Header().Set("content-type", mediaTypeJSON)
+		if mt, _, _ := mime.ParseMediaType(r.Header.Get("content-type")); mt != mediaTypeJSON {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: fmt.Sprintf("invalid request content type: %s", mt)})
+			return
+		}
+		defer r.Body.Close()
+		req := new(importUsersRequest)
+		if err := s.decodeJSON(r.Body, req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: fmt.Sprintf("failed to decode request body: %s", err)})
+			return
+		}
+		if len(req.Users) == 0 || len(req.Users) > defaultMaxImportUsers {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: fmt.Sprintf("the number of users must be between 1 and %d", defaultMaxImportUsers)})
+			return
+		}
+		registered, err := s.userRepo.RegisterUsers(ctx, req.Users)
+		var importErr *repos.UserImportError
+		switch {
+		case errors.As(err, &importErr):
+			resp := importUsersErrorResponse{Error: importErr.Error(), Rows: make([]*importRowError, len(importErr.Rows))}
+			for i, row := range importErr.Rows {
+				resp.Rows[i] = &importRowError{Index: row.Index, Error: row.Err.Error()}
+			}
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			_ = json.NewEncoder(w).Encode(resp)
+			return
+		case errors.Is(err, repos.ErrUserAlreadyExists):
+			w.WriteHeader(http.StatusConflict)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: err.Error()})
+			return
+		case err != nil:
+			slog.ErrorContext(ctx, "failed to import users", slog.String("error", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "failed to import the users"})
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(importUsersResponse{Imported: len(registered), Users: registered})
+	})
+}
+
+type listUsersResponse struct {
+	Users      []*repos.User `json:"users"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+func (s *Server) handleListUsers() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		w.Header().Set("content-type", mediaTypeJSON)
+		query := r.URL.Query()
+		opts := []repos.ListUsersOption{
+			repos.WithUsersAfter(query.Get("after")),
+			repos.WithNamePrefix(query.Get("name_prefix")),
+		}
+		if v := query.Get("limit"); v != "" {
+			limit, err := strconv.Atoi(v)
+			if err != nil || limit <= 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(errorResponse{Error: fmt.Sprintf("invalid limit: %q", v)})
+				return
+			}
+			opts = append(opts, repos.WithUsersLimit(limit))
+		}
+		page, err := s.userRepo.ListUsers(ctx, opts...)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to list users", slog.String("error", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "failed to list the users"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(listUsersResponse{Users: page.Users, NextCursor: page.NextCursor})
+	})
+}
+
+type userPatch struct {
+	Name *string `json:"name"`
+}
+
+func (s *Server) handlePatchUser() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		w.Header().Set("content-type", mediaTypeJSON)
+		if mt, _, _ := mime.ParseMediaType(r.Header.Get("content-type")); mt != mediaTypeJSON {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: fmt.Sprintf("invalid request content type: %s", mt)})
+			return
+		}
+		defer r.Body.Close()
+		patch := new(userPatch)
+		if err := s.decodeJSON(r.Body, patch); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: fmt.Sprintf("failed to decode request body: %s", err)})
+			return
+		}
+		var opts []repos.UpdateUserOption
+		if patch.Name != nil {
+			opts = append(opts, repos.WithNewUserName(*patch.Name))
+		}
+		user, err := s.userRepo.UpdateUser(ctx, httptreemux.ContextParams(ctx)["name"], opts...)
+		var validationErr *repos.ValidationError
+		switch {
+		case errors.As(err, &validationErr), errors.Is(err, repos.ErrNothingToUpdate):
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: err.Error()})
+			return
+		case errors.Is(err, repos.ErrNotFound):
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintln(w, `{"error":"not found"}`)
+			return
+		case errors.Is(err, repos.ErrUserAlreadyExists):
+			w.WriteHeader(http.StatusConflict)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: err.Error()})
+			return
+		case err != nil:
+			slog.ErrorContext(ctx, "failed to update user", slog.String("error", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "failed to update the user"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(user)
+	})
+}
+
+func (s *Server) handleDeleteUser() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		err := s.userRepo.DeleteUser(ctx, httptreemux.ContextParams(ctx)["name"])
+		switch {
+		case errors.Is(err, repos.ErrUserNameRequired):
+			w.Header().Set("content-type", mediaTypeJSON)
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintln(w, `{"error":"user name required"}`)
+			return
+		case errors.Is(err, repos.ErrNotFound):
+			w.Header().Set("content-type", mediaTypeJSON)
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintln(w, `{"error":"not found"}`)
+			return
+		case err != nil:
+			slog.ErrorContext(ctx, "failed to delete user", slog.String("error", err.Error()))
+			w.Header().Set("content-type", mediaTypeJSON)
+			w.WriteHeader(http.StatusInternalServerError)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "failed to delete the user"})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// Handler returns the handler serving the public routes of the server without starting the listener.
+//
+// The health checks and the admin routes are included unless the admin port is configured.
+func (s *Server) Handler() http.Handler {
+	return s.handler()
+}
+
+func (s *Server) newMux() *httptreemux.ContextMux {
+	// HEAD is served by the GET handlers, which is the default of httptreemux
 	m := httptreemux.NewContextMux()
-	m.UseHandler(withOtel)
+	m.MethodNotAllowedHandler = handleMethodNotAllowed
+	m.UseHandler(s.withOtel)
+	m.UseHandler(withRequestID)
+	if s.slo != nil {
+		m.UseHandler(s.slo.middleware)
+	}
+	m.UseHandler(withRecovery)
 	m.UseHandler(injectRouteAttrs)
-	m.UseHandler(s.apartmentMiddleware)
-	m.Handler(http.MethodPost, "/users", s.handlePostUsers())
-	m.Handler(http.MethodGet, "/users/:name", s.handleGetUser())
+	m.UseHandler(s.withRequestDeadline)
+	if s.queryBudget > 0 {
+		m.UseHandler(s.withQueryBudget)
+	}
+	s.useMiddlewaresAt(m, PositionGlobal)
+	return m
+}
+
+// mountOperationalRoutes mounts the health checks, the version of the binary and the admin routes.
+func (s *Server) mountOperationalRoutes(m *httptreemux.ContextMux) {
+	m.Handler(http.MethodGet, "/healthz", s.handleGetHealthz())
+	m.Handler(http.MethodGet, "/readyz", s.handleGetReadyz())
+	m.Handler(http.MethodGet, "/version", s.handleGetVersion())
+	if s.adminMiddleware != nil {
+		s.mountAdminRoutes(m.NewContextGroup("/admin"))
+	}
+}
+
+// adminHandler returns the handler served on the admin port.
+func (s *Server) adminHandler() http.Handler {
+	m := s.newMux()
+	s.mountOperationalRoutes(m)
 	return m
 }
 
-func withOtel(next http.Handler) http.Handler {
+func (s *Server) handler() http.Handler {
+	m := s.newMux()
+	if s.adminPort == "" {
+		s.mountOperationalRoutes(m)
+	}
+	if len(s.webhooks) > 0 {
+		webhookGroup := m.NewContextGroup("/webhooks")
+		webhookGroup.UseHandler(s.withMaintenance)
+		if s.tenantResolver != nil {
+			webhookGroup.UseHandler(s.tenantResolver.RequireActiveTenant)
+		}
+		webhookGroup.UseHandler(s.instrumentApartment(s.apartmentMiddleware))
+		webhookGroup.Handler(http.MethodPost, "/:source", s.handlePostWebhook())
+	}
+	tenantGroup := m.NewContextGroup("/")
+	tenantGroup.UseHandler(s.withMaintenance)
+	if s.mirror != nil {
+		tenantGroup.UseHandler(s.mirror.middleware)
+	}
+	tenantGroup.UseHandler(s.limitConcurrency)
+	s.useMiddlewaresAt(tenantGroup, PositionBeforeAuth)
+	if s.authMiddleware != nil {
+		tenantGroup.UseHandler(s.authMiddleware)
+	}
+	s.useMiddlewaresAt(tenantGroup, PositionBeforeApartment)
+	for _, b := range s.circuitBreakers {
+		tenantGroup.UseHandler(circuitBreakerMiddleware(b))
+	}
+	if s.tenantResolver != nil {
+		tenantGroup.UseHandler(s.tenantResolver.RequireActiveTenant)
+	}
+	tenantGroup.UseHandler(s.instrumentApartment(s.apartmentMiddleware))
+	tenantGroup.UseHandler(s.withTenantAllowlist)
+	s.useMiddlewaresAt(tenantGroup, PositionAfterApartment)
+	for _, rt := range append(s.routes(), s.connectRoutes()...) {
+		h := rt.handler
+		if s.responseCache != nil && rt.cacheResource != "" {
+			h = s.responseCache.middleware(rt.cacheResource)(h)
+		}
+		tenantGroup.Handler(rt.method, rt.path, s.requireRole(rt.role)(h))
+	}
+	return m
+}
+
+// route is a tenant-facing endpoint annotated with the role required to access it.
+//
+// The responses of the route are cached if cacheResource is given and the server has the response cache.
+type route struct {
+	method        string
+	path          string
+	role          auth.Role
+	cacheResource string
+	handler       http.Handler
+}
+
+func (s *Server) routes() []route {
+	return []route{
+		{method: http.MethodPost, path: "/users", role: auth.RoleEditor, handler: s.handlePostUsers()},
+		{method: http.MethodGet, path: "/users", role: auth.RoleViewer, cacheResource: "users", handler: s.handleListUsers()},
+		{method: http.MethodPost, path: "/users:import", role: auth.RoleEditor, handler: s.handleImportUsers()},
+		{method: http.MethodGet, path: "/users/:name", role: auth.RoleViewer, cacheResource: "users", handler: s.handleGetUser()},
+		{method: http.MethodPatch, path: "/users/:name", role: auth.RoleEditor, handler: s.handlePatchUser()},
+		{method: http.MethodDelete, path: "/users/:name", role: auth.RoleOwner, handler: s.handleDeleteUser()},
+		{method: http.MethodPost, path: "/batch", role: auth.RoleEditor, handler: s.handlePostBatch()},
+		{method: http.MethodGet, path: "/audit-logs", role: auth.RoleOwner, handler: s.handleListAuditLogs()},
+	}
+}
+
+func (s *Server) withOtel(next http.Handler) http.Handler {
 	return otelhttp.NewHandler(next, "server",
 		otelhttp.WithPublicEndpoint(),
+		otelhttp.WithMeterProvider(s.meterProvider),
 		otelhttp.WithSpanNameFormatter(formatSpanName),
 		otelhttp.WithClientTrace(func(ctx context.Context) *httptrace.ClientTrace { return otelhttptrace.NewClientTrace(ctx) }))
 }
@@ -159,6 +579,9 @@ func injectRouteAttrs(next http.Handler) http.Handler {
 				span.SetAttributes(attrs...)
 			}
 		}
+		if data := httptreemux.ContextData(ctx); data != nil {
+			r = r.WithContext(adapters.WithRoute(ctx, data.Route()))
+		}
 		next.ServeHTTP(w, r)
 	})
 }
@@ -168,20 +591,80 @@ func (s *Server) Start(ctx context.Context) error {
 		Handler: s.handler(),
 		Addr:    net.JoinHostPort("localhost", s.port),
 	}
+	if err := s.configureHTTP2(hs); err != nil {
+		return err
+	}
+	servers := []*http.Server{hs}
+	var adminServer *http.Server
+	if s.adminPort != "" {
+		adminServer = &http.Server{
+			Handler: s.adminHandler(),
+			Addr:    net.JoinHostPort("localhost", s.adminPort),
+		}
+		servers = append(servers, adminServer)
+	}
 	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
 	defer cancel()
+	if s.tlsEnabled() {
+		cr, err := newCertReloader(s.certFile, s.keyFile)
+		if err != nil {
+			return err
+		}
+		hs.TLSConfig = &tls.Config{GetCertificate: cr.GetCertificate, MinVersion: tls.VersionTLS12}
+		go cr.watch(ctx, s.tlsReloadInterval)
+	}
+	listeners := make(map[*http.Server]net.Listener, len(servers))
+	for _, srv := range servers {
+		ln, err := s.listen(ctx, srv.Addr)
+		if err != nil {
+			for _, ln := range listeners {
+				_ = ln.Close()
+			}
+			return fmt.Errorf("failed to listen on %s: %w", srv.Addr, err)
+		}
+		listeners[srv] = ln
+	}
+	if s.gracefulUpgrade {
+		notifyUpgradeParent(ctx)
+		go watchUpgradeSignal(ctx)
+	}
 	go func() {
 		<-ctx.Done()
+		s.draining.Store(true)
+		if s.preStopDelay > 0 {
+			slog.InfoContext(ctx, "draining server before shutdown", slog.Duration("delay", s.preStopDelay))
+			time.Sleep(s.preStopDelay)
+		}
 		slog.InfoContext(ctx, "shutting down server", slog.Duration("grace", s.shutdownGrace))
-		ctx, cancel := context.WithTimeout(ctx, s.shutdownGrace)
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.shutdownGrace)
 		defer cancel()
-		if err := hs.Shutdown(ctx); err != nil {
-			slog.WarnContext(ctx, "cannot shut down server gracefully", slog.String("error", err.Error()))
+		for _, srv := range servers {
+			if err := srv.Shutdown(ctx); err != nil {
+				slog.WarnContext(ctx, "cannot shut down server gracefully", slog.String("addr", srv.Addr), slog.String("error", err.Error()))
+			}
 		}
 	}()
-	slog.InfoContext(ctx, "start server", slog.String("port", s.port))
-	if err := hs.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-		return err
+	errCh := make(chan error, len(servers))
+	if adminServer != nil {
+		go func() {
+			slog.InfoContext(ctx, "start admin server", slog.String("port", s.adminPort))
+			errCh <- adminServer.Serve(listeners[adminServer])
+		}()
 	}
-	return nil
+	go func() {
+		slog.InfoContext(ctx, "start server", slog.String("port", s.port), slog.Bool("tls", s.tlsEnabled()))
+		if s.tlsEnabled() {
+			errCh <- hs.ServeTLS(listeners[hs], "", "")
+		} else {
+			errCh <- hs.Serve(listeners[hs])
+		}
+	}()
+	var errs []error
+	for range servers {
+		if err := <-errCh; err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errs = append(errs, err)
+			cancel()
+		}
+	}
+	return errors.Join(errs...)
 }
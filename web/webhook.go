@@ -0,0 +1,200 @@
+package web
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aereal/nagaya"
+	"github.com/dimfeld/httptreemux/v5"
+)
+
+var (
+	defaultWebhookTolerance   = time.Minute * 5
+	defaultWebhookMaxBodySize = int64(1 << 20)
+)
+
+// WebhookEvent is a verified event pushed by the external system.
+type WebhookEvent struct {
+	Source    string
+	ID        string
+	Timestamp time.Time
+	Payload   json.RawMessage
+}
+
+// WebhookHandler handles the verified event within the tenant bound to the context.
+type WebhookHandler func(ctx context.Context, event *WebhookEvent) error
+
+// WithWebhook registers the handler of the events pushed by the source to POST /webhooks/:source.
+//
+// The requests must be signed with the secret: the x-webhook-signature header has the form v1=<hex HMAC-SHA256 of "{id}.{timestamp}.{tenant}.{body}">,
+// where id and timestamp (UNIX seconds) are given in the x-webhook-id and x-webhook-timestamp headers, and tenant is the tenant the request is routed to.
+// Signing the tenant keeps a captured event from being replayed against the other tenants.
+// The requests whose timestamp is out of the tolerance or whose ID has been delivered are rejected to prevent the replay.
+// An ID is recorded as delivered only after the handler succeeds, so the sender can retry the failed event with the same ID;
+// the concurrent deliveries of the same ID are rejected while the first one is handled.
+//
+// The delivered IDs are shared by all the tenants of the source but kept in the memory of the process,
+// so the replay is not detected across the replicas or the restarts; the handler must be idempotent if it matters.
+func WithWebhook(source string, secret []byte, handler WebhookHandler) NewServerOption {
+	return func(s *Server) {
+		if s.webhooks == nil {
+			s.webhooks = map[string]*webhookSource{}
+		}
+		s.webhooks[source] = &webhookSource{secret: secret, handler: handler, seen: newSeenIDs()}
+	}
+}
+
+type webhookSource struct {
+	secret  []byte
+	handler WebhookHandler
+	seen    *seenIDs
+}
+
+func (ws *webhookSource) verify(tenant nagaya.Tenant, id, timestamp, signature string, body []byte) bool {
+	mac := hmac.New(sha256.New, ws.secret)
+	fmt.Fprintf(mac, "%s.%s.%s.", id, timestamp, tenant)
+	mac.Write(body)
+	expected := mac.Sum(nil)
+	for _, sig := range strings.Split(signature, ",") {
+		v, ok := strings.CutPrefix(strings.TrimSpace(sig), "v1=")
+		if !ok {
+			continue
+		}
+		got, err := hex.DecodeString(v)
+		if err != nil {
+			continue
+		}
+		if hmac.Equal(got, expected) {
+			return true
+		}
+	}
+	return false
+}
+
+func newSeenIDs() *seenIDs {
+	return &seenIDs{ids: map[string]time.Time{}, inFlight: map[string]struct{}{}}
+}
+
+// seenIDs remembers the IDs of the events being handled, and the IDs of the delivered events until they get out of the tolerance window.
+type seenIDs struct {
+	mux      sync.Mutex
+	ids      map[string]time.Time
+	inFlight map[string]struct{}
+}
+
+// begin marks the ID in flight and reports whether it is neither delivered nor in flight; the caller must call done or abort if it returns true.
+func (s *seenIDs) begin(id string, now time.Time) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	for k, exp := range s.ids {
+		if now.After(exp) {
+			delete(s.ids, k)
+		}
+	}
+	if _, ok := s.ids[id]; ok {
+		return false
+	}
+	if _, ok := s.inFlight[id]; ok {
+		return false
+	}
+	s.inFlight[id] = struct{}{}
+	return true
+}
+
+// done records the ID in flight as delivered until expiresAt.
+func (s *seenIDs) done(id string, expiresAt time.Time) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	delete(s.inFlight, id)
+	s.ids[id] = expiresAt
+}
+
+// abort forgets the ID in flight so that the event can be delivered again.
+func (s *seenIDs) abort(id string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	delete(s.inFlight, id)
+}
+
+func (s *Server) handlePostWebhook() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		w.Header().Set("content-type", mediaTypeJSON)
+		source := httptreemux.ContextParams(ctx)["source"]
+		ws, ok := s.webhooks[source]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "unknown webhook source"})
+			return
+		}
+		defer r.Body.Close()
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, defaultWebhookMaxBodySize))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: fmt.Sprintf("failed to read request body: %s", err)})
+			return
+		}
+		id := r.Header.Get("x-webhook-id")
+		timestamp := r.Header.Get("x-webhook-timestamp")
+		tenant, _ := nagaya.TenantFromContext(ctx)
+		if id == "" || tenant == "" || !ws.verify(tenant, id, timestamp, r.Header.Get("x-webhook-signature"), body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "invalid signature"})
+			return
+		}
+		unix, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "invalid timestamp"})
+			return
+		}
+		now := time.Now()
+		sentAt := time.Unix(unix, 0)
+		if sentAt.Before(now.Add(-defaultWebhookTolerance)) || sentAt.After(now.Add(defaultWebhookTolerance)) {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "timestamp is out of the tolerance"})
+			return
+		}
+		if !ws.seen.begin(id, now) {
+			w.WriteHeader(http.StatusConflict)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "event already delivered"})
+			return
+		}
+		event := &WebhookEvent{Source: source, ID: id, Timestamp: sentAt, Payload: body}
+		if err := ws.handle(ctx, event); err != nil {
+			slog.ErrorContext(ctx, "failed to handle webhook", slog.String("source", source), slog.String("webhook.id", id), slog.String("error", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			_ = json.NewEncoder(w).Encode(errorResponse{Error: "failed to handle the event"})
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
+
+// handle calls the handler and records the ID of the event as delivered only if it succeeds; the ID is released even if the handler panics.
+func (ws *webhookSource) handle(ctx context.Context, event *WebhookEvent) (err error) {
+	delivered := false
+	defer func() {
+		if delivered {
+			ws.seen.done(event.ID, event.Timestamp.Add(defaultWebhookTolerance))
+		} else {
+			ws.seen.abort(event.ID)
+		}
+	}()
+	if err := ws.handler(ctx, event); err != nil {
+		return err
+	}
+	delivered = true
+	return nil
+}
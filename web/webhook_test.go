@@ -0,0 +1,117 @@
+package web
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aereal/nagaya"
+)
+
+func sign(secret []byte, tenant nagaya.Tenant, id, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(id + "." + timestamp + "." + string(tenant) + "."))
+	mac.Write(body)
+	return "v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestWebhookSource_verify(t *testing.T) {
+	secret := []byte("s3cr3t")
+	body := []byte(`{"type":"user.registered"}`)
+	valid := sign(secret, "tenant_1", "evt_1", "1700000000", body)
+	testCases := []struct {
+		name      string
+		tenant    nagaya.Tenant
+		id        string
+		timestamp string
+		signature string
+		body      []byte
+		want      bool
+	}{
+		{name: "ok", tenant: "tenant_1", id: "evt_1", timestamp: "1700000000", signature: valid, body: body, want: true},
+		{name: "ok/one of the rotated signatures", tenant: "tenant_1", id: "evt_1", timestamp: "1700000000", signature: "v1=deadbeef, " + valid, body: body, want: true},
+		{name: "other secret", tenant: "tenant_1", id: "evt_1", timestamp: "1700000000", signature: sign([]byte("other"), "tenant_1", "evt_1", "1700000000", body), body: body, want: false},
+		{name: "tampered body", tenant: "tenant_1", id: "evt_1", timestamp: "1700000000", signature: valid, body: []byte(`{"type":"user.deleted"}`), want: false},
+		{name: "other id", tenant: "tenant_1", id: "evt_2", timestamp: "1700000000", signature: valid, body: body, want: false},
+		{name: "replayed to other tenant", tenant: "tenant_2", id: "evt_1", timestamp: "1700000000", signature: valid, body: body, want: false},
+		{name: "other timestamp", tenant: "tenant_1", id: "evt_1", timestamp: "1700000001", signature: valid, body: body, want: false},
+		{name: "unknown scheme", tenant: "tenant_1", id: "evt_1", timestamp: "1700000000", signature: "v0=" + valid[len("v1="):], body: body, want: false},
+		{name: "not hex", tenant: "tenant_1", id: "evt_1", timestamp: "1700000000", signature: "v1=zz", body: body, want: false},
+		{name: "empty", tenant: "tenant_1", id: "evt_1", timestamp: "1700000000", signature: "", body: body, want: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ws := &webhookSource{secret: secret}
+			if got := ws.verify(tc.tenant, tc.id, tc.timestamp, tc.signature, tc.body); got != tc.want {
+				t.Errorf("verify() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSeenIDs(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name  string
+		setup func(s *seenIDs)
+		at    time.Time
+		want  bool
+	}{
+		{name: "unseen", setup: func(*seenIDs) {}, at: now, want: true},
+		{name: "in flight", setup: func(s *seenIDs) { s.begin("evt_1", now) }, at: now, want: false},
+		{name: "aborted", setup: func(s *seenIDs) { s.begin("evt_1", now); s.abort("evt_1") }, at: now, want: true},
+		{name: "delivered", setup: func(s *seenIDs) { s.begin("evt_1", now); s.done("evt_1", now.Add(time.Minute)) }, at: now.Add(time.Minute), want: false},
+		{name: "delivered and expired", setup: func(s *seenIDs) { s.begin("evt_1", now); s.done("evt_1", now.Add(time.Minute)) }, at: now.Add(time.Minute + time.Second), want: true},
+		{name: "other id delivered", setup: func(s *seenIDs) { s.begin("evt_2", now); s.done("evt_2", now.Add(time.Minute)) }, at: now, want: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newSeenIDs()
+			tc.setup(s)
+			if got := s.begin("evt_1", tc.at); got != tc.want {
+				t.Errorf("begin() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWebhookSource_handle(t *testing.T) {
+	errHandler := errors.New("oops")
+	testCases := []struct {
+		name           string
+		handler        WebhookHandler
+		wantErr        error
+		wantPanic      bool
+		wantRedelivery bool
+	}{
+		{name: "succeeded", handler: func(context.Context, *WebhookEvent) error { return nil }, wantRedelivery: false},
+		{name: "failed", handler: func(context.Context, *WebhookEvent) error { return errHandler }, wantErr: errHandler, wantRedelivery: true},
+		{name: "panicked", handler: func(context.Context, *WebhookEvent) error { panic("oops") }, wantPanic: true, wantRedelivery: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			now := time.Now()
+			ws := &webhookSource{handler: tc.handler, seen: newSeenIDs()}
+			if !ws.seen.begin("evt_1", now) {
+				t.Fatal("begin() = false")
+			}
+			func() {
+				defer func() {
+					if rv := recover(); (rv != nil) != tc.wantPanic {
+						t.Errorf("recovered = %v, wantPanic = %v", rv, tc.wantPanic)
+					}
+				}()
+				if err := ws.handle(context.Background(), &WebhookEvent{ID: "evt_1", Timestamp: now}); !errors.Is(err, tc.wantErr) {
+					t.Errorf("error = %v, want %v", err, tc.wantErr)
+				}
+			}()
+			if got := ws.seen.begin("evt_1", now); got != tc.wantRedelivery {
+				t.Errorf("begin() after handle = %v, want %v", got, tc.wantRedelivery)
+			}
+		})
+	}
+}
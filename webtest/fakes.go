@@ -0,0 +1,196 @@
+package webtest
+
+import (
+	"context"
+	"enjoymultitenancy/repos"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/aereal/nagaya"
+	"github.com/rs/xid"
+)
+
+// FakeUserRepo is an in-memory implementation of web.UserRepository.
+//
+// The users are partitioned by the tenant bound to the context like the real repo.
+type FakeUserRepo struct {
+	mux   sync.RWMutex
+	users map[nagaya.Tenant]map[string]*repos.User
+}
+
+func NewFakeUserRepo() *FakeUserRepo {
+	return &FakeUserRepo{users: map[nagaya.Tenant]map[string]*repos.User{}}
+}
+
+func (r *FakeUserRepo) RegisterUser(ctx context.Context, user *repos.UserToRegister) (*repos.User, error) {
+	if user == nil {
+		return nil, repos.ErrUserNameRequired
+	}
+	if err := user.Validate(); err != nil {
+		return nil, err
+	}
+	tenant, _ := nagaya.TenantFromContext(ctx)
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.users[tenant] == nil {
+		r.users[tenant] = map[string]*repos.User{}
+	}
+	if _, ok := r.users[tenant][user.Name]; ok {
+		return nil, repos.ErrUserAlreadyExists
+	}
+	registered := &repos.User{ID: xid.New().String(), Name: user.Name}
+	r.users[tenant][user.Name] = registered
+	u := *registered
+	return &u, nil
+}
+
+func (r *FakeUserRepo) RegisterUsers(ctx context.Context, users []*repos.UserToRegister) ([]*repos.User, error) {
+	tenant, _ := nagaya.TenantFromContext(ctx)
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	importErr := new(repos.UserImportError)
+	seen := map[string]bool{}
+	for i, user := range users {
+		if user == nil {
+			importErr.Rows = append(importErr.Rows, &repos.RowError{Index: i, Err: repos.ErrUserNameRequired})
+			continue
+		}
+		if err := user.Validate(); err != nil {
+			importErr.Rows = append(importErr.Rows, &repos.RowError{Index: i, Err: err})
+			continue
+		}
+		if seen[user.Name] || r.users[tenant][user.Name] != nil {
+			importErr.Rows = append(importErr.Rows, &repos.RowError{Index: i, Err: repos.ErrUserAlreadyExists})
+		}
+		seen[user.Name] = true
+	}
+	if len(importErr.Rows) > 0 {
+		return nil, importErr
+	}
+	if r.users[tenant] == nil {
+		r.users[tenant] = map[string]*repos.User{}
+	}
+	registered := make([]*repos.User, len(users))
+	for i, user := range users {
+		r.users[tenant][user.Name] = &repos.User{ID: xid.New().String(), Name: user.Name}
+		u := *r.users[tenant][user.Name]
+		registered[i] = &u
+	}
+	return registered, nil
+}
+
+func (r *FakeUserRepo) FetchUserByName(ctx context.Context, name string, _ ...repos.FetchUserOption) (*repos.User, error) {
+	if name == "" {
+		return nil, repos.ErrUserNameRequired
+	}
+	tenant, _ := nagaya.TenantFromContext(ctx)
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	user, ok := r.users[tenant][name]
+	if !ok {
+		return nil, repos.ErrNotFound
+	}
+	u := *user
+	return &u, nil
+}
+
+func (r *FakeUserRepo) UpdateUser(ctx context.Context, name string, opts ...repos.UpdateUserOption) (*repos.User, error) {
+	if name == "" {
+		return nil, repos.ErrUserNameRequired
+	}
+	update := new(repos.UserUpdate)
+	for _, o := range opts {
+		o(update)
+	}
+	if update.Name == nil {
+		return nil, repos.ErrNothingToUpdate
+	}
+	if err := update.Validate(); err != nil {
+		return nil, err
+	}
+	tenant, _ := nagaya.TenantFromContext(ctx)
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	user, ok := r.users[tenant][name]
+	if !ok {
+		return nil, repos.ErrNotFound
+	}
+	if _, taken := r.users[tenant][*update.Name]; taken && *update.Name != name {
+		return nil, repos.ErrUserAlreadyExists
+	}
+	delete(r.users[tenant], name)
+	user.Name = *update.Name
+	r.users[tenant][user.Name] = user
+	u := *user
+	return &u, nil
+}
+
+func (r *FakeUserRepo) DeleteUser(ctx context.Context, name string) error {
+	if name == "" {
+		return repos.ErrUserNameRequired
+	}
+	tenant, _ := nagaya.TenantFromContext(ctx)
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if _, ok := r.users[tenant][name]; !ok {
+		return repos.ErrNotFound
+	}
+	delete(r.users[tenant], name)
+	return nil
+}
+
+// ListUsers returns all the users of the tenant in a page ordered by the ID; the options are ignored.
+func (r *FakeUserRepo) ListUsers(ctx context.Context, _ ...repos.ListUsersOption) (*repos.UserPage, error) {
+	tenant, _ := nagaya.TenantFromContext(ctx)
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	users := make([]*repos.User, 0, len(r.users[tenant]))
+	for _, user := range r.users[tenant] {
+		u := *user
+		users = append(users, &u)
+	}
+	slices.SortFunc(users, func(a, b *repos.User) int { return strings.Compare(a.ID, b.ID) })
+	return &repos.UserPage{Users: users}, nil
+}
+
+// FakeMembershipRepo is an in-memory implementation of web.MembershipRepository.
+type FakeMembershipRepo struct {
+	mux         sync.RWMutex
+	memberships map[nagaya.Tenant]map[string]*repos.Membership
+}
+
+func NewFakeMembershipRepo() *FakeMembershipRepo {
+	return &FakeMembershipRepo{memberships: map[nagaya.Tenant]map[string]*repos.Membership{}}
+}
+
+// Grant grants the role to the subject within the tenant.
+func (r *FakeMembershipRepo) Grant(tenant nagaya.Tenant, m *repos.Membership) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.memberships[tenant] == nil {
+		r.memberships[tenant] = map[string]*repos.Membership{}
+	}
+	r.memberships[tenant][m.Subject] = m
+}
+
+func (r *FakeMembershipRepo) FetchMembership(ctx context.Context, subject string) (*repos.Membership, error) {
+	if subject == "" {
+		return nil, repos.ErrSubjectRequired
+	}
+	tenant, _ := nagaya.TenantFromContext(ctx)
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	m, ok := r.memberships[tenant][subject]
+	if !ok {
+		return nil, repos.ErrNotFound
+	}
+	return m, nil
+}
+
+// FakeTransactor runs the functions without transactions; the writes done before the failure are not rolled back.
+type FakeTransactor struct{}
+
+func (FakeTransactor) RunInTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
@@ -0,0 +1,56 @@
+// Package webtest provides helpers to test the handlers of the web package without the databases.
+package webtest
+
+import (
+	"encoding/json"
+	"enjoymultitenancy/web"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aereal/nagaya"
+)
+
+// TenantHeader is the header that the stub apartment middleware reads the tenant from.
+const TenantHeader = "tenant-id"
+
+// TestServer is a running server backed by the in-memory repos.
+type TestServer struct {
+	*httptest.Server
+	Users       *FakeUserRepo
+	Memberships *FakeMembershipRepo
+}
+
+// NewTestServer starts a server wired with the fake repos and the stub apartment middleware.
+//
+// The given options are applied after the defaults, so they can replace the fakes.
+// The server is closed on the cleanup of the test.
+func NewTestServer(t testing.TB, opts ...web.NewServerOption) *TestServer {
+	t.Helper()
+	ts := &TestServer{
+		Users:       NewFakeUserRepo(),
+		Memberships: NewFakeMembershipRepo(),
+	}
+	srvOpts := append([]web.NewServerOption{
+		web.WithUserRepo(ts.Users),
+		web.WithTransactor(FakeTransactor{}),
+		web.WithApartmentMiddleware(StubApartmentMiddleware),
+	}, opts...)
+	ts.Server = httptest.NewServer(web.NewServer(srvOpts...).Handler())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// StubApartmentMiddleware binds the tenant given in TenantHeader to the request context without obtaining database connections.
+func StubApartmentMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant := r.Header.Get(TenantHeader)
+		if tenant == "" {
+			w.Header().Set("content-type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": nagaya.ErrNoTenantBound.Error()})
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(nagaya.WithTenant(r.Context(), nagaya.Tenant(tenant))))
+	})
+}